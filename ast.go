@@ -24,6 +24,7 @@ type ParsedDef interface {
 type ParsedFunDef struct {
 	Fun        Token
 	Typename   *Token
+	TypeParams []Token
 	Dot        *Token
 	Id         Token
 	Params     []ParsedFunParam
@@ -43,9 +44,10 @@ type ParsedImport struct {
 }
 
 type ParsedStructDef struct {
-	Struct Token
-	Name   Token
-	Fields []ParsedStructField
+	Struct     Token
+	Name       Token
+	TypeParams []Token
+	Fields     []ParsedStructField
 }
 
 type ParsedStructField struct {
@@ -67,6 +69,55 @@ type ParsedTypealiasDef struct {
 	Type      ParsedType
 }
 
+type ParsedEnumDef struct {
+	Enum     Token
+	Name     Token
+	Variants []ParsedEnumVariant
+}
+
+type ParsedEnumVariant struct {
+	Name    Token
+	Payload []ParsedType
+	Eq      *Token
+	Value   ParsedExpr
+}
+
+type ParsedConstDef struct {
+	Const Token
+	Name  Token
+	Eq    Token
+	Value ParsedExpr
+}
+
+type ParsedVarDef struct {
+	Mut   *Token
+	Var   Token
+	Name  Token
+	Eq    Token
+	Value ParsedExpr
+}
+
+type ParsedTraitDef struct {
+	Trait   Token
+	Name    Token
+	Methods []ParsedTraitMethod
+}
+
+type ParsedTraitMethod struct {
+	Fun        Token
+	Id         Token
+	Params     []ParsedFunParam
+	ReturnType ParsedType
+}
+
+type ParsedImplDef struct {
+	Impl     Token
+	Trait    ParsedType
+	For      Token
+	Typename Token
+	Methods  []*ParsedFunDef
+}
+
 func (f *ParsedFunDef) pos() Pos {
 	return f.Fun.Pos
 }
@@ -82,12 +133,35 @@ func (e *ParsedExternFunDef) pos() Pos {
 func (p *ParsedTypealiasDef) pos() Pos {
 	return p.Typealias.Pos
 }
+func (e *ParsedEnumDef) pos() Pos {
+	return e.Enum.Pos
+}
+func (c *ParsedConstDef) pos() Pos {
+	return c.Const.Pos
+}
+func (v *ParsedVarDef) pos() Pos {
+	if v.Mut != nil {
+		return v.Mut.Pos
+	}
+	return v.Var.Pos
+}
+func (t *ParsedTraitDef) pos() Pos {
+	return t.Trait.Pos
+}
+func (i *ParsedImplDef) pos() Pos {
+	return i.Impl.Pos
+}
 
 func (f *ParsedFunDef) def()       {}
 func (i *ParsedImport) def()       {}
 func (s *ParsedStructDef) def()    {}
 func (e *ParsedExternFunDef) def() {}
 func (p *ParsedTypealiasDef) def() {}
+func (e *ParsedEnumDef) def()      {}
+func (c *ParsedConstDef) def()     {}
+func (v *ParsedVarDef) def()       {}
+func (t *ParsedTraitDef) def()     {}
+func (i *ParsedImplDef) def()      {}
 
 func (f *ParsedFunDef) id() string {
 	return f.Id.Content
@@ -104,6 +178,21 @@ func (e *ParsedExternFunDef) id() string {
 func (p *ParsedTypealiasDef) id() string {
 	return p.Name.Content
 }
+func (e *ParsedEnumDef) id() string {
+	return e.Name.Content
+}
+func (c *ParsedConstDef) id() string {
+	return c.Name.Content
+}
+func (v *ParsedVarDef) id() string {
+	return v.Name.Content
+}
+func (t *ParsedTraitDef) id() string {
+	return t.Name.Content
+}
+func (i *ParsedImplDef) id() string {
+	return i.Typename.Content
+}
 
 type ParsedStmt interface {
 	ParsedNode
@@ -134,9 +223,11 @@ type ParsedReturn struct {
 
 type ParsedIf struct {
 	If        Token
+	Binding   *Token
 	Condition ParsedExpr
 	Body      *ParsedBlock
 	ElseBody  *ParsedBlock
+	ElseIf    *ParsedIf
 }
 
 type ParsedWhile struct {
@@ -145,6 +236,24 @@ type ParsedWhile struct {
 	Body      *ParsedBlock
 }
 
+type ParsedFor struct {
+	For  Token
+	Init ParsedStmt
+	Cond ParsedExpr
+	Post ParsedStmt
+	Body *ParsedBlock
+}
+
+type ParsedForIn struct {
+	For      Token
+	Name     Token
+	In       Token
+	Iterable ParsedExpr
+	DotDot   *Token
+	End      ParsedExpr
+	Body     *ParsedBlock
+}
+
 type ParsedBreak struct {
 	Break Token
 }
@@ -153,6 +262,29 @@ type ParsedContinue struct {
 	Continue Token
 }
 
+type ParsedMatch struct {
+	Match    Token
+	Value    ParsedExpr
+	Arms     []ParsedMatchArm
+	ElseBody *ParsedBlock
+}
+
+type ParsedDefer struct {
+	Defer Token
+	Stmt  ParsedStmt
+}
+
+type ParsedUnsafe struct {
+	Unsafe Token
+	Body   *ParsedBlock
+}
+
+type ParsedMatchArm struct {
+	Variant  Token
+	Bindings []Token
+	Body     *ParsedBlock
+}
+
 func (v *ParsedVar) pos() Pos {
 	return v.Id.Pos
 }
@@ -171,12 +303,27 @@ func (i *ParsedIf) pos() Pos {
 func (p ParsedWhile) pos() Pos {
 	return p.While.Pos
 }
+func (p *ParsedFor) pos() Pos {
+	return p.For.Pos
+}
+func (p *ParsedForIn) pos() Pos {
+	return p.For.Pos
+}
 func (p ParsedBreak) pos() Pos {
 	return p.Break.Pos
 }
 func (p ParsedContinue) pos() Pos {
 	return p.Continue.Pos
 }
+func (m *ParsedMatch) pos() Pos {
+	return m.Match.Pos
+}
+func (d *ParsedDefer) pos() Pos {
+	return d.Defer.Pos
+}
+func (u *ParsedUnsafe) pos() Pos {
+	return u.Unsafe.Pos
+}
 
 func (v *ParsedVar) stmt()      {}
 func (e *ParsedExprStmt) stmt() {}
@@ -184,8 +331,13 @@ func (b *ParsedBlock) stmt()    {}
 func (r *ParsedReturn) stmt()   {}
 func (i *ParsedIf) stmt()       {}
 func (p *ParsedWhile) stmt()    {}
+func (p *ParsedFor) stmt()      {}
+func (p *ParsedForIn) stmt()    {}
 func (p *ParsedBreak) stmt()    {}
 func (p *ParsedContinue) stmt() {}
+func (m *ParsedMatch) stmt()    {}
+func (d *ParsedDefer) stmt()    {}
+func (u *ParsedUnsafe) stmt()   {}
 
 type ParsedExpr interface {
 	ParsedNode
@@ -213,6 +365,15 @@ type ParsedLiteralExpr struct {
 	Token
 }
 
+type ParsedInterpolatedStringExpr struct {
+	Parts []Token
+	Exprs []ParsedExpr
+}
+
+type ParsedIfExpr struct {
+	If *ParsedIf
+}
+
 type ParsedIdExpr struct {
 	Token
 }
@@ -250,6 +411,51 @@ type ParsedAsExpr struct {
 	Type  ParsedType
 }
 
+type ParsedArrayLiteralExpr struct {
+	Type  *ParsedArrayType
+	Left  Token
+	Elems []ParsedExpr
+	Right Token
+}
+
+type ParsedIndexExpr struct {
+	Object ParsedExpr
+	Left   Token
+	Index  ParsedExpr
+	Right  Token
+}
+
+type ParsedSliceExpr struct {
+	Object ParsedExpr
+	Left   Token
+	Low    ParsedExpr
+	Colon  Token
+	High   ParsedExpr
+	Right  Token
+}
+
+type ParsedTypeArgsExpr struct {
+	Object ParsedExpr
+	Left   Token
+	Args   []ParsedType
+	Right  Token
+	// Index is the same brackets parsed as an index expression, if they parse
+	// as one. The checker picks between the two once it resolves Object.
+	Index ParsedExpr
+}
+
+type ParsedPropagateExpr struct {
+	Value    ParsedExpr
+	Question Token
+}
+
+type ParsedFunExpr struct {
+	Fun        Token
+	Params     []ParsedFunParam
+	ReturnType ParsedType
+	Body       *ParsedBlock
+}
+
 func (u ParsedUnaryExpr) pos() Pos {
 	return u.Operator.Pos
 }
@@ -262,6 +468,12 @@ func (g ParsedGroupedExpr) pos() Pos {
 func (l ParsedLiteralExpr) pos() Pos {
 	return l.Token.Pos
 }
+func (i ParsedInterpolatedStringExpr) pos() Pos {
+	return i.Parts[0].Pos
+}
+func (i ParsedIfExpr) pos() Pos {
+	return i.If.pos()
+}
 func (i ParsedIdExpr) pos() Pos {
 	return i.Token.Pos
 }
@@ -283,17 +495,46 @@ func (p ParsedModuleAccessExpr) pos() Pos {
 func (p ParsedAsExpr) pos() Pos {
 	return p.Value.pos()
 }
+func (p ParsedArrayLiteralExpr) pos() Pos {
+	if p.Type != nil {
+		return p.Type.pos()
+	}
+	return p.Left.Pos
+}
+func (p ParsedIndexExpr) pos() Pos {
+	return p.Object.pos()
+}
+func (p ParsedSliceExpr) pos() Pos {
+	return p.Object.pos()
+}
+func (p ParsedTypeArgsExpr) pos() Pos {
+	return p.Object.pos()
+}
+func (p ParsedFunExpr) pos() Pos {
+	return p.Fun.Pos
+}
+func (p ParsedPropagateExpr) pos() Pos {
+	return p.Value.pos()
+}
 
-func (u ParsedUnaryExpr) expr()        {}
-func (b ParsedBinaryExpr) expr()       {}
-func (g ParsedGroupedExpr) expr()      {}
-func (l ParsedLiteralExpr) expr()      {}
-func (i ParsedIdExpr) expr()           {}
-func (c ParsedCallExpr) expr()         {}
-func (s ParsedStructInitExpr) expr()   {}
-func (a ParsedObjectAccessExpr) expr() {}
-func (p ParsedModuleAccessExpr) expr() {}
-func (p ParsedAsExpr) expr()           {}
+func (u ParsedUnaryExpr) expr()              {}
+func (b ParsedBinaryExpr) expr()             {}
+func (g ParsedGroupedExpr) expr()            {}
+func (l ParsedLiteralExpr) expr()            {}
+func (i ParsedInterpolatedStringExpr) expr() {}
+func (i ParsedIfExpr) expr()                 {}
+func (i ParsedIdExpr) expr()                 {}
+func (c ParsedCallExpr) expr()               {}
+func (s ParsedStructInitExpr) expr()         {}
+func (a ParsedObjectAccessExpr) expr()       {}
+func (p ParsedModuleAccessExpr) expr()       {}
+func (p ParsedAsExpr) expr()                 {}
+func (p ParsedArrayLiteralExpr) expr()       {}
+func (p ParsedIndexExpr) expr()              {}
+func (p ParsedSliceExpr) expr()              {}
+func (p ParsedTypeArgsExpr) expr()           {}
+func (p ParsedFunExpr) expr()                {}
+func (p ParsedPropagateExpr) expr()          {}
 
 type ParsedType interface {
 	ParsedNode
@@ -315,6 +556,45 @@ type ParsedModuleAccessType struct {
 	Member     ParsedType
 }
 
+type ParsedArrayType struct {
+	Left  Token
+	Len   ParsedExpr
+	Right Token
+	Elem  ParsedType
+}
+
+type ParsedSliceType struct {
+	Left  Token
+	Right Token
+	Elem  ParsedType
+}
+
+type ParsedGenericType struct {
+	Type  ParsedType
+	Left  Token
+	Args  []ParsedType
+	Right Token
+}
+
+type ParsedOptionalType struct {
+	Question Token
+	Elem     ParsedType
+}
+
+type ParsedResultType struct {
+	Value ParsedType
+	Bang  Token
+	Error ParsedType
+}
+
+type ParsedFunType struct {
+	Fun     Token
+	Left    Token
+	Params  []ParsedType
+	Right   Token
+	Returns ParsedType
+}
+
 func (i *ParsedIdType) pos() Pos {
 	return i.Token.Pos
 }
@@ -324,7 +604,31 @@ func (p *ParsedPointerType) pos() Pos {
 func (p *ParsedModuleAccessType) pos() Pos {
 	return p.Module.Pos
 }
+func (p *ParsedArrayType) pos() Pos {
+	return p.Left.Pos
+}
+func (p *ParsedSliceType) pos() Pos {
+	return p.Left.Pos
+}
+func (p *ParsedGenericType) pos() Pos {
+	return p.Type.pos()
+}
+func (p *ParsedFunType) pos() Pos {
+	return p.Fun.Pos
+}
+func (p *ParsedOptionalType) pos() Pos {
+	return p.Question.Pos
+}
+func (p *ParsedResultType) pos() Pos {
+	return p.Value.pos()
+}
 
 func (i *ParsedIdType) parsedType()           {}
 func (p *ParsedPointerType) parsedType()      {}
 func (p *ParsedModuleAccessType) parsedType() {}
+func (p *ParsedArrayType) parsedType()        {}
+func (p *ParsedSliceType) parsedType()        {}
+func (p *ParsedGenericType) parsedType()      {}
+func (p *ParsedFunType) parsedType()          {}
+func (p *ParsedOptionalType) parsedType()     {}
+func (p *ParsedResultType) parsedType()       {}
@@ -32,6 +32,8 @@ func main() {
 	checkedFile, err := wall.CheckCompilationUnit(parsedFile)
 	check(err)
 	wall.LowerExternFunctions(checkedFile)
+	wall.LowerTemporaries(checkedFile)
+	wall.LowerDefers(checkedFile)
 	cSource := wall.CodegenCompilationUnit(checkedFile)
 	if *cHeaders {
 		fmt.Println("#include <stdlib.h>")
@@ -39,6 +41,7 @@ func main() {
 		fmt.Println("#include <stdint.h>")
 		fmt.Println("#include <string.h>")
 		fmt.Println("#include <stddef.h>")
+		fmt.Println("#include <stdarg.h>")
 	}
 	fmt.Println(cSource)
 }
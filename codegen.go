@@ -2,7 +2,9 @@ package wall
 
 import (
 	"fmt"
+	"math/big"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
@@ -19,6 +21,10 @@ func CodegenCompilationUnit(c *CheckedFile) string {
 	result.WriteString(CodegenFuncDeclarations(c))
 	result.WriteString("/* type definitions */\n")
 	result.WriteString(CodegenTypeDefinitions(c))
+	result.WriteString("/* builtin functions */\n")
+	result.WriteString(CodegenBuiltinFunctions(c))
+	result.WriteString("/* global variables */\n")
+	result.WriteString(CodegenGlobals(c))
 	result.WriteString("/* function definitions */\n")
 	result.WriteString(CodegenFuncDefinitions(c))
 	return result.String()
@@ -35,7 +41,7 @@ func codegenFuncTypedefs(c *CheckedFile, checkedFiles map[*CheckedFile]struct{})
 	checkedFiles[c] = struct{}{}
 	var builder strings.Builder
 	for i, typ := range *c.Types {
-		if typ, ok := typ.(*FunctionType); ok {
+		if typ, ok := typ.(*FunctionType); ok && typ.Extern {
 			fmt.Fprintf(&builder, "typedef %s (*%s)(", CodegenType(typ.Returns, c.GlobalScope), cFuncTypeId(i, c.Filename))
 			if len(typ.Params) == 0 {
 				builder.WriteString(CodegenType(UNIT_TYPE_ID, c.GlobalScope))
@@ -47,6 +53,14 @@ func codegenFuncTypedefs(c *CheckedFile, checkedFiles map[*CheckedFile]struct{})
 				}
 			}
 			builder.WriteString(");\n")
+		} else if ok {
+			id := cFuncTypeId(i, c.Filename)
+			fmt.Fprintf(&builder, "typedef struct %s {\n%s (*fn)(void*", id, CodegenType(typ.Returns, c.GlobalScope))
+			for _, param := range typ.Params {
+				fmt.Fprintf(&builder, ", %s", CodegenType(param, c.GlobalScope))
+			}
+			builder.WriteString(");\nvoid* env;\n} ")
+			fmt.Fprintf(&builder, "%s;\n", id)
 		}
 		if typ, ok := typ.(*MethodType); ok {
 			fmt.Fprintf(&builder, "typedef %s (*%s)(", CodegenType(typ.Returns, c.GlobalScope), cFuncTypeId(i, c.Filename))
@@ -70,28 +84,247 @@ func cFuncTypeId(id int, filename string) string {
 	return cId(fmt.Sprintf("%s_FUNC_TYPE_%d", moduleNameFromFilename(filename), id))
 }
 
+func cArrayTypeId(id TypeId) string {
+	return cId(fmt.Sprintf("ARRAY_TYPE_%d", id))
+}
+
+func cSliceTypeId(id TypeId) string {
+	return cId(fmt.Sprintf("SLICE_TYPE_%d", id))
+}
+
+func CodegenBuiltinFunctions(c *CheckedFile) string {
+	var builder strings.Builder
+	builder.WriteString("static inline size_t WALL_checkIndex(size_t index, size_t len) {\n")
+	builder.WriteString("if (index >= len) {\n")
+	builder.WriteString("fprintf(stderr, \"index out of range: %zu (len is %zu)\\n\", index, len);\n")
+	builder.WriteString("abort();\n")
+	builder.WriteString("}\n")
+	builder.WriteString("return index;\n")
+	builder.WriteString("}\n")
+	builder.WriteString("static char* WALL_format(const char* format, ...) {\n")
+	builder.WriteString("va_list args;\n")
+	builder.WriteString("va_start(args, format);\n")
+	builder.WriteString("int len = vsnprintf(NULL, 0, format, args);\n")
+	builder.WriteString("va_end(args);\n")
+	builder.WriteString("char* result = malloc(len + 1);\n")
+	builder.WriteString("va_start(args, format);\n")
+	builder.WriteString("vsnprintf(result, len + 1, format, args);\n")
+	builder.WriteString("va_end(args);\n")
+	builder.WriteString("return result;\n")
+	builder.WriteString("}\n")
+	for i, typ := range *c.Types {
+		if typ, ok := typ.(*SliceType); ok {
+			codegenSliceFunctions(&builder, TypeId(i), typ, c.GlobalScope)
+		}
+		if typ, ok := typ.(*EnumType); ok {
+			codegenEnumConstructors(&builder, TypeId(i), typ, c.GlobalScope)
+		}
+		if typ, ok := typ.(*FunctionType); ok && !typ.Extern {
+			codegenFunctionCall(&builder, TypeId(i), typ, c.GlobalScope)
+		}
+	}
+	builder.WriteString(codegenClosureEnvs(c, c.GlobalScope, make(map[*CheckedFile]struct{})))
+	return builder.String()
+}
+
+func codegenFunctionCall(builder *strings.Builder, id TypeId, typ *FunctionType, s *Scope) {
+	fun := CodegenType(id, s)
+	fmt.Fprintf(builder, "static inline %s %s_call(%s f", CodegenType(typ.Returns, s), fun, fun)
+	for i, param := range typ.Params {
+		fmt.Fprintf(builder, ", %s _%d", CodegenType(param, s), i)
+	}
+	builder.WriteString(") {\n")
+	if typ.Returns != UNIT_TYPE_ID {
+		builder.WriteString("return ")
+	}
+	builder.WriteString("f.fn(f.env")
+	for i := range typ.Params {
+		fmt.Fprintf(builder, ", _%d", i)
+	}
+	builder.WriteString(");\n")
+	builder.WriteString("}\n")
+}
+
+func codegenClosureEnvs(c *CheckedFile, s *Scope, checkedFiles map[*CheckedFile]struct{}) string {
+	if _, ok := checkedFiles[c]; ok {
+		return ""
+	}
+	checkedFiles[c] = struct{}{}
+	var builder strings.Builder
+	for _, closure := range c.Closures {
+		if len(closure.Captures) == 0 {
+			continue
+		}
+		name := closure.Name.Content
+		fmt.Fprintf(&builder, "struct %s_env {\n", name)
+		for _, capture := range closure.Captures {
+			fmt.Fprintf(&builder, "%s %s;\n", CodegenType(capture.Type, s), capture.Name.Content)
+		}
+		builder.WriteString("};\n")
+	}
+	for _, imp := range c.Imports {
+		builder.WriteString(codegenClosureEnvs(imp.File, s, checkedFiles))
+	}
+	return builder.String()
+}
+
+func codegenEnumConstructors(builder *strings.Builder, id TypeId, typ *EnumType, s *Scope) {
+	enum := s.TypeToString(id)
+	for _, variant := range payloadVariants(typ) {
+		fmt.Fprintf(builder, "static inline %s %s(", enum, cEnumConstructorId(enum, variant))
+		for i, param := range typ.Payloads[variant] {
+			if i > 0 {
+				builder.WriteString(", ")
+			}
+			fmt.Fprintf(builder, "%s _%d", CodegenType(param, s), i)
+		}
+		builder.WriteString(") {\n")
+		fmt.Fprintf(builder, "%s v;\n", enum)
+		fmt.Fprintf(builder, "v.tag = %s;\n", cEnumVariantId(enum, variant))
+		for i := range typ.Payloads[variant] {
+			fmt.Fprintf(builder, "v.as.%s._%d = _%d;\n", variant, i, i)
+		}
+		builder.WriteString("return v;\n")
+		builder.WriteString("}\n")
+	}
+}
+
+func payloadVariants(typ *EnumType) []string {
+	variants := make([]string, 0, len(typ.Payloads))
+	for variant := range typ.Payloads {
+		variants = append(variants, variant)
+	}
+	sort.Slice(variants, func(i, j int) bool {
+		return typ.Variants[variants[i]] < typ.Variants[variants[j]]
+	})
+	return variants
+}
+
+func codegenSliceFunctions(builder *strings.Builder, id TypeId, typ *SliceType, s *Scope) {
+	slice := cSliceTypeId(id)
+	elem := CodegenType(typ.Elem, s)
+	fmt.Fprintf(builder, "static inline %s* %s_at(%s s, size_t index) {\n", elem, slice, slice)
+	builder.WriteString("return &s.ptr[WALL_checkIndex(index, s.len)];\n")
+	builder.WriteString("}\n")
+	fmt.Fprintf(builder, "static inline %s %s_slice(%s s, size_t low, size_t high) {\n", slice, slice, slice)
+	builder.WriteString("if (low > high || high > s.len) {\n")
+	builder.WriteString("fprintf(stderr, \"slice bounds out of range: [%zu:%zu] (len is %zu)\\n\", low, high, s.len);\n")
+	builder.WriteString("abort();\n")
+	builder.WriteString("}\n")
+	fmt.Fprintf(builder, "return (%s) { s.ptr + low, high - low };\n", slice)
+	builder.WriteString("}\n")
+	fmt.Fprintf(builder, "static inline %s %s_sliceFrom(%s s, size_t low) {\n", slice, slice, slice)
+	fmt.Fprintf(builder, "return %s_slice(s, low, s.len);\n", slice)
+	builder.WriteString("}\n")
+}
+
 func WallPrefixesToGlobalNames(c *CheckedFile) {
-	moduleNamesToGlobalNames(c, make(map[*CheckedFile]struct{}))
-	wallPrefixesToGlobalNames(c, make(map[*CheckedFile]struct{}))
+	moduleNamesToGlobalNames(c, true, make(map[*CheckedFile]struct{}))
+	wallPrefixesToGlobalNames(c, true, make(map[*CheckedFile]struct{}))
 }
 
 func CodegenTypeDeclarations(c *CheckedFile) string {
-	return codegenTypeDeclarations(c, make(map[*CheckedFile]struct{}))
+	var builder strings.Builder
+	builder.WriteString(codegenTypeDeclarations(c, make(map[*CheckedFile]struct{})))
+	for i, typ := range *c.Types {
+		if _, ok := typ.(*ArrayType); ok {
+			id := cArrayTypeId(TypeId(i))
+			fmt.Fprintf(&builder, "typedef struct %s %s;\n", id, id)
+		}
+		if _, ok := typ.(*SliceType); ok {
+			id := cSliceTypeId(TypeId(i))
+			fmt.Fprintf(&builder, "typedef struct %s %s;\n", id, id)
+		}
+		if _, ok := typ.(*OptionalType); ok && !isPointerOptional(TypeId(i), c.GlobalScope) {
+			id := cOptionalTypeId(TypeId(i))
+			fmt.Fprintf(&builder, "typedef struct %s %s;\n", id, id)
+		}
+		if _, ok := typ.(*ResultType); ok {
+			id := cResultTypeId(TypeId(i))
+			fmt.Fprintf(&builder, "typedef struct %s %s;\n", id, id)
+		}
+	}
+	return builder.String()
 }
 
 func CodegenFuncDeclarations(c *CheckedFile) string {
-	return codegenFuncDeclarations(c, make(map[*CheckedFile]struct{}))
+	return codegenFuncDeclarations(c, c.GlobalScope, make(map[*CheckedFile]struct{}))
 }
 
 func CodegenTypeDefinitions(c *CheckedFile) string {
-	return codegenTypeDefinitions(c, make(map[*CheckedFile]struct{}))
+	structs := make(map[TypeId]*CheckedStructDef)
+	order := collectStructDefs(c, structs, make(map[*CheckedFile]struct{}))
+	for i := range *c.Types {
+		order = append(order, TypeId(i))
+	}
+	var builder strings.Builder
+	defined := make(map[TypeId]struct{})
+	for _, id := range order {
+		codegenTypeDefinition(&builder, id, structs, defined, c.GlobalScope)
+	}
+	return builder.String()
 }
 
 func CodegenFuncDefinitions(c *CheckedFile) string {
-	return codegenFuncDefinitions(c, make(map[*CheckedFile]struct{}))
+	return codegenFuncDefinitions(c, true, c.GlobalScope, make(map[*CheckedFile]struct{}))
+}
+
+const GLOBALS_INIT = "WALL_init"
+
+func CodegenGlobals(c *CheckedFile) string {
+	var builder strings.Builder
+	init := &CheckedBlock{}
+	codegenGlobals(&builder, init, c, c.GlobalScope, make(map[*CheckedFile]struct{}))
+	if len(init.Stmts) > 0 {
+		(&temporaries{file: c}).lowerBlock(init, nil)
+		fmt.Fprintf(&builder, "static void %s(void) %s", GLOBALS_INIT, codegenBlock(init, c.GlobalScope))
+	}
+	return builder.String()
+}
+
+func codegenGlobals(builder *strings.Builder, init *CheckedBlock, c *CheckedFile, s *Scope, checkedFiles map[*CheckedFile]struct{}) {
+	if _, ok := checkedFiles[c]; ok {
+		return
+	}
+	checkedFiles[c] = struct{}{}
+	for _, imp := range c.Imports {
+		codegenGlobals(builder, init, imp.File, s, checkedFiles)
+	}
+	for _, global := range c.Globals {
+		typ := CodegenType(global.Value.TypeId(), s)
+		if global.Static {
+			fmt.Fprintf(builder, "static %s %s = %s;\n", typ, global.Name.Content, CodegenExpr(global.Value, s))
+		} else {
+			fmt.Fprintf(builder, "static %s %s;\n", typ, global.Name.Content)
+			init.Stmts = append(init.Stmts, &CheckedExprStmt{
+				Expr: assign(&CheckedIdExpr{Id: global.Name, Type: global.Value.TypeId()}, global.Value),
+			})
+		}
+	}
+}
+
+func hasGlobalsInit(c *CheckedFile, checkedFiles map[*CheckedFile]struct{}) bool {
+	if _, ok := checkedFiles[c]; ok {
+		return false
+	}
+	checkedFiles[c] = struct{}{}
+	for _, global := range c.Globals {
+		if !global.Static {
+			return true
+		}
+	}
+	for _, imp := range c.Imports {
+		if hasGlobalsInit(imp.File, checkedFiles) {
+			return true
+		}
+	}
+	return false
 }
 
 func CodegenExpr(expr CheckedExpr, s *Scope) string {
+	if _, isLiteral := expr.(*CheckedLiteralExpr); !isLiteral && isUntypedConstant(expr) && isInteger(expr.TypeId()) {
+		return codegenFoldedConstant(expr, s)
+	}
 	switch expr := expr.(type) {
 	case *CheckedUnaryExpr:
 		return codegenUnaryExpr(expr, s)
@@ -115,10 +348,150 @@ func CodegenExpr(expr CheckedExpr, s *Scope) string {
 		return codegenAsExpr(expr, s)
 	case *CheckedMethodExpr:
 		return codegenMethodExpr(expr, s)
+	case *CheckedIfExpr:
+		return codegenIfExpr(expr, s)
+	case *CheckedInterpolatedStringExpr:
+		return codegenInterpolatedStringExpr(expr, s)
+	case *CheckedArrayLiteralExpr:
+		return codegenArrayLiteralExpr(expr, s)
+	case *CheckedIndexExpr:
+		return codegenIndexExpr(expr, s)
+	case *CheckedSliceExpr:
+		return codegenSliceExpr(expr, s)
+	case *CheckedLenExpr:
+		return codegenLenExpr(expr, s)
+	case *CheckedEnumVariantExpr:
+		return codegenEnumVariantExpr(expr, s)
+	case *CheckedFunExpr:
+		return codegenFunExpr(expr, s)
+	case *CheckedOptionalExpr:
+		return codegenOptionalExpr(expr, s)
+	case *CheckedResultExpr:
+		return codegenResultExpr(expr, s)
 	}
 	panic("unreachable")
 }
 
+func codegenOptionalExpr(expr *CheckedOptionalExpr, s *Scope) string {
+	if isPointerOptional(expr.Type, s) {
+		return CodegenExpr(expr.Value, s)
+	}
+	return fmt.Sprintf("(%s) { 1, %s }", CodegenType(expr.Type, s), CodegenExpr(expr.Value, s))
+}
+
+func isPointerOptional(id TypeId, s *Scope) bool {
+	if optionalType, isOptional := (*s.File.Types)[id].(*OptionalType); isOptional {
+		_, isPointer := (*s.File.Types)[optionalType.Elem].(*PointerType)
+		return isPointer
+	}
+	return false
+}
+
+func cOptionalTypeId(id TypeId) string {
+	return cId(fmt.Sprintf("OPTIONAL_TYPE_%d", id))
+}
+
+func codegenResultExpr(expr *CheckedResultExpr, s *Scope) string {
+	result := CodegenType(expr.Type, s)
+	if expr.Error {
+		return fmt.Sprintf("(%s) { .ok = 0, .error = %s }", result, CodegenExpr(expr.Value, s))
+	}
+	if expr.Value == nil {
+		return fmt.Sprintf("(%s) { .ok = 1 }", result)
+	}
+	if expr.Value.TypeId() == UNIT_TYPE_ID {
+		return fmt.Sprintf("(%s, (%s) { .ok = 1 })", CodegenExpr(expr.Value, s), result)
+	}
+	return fmt.Sprintf("(%s) { .ok = 1, .value = %s }", result, CodegenExpr(expr.Value, s))
+}
+
+func cResultTypeId(id TypeId) string {
+	return cId(fmt.Sprintf("RESULT_TYPE_%d", id))
+}
+
+func codegenFunExpr(expr *CheckedFunExpr, s *Scope) string {
+	if expr.Extern {
+		return expr.Name.Content
+	}
+	if len(expr.Captures) == 0 {
+		return fmt.Sprintf("(%s) { %s, NULL }", CodegenType(expr.Type, s), expr.Name.Content)
+	}
+	env := make([]string, 0, len(expr.Captures))
+	for _, capture := range expr.Captures {
+		env = append(env, fmt.Sprintf(".%s = %s", capture.Name.Content, capture.Name.Content))
+	}
+	return fmt.Sprintf("(%s) { %s, &(struct %s_env) { %s } }", CodegenType(expr.Type, s), expr.Name.Content, expr.Name.Content, strings.Join(env, ", "))
+}
+
+func codegenArrayLiteralExpr(expr *CheckedArrayLiteralExpr, s *Scope) string {
+	if len(expr.Elems) == 0 {
+		return fmt.Sprintf("(%s) { 0 }", CodegenType(expr.Type, s))
+	}
+	elems := make([]string, 0, len(expr.Elems))
+	for _, elem := range expr.Elems {
+		elems = append(elems, CodegenExpr(elem, s))
+	}
+	return fmt.Sprintf("(%s) { .data = { %s } }", CodegenType(expr.Type, s), strings.Join(elems, ", "))
+}
+
+func codegenIndexExpr(expr *CheckedIndexExpr, s *Scope) string {
+	if _, isSlice := (*s.File.Types)[expr.Object.TypeId()].(*SliceType); isSlice {
+		return fmt.Sprintf("(*%s_at(%s, %s))", cSliceTypeId(expr.Object.TypeId()), CodegenExpr(expr.Object, s), CodegenExpr(expr.Index, s))
+	}
+	if _, isPointer := (*s.File.Types)[expr.Object.TypeId()].(*PointerType); isPointer {
+		return fmt.Sprintf("(%s)[%s]", CodegenExpr(expr.Object, s), CodegenExpr(expr.Index, s))
+	}
+	arrayType := (*s.File.Types)[expr.Object.TypeId()].(*ArrayType)
+	return fmt.Sprintf("(%s).data[WALL_checkIndex(%s, %d)]", CodegenExpr(expr.Object, s), CodegenExpr(expr.Index, s), arrayType.Len)
+}
+
+func codegenSliceExpr(expr *CheckedSliceExpr, s *Scope) string {
+	slice := cSliceTypeId(expr.Type)
+	var object string
+	switch t := (*s.File.Types)[expr.Object.TypeId()].(type) {
+	case *SliceType:
+		object = CodegenExpr(expr.Object, s)
+	case *ArrayType:
+		object = fmt.Sprintf("(%s) { (%s).data, %d }", slice, CodegenExpr(expr.Object, s), t.Len)
+	case *PointerType:
+		object = fmt.Sprintf("(%s) { %s, SIZE_MAX }", slice, CodegenExpr(expr.Object, s))
+	}
+	low := "0"
+	if expr.Low != nil {
+		low = CodegenExpr(expr.Low, s)
+	}
+	if expr.High == nil {
+		return fmt.Sprintf("%s_sliceFrom(%s, %s)", slice, object, low)
+	}
+	return fmt.Sprintf("%s_slice(%s, %s, %s)", slice, object, low, CodegenExpr(expr.High, s))
+}
+
+func codegenLenExpr(expr *CheckedLenExpr, s *Scope) string {
+	if arrayType, isArray := (*s.File.Types)[expr.Object.TypeId()].(*ArrayType); isArray {
+		return fmt.Sprintf("((size_t) %d)", arrayType.Len)
+	}
+	return fmt.Sprintf("(%s).len", CodegenExpr(expr.Object, s))
+}
+
+func codegenEnumVariantExpr(expr *CheckedEnumVariantExpr, s *Scope) string {
+	enum := s.TypeToString(expr.Enum)
+	if expr.Type != expr.Enum {
+		return cEnumConstructorId(enum, expr.Variant.Content)
+	}
+	if isTaggedUnion(expr.Enum, s) {
+		return fmt.Sprintf("((%s) { .tag = %s })", enum, cEnumVariantId(enum, expr.Variant.Content))
+	}
+	return cEnumVariantId(enum, expr.Variant.Content)
+}
+
+func cEnumVariantId(enum string, variant string) string {
+	return enum + "_" + variant
+}
+
+func cEnumConstructorId(enum string, variant string) string {
+	return enum + "_new_" + variant
+}
+
 func codegenMethodExpr(expr *CheckedMethodExpr, s *Scope) string {
 	name := strings.ReplaceAll(expr.Method.Content, ".", "_")
 	return name
@@ -165,11 +538,23 @@ func codegenUnaryExpr(expr *CheckedUnaryExpr, s *Scope) string {
 		return fmt.Sprintf("&(%s)", CodegenExpr(expr.Operand, s))
 	case CHECKED_DEREF:
 		return fmt.Sprintf("*(%s)", CodegenExpr(expr.Operand, s))
+	case CHECKED_NOT:
+		return fmt.Sprintf("!(%s)", CodegenExpr(expr.Operand, s))
+	case CHECKED_BITNOT:
+		return fmt.Sprintf("(%s)~(%s)", CodegenType(expr.Type, s), CodegenExpr(expr.Operand, s))
 	}
 	panic("unreachable")
 }
 
 func codegenBinaryExpr(expr *CheckedBinaryExpr, s *Scope) string {
+	if _, isOptional := (*s.File.Types)[expr.Left.TypeId()].(*OptionalType); isOptional && !isPointerOptional(expr.Left.TypeId(), s) {
+		switch expr.Op {
+		case CHECKED_EQUALS:
+			return fmt.Sprintf("!(%s).has", CodegenExpr(expr.Left, s))
+		case CHECKED_NOTEQUALS:
+			return fmt.Sprintf("(%s).has", CodegenExpr(expr.Left, s))
+		}
+	}
 	switch expr.Op {
 	case CHECKED_ADD:
 		return fmt.Sprintf("(%s)+(%s)", CodegenExpr(expr.Left, s), CodegenExpr(expr.Right, s))
@@ -193,6 +578,42 @@ func codegenBinaryExpr(expr *CheckedBinaryExpr, s *Scope) string {
 		return fmt.Sprintf("(%s)>=(%s)", CodegenExpr(expr.Left, s), CodegenExpr(expr.Right, s))
 	case CHECKED_ASSIGN:
 		return fmt.Sprintf("(%s)=(%s)", CodegenExpr(expr.Left, s), CodegenExpr(expr.Right, s))
+	case CHECKED_AND:
+		return fmt.Sprintf("(%s)&&(%s)", CodegenExpr(expr.Left, s), CodegenExpr(expr.Right, s))
+	case CHECKED_OR:
+		return fmt.Sprintf("(%s)||(%s)", CodegenExpr(expr.Left, s), CodegenExpr(expr.Right, s))
+	case CHECKED_REMAINDER:
+		return fmt.Sprintf("(%s)%%(%s)", CodegenExpr(expr.Left, s), CodegenExpr(expr.Right, s))
+	case CHECKED_BITAND:
+		return fmt.Sprintf("(%s)&(%s)", CodegenExpr(expr.Left, s), CodegenExpr(expr.Right, s))
+	case CHECKED_BITOR:
+		return fmt.Sprintf("(%s)|(%s)", CodegenExpr(expr.Left, s), CodegenExpr(expr.Right, s))
+	case CHECKED_BITXOR:
+		return fmt.Sprintf("(%s)^(%s)", CodegenExpr(expr.Left, s), CodegenExpr(expr.Right, s))
+	case CHECKED_SHIFTLEFT:
+		return fmt.Sprintf("(%s)<<(%s)", CodegenExpr(expr.Left, s), CodegenExpr(expr.Right, s))
+	case CHECKED_SHIFTRIGHT:
+		return fmt.Sprintf("(%s)>>(%s)", CodegenExpr(expr.Left, s), CodegenExpr(expr.Right, s))
+	case CHECKED_ADD_ASSIGN:
+		return fmt.Sprintf("(%s)+=(%s)", CodegenExpr(expr.Left, s), CodegenExpr(expr.Right, s))
+	case CHECKED_SUBTRACT_ASSIGN:
+		return fmt.Sprintf("(%s)-=(%s)", CodegenExpr(expr.Left, s), CodegenExpr(expr.Right, s))
+	case CHECKED_MULTIPLY_ASSIGN:
+		return fmt.Sprintf("(%s)*=(%s)", CodegenExpr(expr.Left, s), CodegenExpr(expr.Right, s))
+	case CHECKED_DIVIDE_ASSIGN:
+		return fmt.Sprintf("(%s)/=(%s)", CodegenExpr(expr.Left, s), CodegenExpr(expr.Right, s))
+	case CHECKED_REMAINDER_ASSIGN:
+		return fmt.Sprintf("(%s)%%=(%s)", CodegenExpr(expr.Left, s), CodegenExpr(expr.Right, s))
+	case CHECKED_BITAND_ASSIGN:
+		return fmt.Sprintf("(%s)&=(%s)", CodegenExpr(expr.Left, s), CodegenExpr(expr.Right, s))
+	case CHECKED_BITOR_ASSIGN:
+		return fmt.Sprintf("(%s)|=(%s)", CodegenExpr(expr.Left, s), CodegenExpr(expr.Right, s))
+	case CHECKED_BITXOR_ASSIGN:
+		return fmt.Sprintf("(%s)^=(%s)", CodegenExpr(expr.Left, s), CodegenExpr(expr.Right, s))
+	case CHECKED_SHIFTLEFT_ASSIGN:
+		return fmt.Sprintf("(%s)<<=(%s)", CodegenExpr(expr.Left, s), CodegenExpr(expr.Right, s))
+	case CHECKED_SHIFTRIGHT_ASSIGN:
+		return fmt.Sprintf("(%s)>>=(%s)", CodegenExpr(expr.Left, s), CodegenExpr(expr.Right, s))
 	}
 	panic("unreachable")
 }
@@ -203,16 +624,10 @@ func codegenGroupedExpr(expr *CheckedGroupedExpr, s *Scope) string {
 
 func codegenLiteralExpr(expr *CheckedLiteralExpr, s *Scope) string {
 	if expr.Literal.Kind == STRING {
-		s := string(expr.Literal.Content)
-		s = strings.ReplaceAll(s, "\a", "\\a")
-		s = strings.ReplaceAll(s, "\b", "\\b")
-		s = strings.ReplaceAll(s, "\f", "\\f")
-		s = strings.ReplaceAll(s, "\r", "\\r")
-		s = strings.ReplaceAll(s, "\v", "\\v")
-		s = strings.ReplaceAll(s, "\\", "\\\\")
-		s = strings.ReplaceAll(s, "\"", "\\\"")
-		s = strings.ReplaceAll(s, "\n", "\\n")
-		return fmt.Sprintf("\"%s\"", s)
+		return cStringLiteral(expr.Literal.Content)
+	}
+	if expr.Literal.Kind == CHARACTER {
+		return cCharLiteral(expr.Literal.Content[0])
 	}
 	if expr.Literal.Kind == TRUE {
 		return "1"
@@ -220,17 +635,133 @@ func codegenLiteralExpr(expr *CheckedLiteralExpr, s *Scope) string {
 	if expr.Literal.Kind == FALSE {
 		return "0"
 	}
+	if expr.Literal.Kind == NULL {
+		if isPointerOptional(expr.Type, s) {
+			return "NULL"
+		}
+		return fmt.Sprintf("(%s) { 0 }", CodegenType(expr.Type, s))
+	}
+	if expr.Literal.Kind == INTEGER {
+		// An untyped integer checked as a float must be a float in C
+		// too, or 7 / 2 would divide as integers.
+		if expr.Type == FLOAT32_TYPE_ID || expr.Type == FLOAT64_TYPE_ID {
+			value, _ := new(big.Int).SetString(expr.Literal.Content, 0)
+			if expr.Type == FLOAT32_TYPE_ID {
+				return value.String() + ".0f"
+			}
+			return value.String() + ".0"
+		}
+		if value, ok := new(big.Int).SetString(expr.Literal.Content, 0); ok && !value.IsInt64() {
+			return expr.Literal.Content + "ull"
+		}
+		return expr.Literal.Content + integerLiteralSuffix(expr.Type)
+	}
 	return string(expr.Literal.Content)
 }
 
+// codegenFoldedConstant emits an untyped integer constant expression as the
+// single literal it folds to, so intermediate results that don't fit the
+// target type never reach C. The checker has already range-checked the value.
+func codegenFoldedConstant(expr CheckedExpr, s *Scope) string {
+	value, err := constantValue(expr)
+	if err != nil {
+		panic(err)
+	}
+	literal := &CheckedLiteralExpr{
+		Literal: Token{Kind: INTEGER, Content: new(big.Int).Abs(value).String()},
+		Type:    expr.TypeId(),
+	}
+	if value.Sign() < 0 {
+		return fmt.Sprintf("(-%s)", codegenLiteralExpr(literal, s))
+	}
+	return codegenLiteralExpr(literal, s)
+}
+
+// integerLiteralSuffix widens a C integer literal to the type it was checked
+// as. Untyped constants are retyped leaf by leaf, so without it C would fold
+// 1 << 40 in a 32-bit int before converting it to int64.
+func integerLiteralSuffix(typeId TypeId) string {
+	switch typeId {
+	case INT_TYPE_ID, INT64_TYPE_ID:
+		return "ll"
+	case UINT_TYPE_ID, UINT64_TYPE_ID:
+		return "ull"
+	case UINT32_TYPE_ID:
+		return "u"
+	}
+	return ""
+}
+
+// cStringLiteral encodes arbitrary bytes as a C string literal. Bytes without
+// a short escape are written as three-digit octal escapes, which, unlike hex
+// escapes, can't swallow the digits that follow them.
+func cStringLiteral(s string) string {
+	var builder strings.Builder
+	builder.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\n':
+			builder.WriteString("\\n")
+		case c == '\r':
+			builder.WriteString("\\r")
+		case c == '\t':
+			builder.WriteString("\\t")
+		case c == '"' || c == '\\':
+			builder.WriteByte('\\')
+			builder.WriteByte(c)
+		case c == '?' && i > 0 && s[i-1] == '?':
+			// avoid trigraphs
+			builder.WriteString("\\?")
+		case c >= ' ' && c <= '~':
+			builder.WriteByte(c)
+		default:
+			fmt.Fprintf(&builder, "\\%03o", c)
+		}
+	}
+	builder.WriteByte('"')
+	return builder.String()
+}
+
+func cCharLiteral(c byte) string {
+	switch c {
+	case 0:
+		return "'\\0'"
+	case '\n':
+		return "'\\n'"
+	case '\r':
+		return "'\\r'"
+	case '\t':
+		return "'\\t'"
+	}
+	switch {
+	case c == '\'' || c == '\\':
+		return fmt.Sprintf("'\\%c'", c)
+	case c >= ' ' && c <= '~':
+		return fmt.Sprintf("'%c'", c)
+	}
+	return fmt.Sprintf("'\\x%02x'", c)
+}
+
 func codegenCallExpr(expr *CheckedCallExpr, s *Scope) string {
 	callee := CodegenExpr(expr.Callee, s)
 	if callee == "inlineC" {
+		if literal, ok := expr.Args[0].(*CheckedLiteralExpr); ok {
+			return literal.Literal.Content
+		}
 		inlineC := CodegenExpr(expr.Args[0], s)
 		inlineC = strings.ReplaceAll(inlineC, "\"", "")
 		return inlineC
 	}
 	var builder strings.Builder
+	if expr.Indirect {
+		fmt.Fprintf(&builder, "%s_call(%s", CodegenType(expr.Callee.TypeId(), s), callee)
+		for _, arg := range expr.Args {
+			builder.WriteString(", ")
+			builder.WriteString(CodegenExpr(arg, s))
+		}
+		builder.WriteString(")")
+		return builder.String()
+	}
 	builder.WriteString(callee)
 	builder.WriteString("(")
 	if obj := objectFromMethodExpr(expr.Callee); obj != nil {
@@ -273,18 +804,98 @@ func CodegenStmt(stmt CheckedStmt, s *Scope) string {
 		return codegenIf(stmt, s)
 	case *CheckedWhile:
 		return codegenWhile(stmt, s)
+	case *CheckedFor:
+		return codegenFor(stmt, s)
 	case *CheckedBreak:
 		return "break;"
 	case *CheckedContinue:
 		return "continue;"
+	case *CheckedMatch:
+		return codegenMatch(stmt, s)
 	}
 	panic("unimplemented")
 }
 
+func codegenMatch(m *CheckedMatch, s *Scope) string {
+	enum := s.TypeToString(m.Value.TypeId())
+	tag := "_match"
+	if isTaggedUnion(m.Value.TypeId(), s) {
+		tag = "_match.tag"
+	}
+	cond := func(variant string) string {
+		return fmt.Sprintf("%s == %s", tag, cEnumVariantId(enum, variant))
+	}
+	payload := func(variant string, i int) string {
+		return fmt.Sprintf("_match.as.%s._%d", variant, i)
+	}
+	enumType, isEnum := (*s.File.Types)[m.Value.TypeId()].(*EnumType)
+	if resultType, isResult := (*s.File.Types)[m.Value.TypeId()].(*ResultType); isResult {
+		enum = CodegenType(m.Value.TypeId(), s)
+		enumType = resultVariants(resultType)
+		cond = func(variant string) string {
+			if variant == RESULT_OK {
+				return "_match.ok"
+			}
+			return "!_match.ok"
+		}
+		payload = func(variant string, i int) string {
+			if variant == RESULT_OK {
+				return "_match.value"
+			}
+			return "_match.error"
+		}
+	} else if !isEnum {
+		panic("unreachable")
+	}
+	var builder strings.Builder
+	builder.WriteString("{\n")
+	fmt.Fprintf(&builder, "%s _match = %s;\n", enum, CodegenExpr(m.Value, s))
+	for i, arm := range m.Arms {
+		if i > 0 {
+			builder.WriteString(" else ")
+		}
+		if i < len(m.Arms)-1 || m.ElseBody != nil {
+			fmt.Fprintf(&builder, "if (%s) ", cond(arm.Variant.Content))
+		}
+		builder.WriteString("{\n")
+		for j, binding := range arm.Bindings {
+			if binding.Content == "_" {
+				continue
+			}
+			typ := enumType.Payloads[arm.Variant.Content][j]
+			fmt.Fprintf(&builder, "%s %s = %s;\n", CodegenType(typ, s), binding.Content, payload(arm.Variant.Content, j))
+		}
+		builder.WriteString(codegenBlock(arm.Body, s))
+		builder.WriteString("}")
+	}
+	if m.ElseBody != nil {
+		if len(m.Arms) > 0 {
+			builder.WriteString(" else ")
+		}
+		builder.WriteString(codegenBlock(m.ElseBody, s))
+	}
+	builder.WriteString("\n}")
+	return builder.String()
+}
+
 func codegenWhile(stmt *CheckedWhile, s *Scope) string {
 	return fmt.Sprintf("while (%s) %s", CodegenExpr(stmt.Cond, s), codegenBlock(stmt.Body, s))
 }
 
+func codegenFor(stmt *CheckedFor, s *Scope) string {
+	init, cond, post := ";", "", ""
+	if stmt.Init != nil {
+		init = CodegenStmt(stmt.Init, s)
+	}
+	if stmt.Cond != nil {
+		cond = CodegenExpr(stmt.Cond, s)
+	}
+	if stmt.Post != nil {
+		post = CodegenExpr(stmt.Post, s)
+	}
+	return fmt.Sprintf("for (%s %s; %s) %s", init, cond, post, codegenBlock(stmt.Body, s))
+}
+
 func codegenVarStmt(stmt *CheckedVar, s *Scope) string {
 	val := CodegenExpr(stmt.Value, s)
 	t := CodegenType(stmt.Value.TypeId(), s)
@@ -315,6 +926,9 @@ func codegenReturn(r *CheckedReturn, s *Scope) string {
 }
 
 func codegenIf(i *CheckedIf, s *Scope) string {
+	if i.Binding != nil {
+		return codegenIfUnwrap(i, s)
+	}
 	if i.ElseBody != nil {
 		return fmt.Sprintf("if (%s) %s else %s", CodegenExpr(i.Cond, s), codegenBlock(i.Body, s), codegenBlock(i.ElseBody, s))
 	} else {
@@ -322,21 +936,80 @@ func codegenIf(i *CheckedIf, s *Scope) string {
 	}
 }
 
-func codegenFuncDefinitions(c *CheckedFile, checkedFiles map[*CheckedFile]struct{}) string {
+func codegenIfExpr(expr *CheckedIfExpr, s *Scope) string {
+	then := expr.Body.Stmts[0].(*CheckedExprStmt).Expr
+	otherwise := expr.ElseBody.Stmts[0].(*CheckedExprStmt).Expr
+	return fmt.Sprintf("((%s) ? (%s) : (%s))", CodegenExpr(expr.Cond, s), CodegenExpr(then, s), CodegenExpr(otherwise, s))
+}
+
+func codegenInterpolatedStringExpr(expr *CheckedInterpolatedStringExpr, s *Scope) string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "WALL_format(%s", CodegenExpr(expr.Format, s))
+	for _, arg := range expr.Args {
+		builder.WriteString(", ")
+		builder.WriteString(CodegenExpr(arg, s))
+	}
+	builder.WriteString(")")
+	return builder.String()
+}
+
+func codegenIfUnwrap(i *CheckedIf, s *Scope) string {
+	var elem TypeId
+	if optionalType, isOptional := (*s.File.Types)[i.Cond.TypeId()].(*OptionalType); isOptional {
+		elem = optionalType.Elem
+	}
+	cond, value := "_optional.has", "_optional.value"
+	if isPointerOptional(i.Cond.TypeId(), s) {
+		cond, value = "_optional != NULL", "_optional"
+	}
+	if resultType, isResult := (*s.File.Types)[i.Cond.TypeId()].(*ResultType); isResult {
+		elem, cond = resultType.Value, "_optional.ok"
+	}
+	var builder strings.Builder
+	builder.WriteString("{\n")
+	fmt.Fprintf(&builder, "%s _optional = %s;\n", CodegenType(i.Cond.TypeId(), s), CodegenExpr(i.Cond, s))
+	fmt.Fprintf(&builder, "if (%s) {\n", cond)
+	fmt.Fprintf(&builder, "%s %s = %s;\n", CodegenType(elem, s), i.Binding.Content, value)
+	builder.WriteString(codegenBlock(i.Body, s))
+	builder.WriteString("}")
+	if i.ElseBody != nil {
+		builder.WriteString(" else ")
+		builder.WriteString(codegenBlock(i.ElseBody, s))
+	}
+	builder.WriteString("\n}")
+	return builder.String()
+}
+
+func codegenFuncDefinitions(c *CheckedFile, root bool, s *Scope, checkedFiles map[*CheckedFile]struct{}) string {
 	if _, ok := checkedFiles[c]; ok {
 		return ""
 	}
 	checkedFiles[c] = struct{}{}
 	var builder strings.Builder
 	for _, def := range c.Funs {
-		codegenFunDef(&builder, def.Name.Content, def.Params, def.ReturnType, def.Body, c.GlobalScope)
+		body := def.Body
+		if root && def.Name.Content == "main" && hasGlobalsInit(c, make(map[*CheckedFile]struct{})) {
+			// The init assigns the globals of every module, imports first.
+			init := &CheckedExprStmt{
+				Expr: &CheckedCallExpr{
+					Callee: &CheckedIdExpr{Id: &Token{Kind: IDENTIFIER, Content: GLOBALS_INIT}, Type: UNIT_TYPE_ID},
+					Args:   []CheckedExpr{},
+					Type:   UNIT_TYPE_ID,
+				},
+			}
+			body = &CheckedBlock{Stmts: append([]CheckedStmt{init}, body.Stmts...)}
+		}
+		codegenFunDef(&builder, def.Name.Content, def.Params, def.ReturnType, body, s)
 	}
 	for _, m := range c.Methods {
 		params := appendThisToParams(m.Params, c.GlobalScope.findType(m.Typename.Content).TypeId, c.GlobalScope)
-		codegenFunDef(&builder, strings.ReplaceAll(m.Name.Content, ".", "_"), params, m.ReturnType, m.Body, c.GlobalScope)
+		codegenFunDef(&builder, strings.ReplaceAll(m.Name.Content, ".", "_"), params, m.ReturnType, m.Body, s)
+	}
+	for _, closure := range c.Closures {
+		codegenClosureDef(&builder, closure, s)
 	}
 	for _, imp := range c.Imports {
-		builder.WriteString(codegenFuncDefinitions(imp.File, checkedFiles))
+		builder.WriteString(codegenFuncDefinitions(imp.File, false, s, checkedFiles))
 	}
 	return builder.String()
 }
@@ -357,7 +1030,29 @@ func codegenFunDef(builder *strings.Builder, name string, params []CheckedFunPar
 	return builder.String()
 }
 
-func wallPrefixesToGlobalNames(c *CheckedFile, checkedFiles map[*CheckedFile]struct{}) {
+func codegenClosureDef(builder *strings.Builder, closure *CheckedFunExpr, s *Scope) {
+	name := closure.Name.Content
+	fmt.Fprintf(builder, "%s %s(", CodegenType(closure.ReturnType, s), name)
+	params := make([]string, 0, len(closure.Params)+1)
+	if !closure.Extern {
+		params = append(params, "void* _env")
+	}
+	for _, param := range closure.Params {
+		params = append(params, fmt.Sprintf("%s %s", CodegenType(param.Type, s), param.Name.Content))
+	}
+	if len(params) == 0 {
+		params = append(params, CodegenType(UNIT_TYPE_ID, s))
+	}
+	builder.WriteString(strings.Join(params, ", "))
+	builder.WriteString(") {\n")
+	for _, capture := range closure.Captures {
+		fmt.Fprintf(builder, "%s %s = ((struct %s_env*) _env)->%s;\n", CodegenType(capture.Type, s), capture.Name.Content, name, capture.Name.Content)
+	}
+	builder.WriteString(codegenBlock(closure.Body, s))
+	builder.WriteString("}\n")
+}
+
+func wallPrefixesToGlobalNames(c *CheckedFile, root bool, checkedFiles map[*CheckedFile]struct{}) {
 	if _, ok := checkedFiles[c]; ok {
 		return
 	}
@@ -368,8 +1063,14 @@ func wallPrefixesToGlobalNames(c *CheckedFile, checkedFiles map[*CheckedFile]str
 		}
 		def.Name.Content = attachWallPrefix(def.Name.Content)
 	}
+	for _, def := range c.Enums {
+		if !c.GlobalScope.findAndRenameType(def.Name.Content, attachWallPrefix(def.Name.Content)) {
+			panic(fmt.Sprintf("type not found: %s", def.Name.Content))
+		}
+		def.Name.Content = attachWallPrefix(def.Name.Content)
+	}
 	for _, def := range c.Funs {
-		if len(checkedFiles) == 1 /* this is a root module */ && def.Name.Content == "main" {
+		if root && def.Name.Content == "main" {
 			continue
 		}
 		if !c.GlobalScope.findAndRenameFun(string(def.Name.Content), attachWallPrefix(def.Name.Content)) {
@@ -390,8 +1091,14 @@ func wallPrefixesToGlobalNames(c *CheckedFile, checkedFiles map[*CheckedFile]str
 		}
 		def.Name.Content = attachWallPrefix(name)
 	}
+	for _, closure := range c.Closures {
+		closure.Name.Content = attachWallPrefix(closure.Name.Content)
+	}
+	for _, global := range c.Globals {
+		global.Name.Content = attachWallPrefix(global.Name.Content)
+	}
 	for _, imp := range c.Imports {
-		wallPrefixesToGlobalNames(imp.File, checkedFiles)
+		wallPrefixesToGlobalNames(imp.File, false, checkedFiles)
 	}
 }
 
@@ -416,7 +1123,7 @@ func renameMethods(c *CheckedFile, checkedFiles map[*CheckedFile]struct{}) {
 	}
 }
 
-func moduleNamesToGlobalNames(c *CheckedFile, checkedFiles map[*CheckedFile]struct{}) {
+func moduleNamesToGlobalNames(c *CheckedFile, root bool, checkedFiles map[*CheckedFile]struct{}) {
 	if _, ok := checkedFiles[c]; ok {
 		return
 	}
@@ -427,8 +1134,14 @@ func moduleNamesToGlobalNames(c *CheckedFile, checkedFiles map[*CheckedFile]stru
 		}
 		def.Name.Content = attachModuleName(def.Name.Content, def.Name.Filename)
 	}
+	for _, def := range c.Enums {
+		if !c.GlobalScope.findAndRenameType(def.Name.Content, attachModuleName(def.Name.Content, def.Name.Filename)) {
+			panic("type not found")
+		}
+		def.Name.Content = attachModuleName(def.Name.Content, def.Name.Filename)
+	}
 	for _, def := range c.Funs {
-		if len(checkedFiles) == 1 /* this is a root module */ && def.Name.Content == "main" {
+		if root && def.Name.Content == "main" {
 			continue
 		}
 		if !c.GlobalScope.findAndRenameFun(string(def.Name.Content), attachModuleName(def.Name.Content, def.Name.Filename)) {
@@ -449,8 +1162,14 @@ func moduleNamesToGlobalNames(c *CheckedFile, checkedFiles map[*CheckedFile]stru
 		}
 		def.Name.Content = attachModuleName(name, def.Name.Filename)
 	}
+	for _, closure := range c.Closures {
+		closure.Name.Content = attachModuleName(closure.Name.Content, closure.Name.Filename)
+	}
+	for _, global := range c.Globals {
+		global.Name.Content = attachModuleName(global.Name.Content, global.Name.Filename)
+	}
 	for _, imp := range c.Imports {
-		moduleNamesToGlobalNames(imp.File, checkedFiles)
+		moduleNamesToGlobalNames(imp.File, false, checkedFiles)
 	}
 }
 
@@ -482,6 +1201,23 @@ func codegenTypeDeclarations(c *CheckedFile, checkedFiles map[*CheckedFile]struc
 		id := string(def.Name.Content)
 		fmt.Fprintf(&builder, "typedef struct %s %s;\n", id, id)
 	}
+	for _, def := range c.Enums {
+		id := def.Name.Content
+		if isTaggedUnion(c.GlobalScope.findType(id).TypeId, c.GlobalScope) {
+			builder.WriteString("enum {\n")
+		} else {
+			fmt.Fprintf(&builder, "typedef enum %s {\n", id)
+		}
+		for _, variant := range def.Variants {
+			fmt.Fprintf(&builder, "%s = %d,\n", cEnumVariantId(id, variant.Name.Content), variant.Value)
+		}
+		if isTaggedUnion(c.GlobalScope.findType(id).TypeId, c.GlobalScope) {
+			builder.WriteString("};\n")
+			fmt.Fprintf(&builder, "typedef struct %s %s;\n", id, id)
+		} else {
+			fmt.Fprintf(&builder, "} %s;\n", id)
+		}
+	}
 	for range c.Typealiases {
 		panic("can't codegen typealiases")
 	}
@@ -491,21 +1227,36 @@ func codegenTypeDeclarations(c *CheckedFile, checkedFiles map[*CheckedFile]struc
 	return builder.String()
 }
 
-func codegenFuncDeclarations(c *CheckedFile, checkedFiles map[*CheckedFile]struct{}) string {
+func codegenFuncDeclarations(c *CheckedFile, s *Scope, checkedFiles map[*CheckedFile]struct{}) string {
 	if _, ok := checkedFiles[c]; ok {
 		return ""
 	}
 	checkedFiles[c] = struct{}{}
 	var builder strings.Builder
 	for _, def := range c.Funs {
-		codegenFunDecl(&builder, def.Name.Content, def.Params, def.ReturnType, c)
+		codegenFunDecl(&builder, def.Name.Content, def.Params, def.ReturnType, s)
 	}
 	for _, m := range c.Methods {
 		params := appendThisToParams(m.Params, c.GlobalScope.findType(m.Typename.Content).TypeId, c.GlobalScope)
-		codegenFunDecl(&builder, strings.ReplaceAll(m.Name.Content, ".", "_"), params, m.ReturnType, c)
+		codegenFunDecl(&builder, strings.ReplaceAll(m.Name.Content, ".", "_"), params, m.ReturnType, s)
+	}
+	for _, closure := range c.Closures {
+		fmt.Fprintf(&builder, "%s %s(", CodegenType(closure.ReturnType, s), closure.Name.Content)
+		params := make([]string, 0, len(closure.Params)+1)
+		if !closure.Extern {
+			params = append(params, "void*")
+		}
+		for _, param := range closure.Params {
+			params = append(params, CodegenType(param.Type, s))
+		}
+		if len(params) == 0 {
+			params = append(params, CodegenType(UNIT_TYPE_ID, s))
+		}
+		builder.WriteString(strings.Join(params, ", "))
+		builder.WriteString(");\n")
 	}
 	for _, imp := range c.Imports {
-		builder.WriteString(codegenFuncDeclarations(imp.File, checkedFiles))
+		builder.WriteString(codegenFuncDeclarations(imp.File, s, checkedFiles))
 	}
 	return builder.String()
 }
@@ -518,13 +1269,13 @@ func appendThisToParams(params []CheckedFunParam, thisType TypeId, s *Scope) []C
 	return append([]CheckedFunParam{thisParam}, params...)
 }
 
-func codegenFunDecl(builder *strings.Builder, name string, params []CheckedFunParam, returnType TypeId, c *CheckedFile) {
-	fmt.Fprintf(builder, "%s %s(", CodegenType(returnType, c.GlobalScope), name)
+func codegenFunDecl(builder *strings.Builder, name string, params []CheckedFunParam, returnType TypeId, s *Scope) {
+	fmt.Fprintf(builder, "%s %s(", CodegenType(returnType, s), name)
 	if len(params) == 0 {
-		builder.WriteString(CodegenType(UNIT_TYPE_ID, c.GlobalScope))
+		builder.WriteString(CodegenType(UNIT_TYPE_ID, s))
 	}
 	for i, param := range params {
-		fmt.Fprintf(builder, "%s", CodegenType(param.Type, c.GlobalScope))
+		fmt.Fprintf(builder, "%s", CodegenType(param.Type, s))
 		if i < len(params)-1 {
 			builder.WriteString(", ")
 		}
@@ -532,19 +1283,82 @@ func codegenFunDecl(builder *strings.Builder, name string, params []CheckedFunPa
 	builder.WriteString(");\n")
 }
 
-func codegenTypeDefinitions(c *CheckedFile, checkedFiles map[*CheckedFile]struct{}) string {
+func collectStructDefs(c *CheckedFile, structs map[TypeId]*CheckedStructDef, checkedFiles map[*CheckedFile]struct{}) []TypeId {
 	if _, ok := checkedFiles[c]; ok {
-		return ""
+		return nil
 	}
 	checkedFiles[c] = struct{}{}
-	var builder strings.Builder
+	order := make([]TypeId, 0, len(c.Structs))
 	for _, imp := range c.Imports {
-		builder.WriteString(codegenTypeDefinitions(imp.File, checkedFiles))
+		order = append(order, collectStructDefs(imp.File, structs, checkedFiles)...)
 	}
 	for _, def := range c.Structs {
-		builder.WriteString(CodegenStructDef(string(def.Name.Content), def.Fields, c.GlobalScope))
+		id := c.GlobalScope.findType(def.Name.Content).TypeId
+		structs[id] = def
+		order = append(order, id)
+	}
+	return order
+}
+
+func codegenTypeDefinition(builder *strings.Builder, id TypeId, structs map[TypeId]*CheckedStructDef, defined map[TypeId]struct{}, s *Scope) {
+	if _, ok := defined[id]; ok {
+		return
+	}
+	switch t := (*s.File.Types)[id].(type) {
+	case *StructType:
+		def, ok := structs[id]
+		if !ok {
+			return
+		}
+		defined[id] = struct{}{}
+		for _, field := range def.Fields {
+			codegenTypeDefinition(builder, field.Type, structs, defined, s)
+		}
+		builder.WriteString(CodegenStructDef(string(def.Name.Content), def.Fields, s))
+	case *ArrayType:
+		defined[id] = struct{}{}
+		codegenTypeDefinition(builder, t.Elem, structs, defined, s)
+		fmt.Fprintf(builder, "struct %s {\n%s data[%d];\n};\n", cArrayTypeId(id), CodegenType(t.Elem, s), t.Len)
+	case *SliceType:
+		defined[id] = struct{}{}
+		fmt.Fprintf(builder, "struct %s {\n%s* ptr;\nsize_t len;\n};\n", cSliceTypeId(id), CodegenType(t.Elem, s))
+	case *OptionalType:
+		if isPointerOptional(id, s) {
+			return
+		}
+		defined[id] = struct{}{}
+		codegenTypeDefinition(builder, t.Elem, structs, defined, s)
+		fmt.Fprintf(builder, "struct %s {\n%s has;\n%s value;\n};\n", cOptionalTypeId(id), CodegenType(BOOL_TYPE_ID, s), CodegenType(t.Elem, s))
+	case *ResultType:
+		defined[id] = struct{}{}
+		codegenTypeDefinition(builder, t.Value, structs, defined, s)
+		codegenTypeDefinition(builder, t.Error, structs, defined, s)
+		fmt.Fprintf(builder, "struct %s {\n%s ok;\n", cResultTypeId(id), CodegenType(BOOL_TYPE_ID, s))
+		if t.Value != UNIT_TYPE_ID {
+			fmt.Fprintf(builder, "%s value;\n", CodegenType(t.Value, s))
+		}
+		fmt.Fprintf(builder, "%s error;\n};\n", CodegenType(t.Error, s))
+	case *EnumType:
+		if len(t.Payloads) == 0 {
+			return
+		}
+		defined[id] = struct{}{}
+		variants := payloadVariants(t)
+		for _, variant := range variants {
+			for _, typ := range t.Payloads[variant] {
+				codegenTypeDefinition(builder, typ, structs, defined, s)
+			}
+		}
+		fmt.Fprintf(builder, "struct %s {\nint32_t tag;\nunion {\n", s.TypeToString(id))
+		for _, variant := range variants {
+			builder.WriteString("struct {\n")
+			for i, typ := range t.Payloads[variant] {
+				fmt.Fprintf(builder, "%s _%d;\n", CodegenType(typ, s), i)
+			}
+			fmt.Fprintf(builder, "} %s;\n", variant)
+		}
+		builder.WriteString("} as;\n};\n")
 	}
-	return builder.String()
 }
 
 func CodegenStructDef(id string, fields []CheckedStructField, s *Scope) string {
@@ -594,9 +1408,25 @@ func CodegenType(id TypeId, s *Scope) string {
 			panic("unreachable")
 		}
 	case *StructType:
+		if t.Instance != nil {
+			return t.Instance.Name.Content
+		}
+		return s.TypeToString(id)
+	case *EnumType:
 		return s.TypeToString(id)
 	case *PointerType:
 		return CodegenType(t.Type, s) + "*"
+	case *ArrayType:
+		return cArrayTypeId(id)
+	case *SliceType:
+		return cSliceTypeId(id)
+	case *OptionalType:
+		if isPointerOptional(id, s) {
+			return CodegenType(t.Elem, s)
+		}
+		return cOptionalTypeId(id)
+	case *ResultType:
+		return cResultTypeId(id)
 	case *FunctionType:
 		return cFuncTypeId(int(id), s.File.Filename)
 	case *MethodType:
@@ -212,13 +212,21 @@ func (e *Evaluator) evaluateLiteralExpr(b *ParsedLiteralExpr) (EvalObject, error
 		}
 		return nil, NewError(b.Token.Pos, "undeclared name: %s", b.Token.Content)
 	case INTEGER:
-		val, err := strconv.ParseInt(string(b.Token.Content), 10, 64)
+		content, _, err := parseNumericLiteral(b.Token)
+		if err != nil {
+			return nil, err
+		}
+		val, err := strconv.ParseInt(content, 10, 64)
 		if err != nil {
 			return nil, err
 		}
 		return &IntObject{Value: val}, nil
 	case FLOAT:
-		val, err := strconv.ParseFloat(string(b.Token.Content), 64)
+		content, _, err := parseNumericLiteral(b.Token)
+		if err != nil {
+			return nil, err
+		}
+		val, err := strconv.ParseFloat(content, 64)
 		if err != nil {
 			return nil, err
 		}
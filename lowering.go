@@ -1,6 +1,7 @@
 package wall
 
 import (
+	"fmt"
 	"strings"
 )
 
@@ -67,3 +68,749 @@ func lowerExternFunctions(c *CheckedFile, checked map[*CheckedFile]struct{}) {
 		lowerExternFunctions(imp.File, checked)
 	}
 }
+
+// LowerTemporaries moves the expressions that C can only write as statements
+// into temporaries, computed by statements inserted before the statement that
+// uses them. Interpolated strings are freed by statements inserted after it.
+func LowerTemporaries(c *CheckedFile) {
+	lowerTemporaries(c, make(map[*CheckedFile]struct{}))
+}
+
+func lowerTemporaries(c *CheckedFile, checked map[*CheckedFile]struct{}) {
+	if _, ok := checked[c]; ok {
+		return
+	}
+	checked[c] = struct{}{}
+	for _, f := range c.Funs {
+		(&temporaries{file: c}).lowerBlock(f.Body, nil)
+	}
+	for _, m := range c.Methods {
+		(&temporaries{file: c}).lowerBlock(m.Body, nil)
+	}
+	for _, closure := range c.Closures {
+		(&temporaries{file: c}).lowerBlock(closure.Body, nil)
+	}
+	for _, imp := range c.Imports {
+		lowerTemporaries(imp.File, checked)
+	}
+}
+
+// temporaries numbers the temporaries of a function body, so that the ones
+// declared in nested blocks never shadow each other.
+type temporaries struct {
+	file  *CheckedFile
+	count int
+}
+
+// hoisted collects the statements that run before and after a statement
+// while lowering its expressions. The operands and branches that only run
+// conditionally collect theirs in a hoisted nested in the statement's one.
+type hoisted struct {
+	pre   []CheckedStmt
+	post  []CheckedStmt
+	outer *hoisted
+}
+
+// pending returns the statements that must still run when a statement
+// returns early: its own post statements and those of every outer statement.
+func (h *hoisted) pending() []CheckedStmt {
+	var stmts []CheckedStmt
+	for ; h != nil; h = h.outer {
+		stmts = append(stmts, h.post...)
+	}
+	return stmts
+}
+
+func (t *temporaries) declare(prefix string, value CheckedExpr) (*CheckedVar, *CheckedIdExpr) {
+	name := &Token{Kind: IDENTIFIER, Content: fmt.Sprintf("_%s%d", prefix, t.count)}
+	t.count++
+	return &CheckedVar{Name: name, Value: value}, &CheckedIdExpr{Id: name, Type: value.TypeId()}
+}
+
+// evaluate moves expr into a temporary if the statements after it have to
+// run before the statement that uses it, e.g. the condition of an if.
+func (t *temporaries) evaluate(expr CheckedExpr, h *hoisted) CheckedExpr {
+	if len(h.post) == 0 {
+		return expr
+	}
+	value, temp := t.declare("cond", expr)
+	h.pre = append(append(h.pre, value), h.post...)
+	h.post = nil
+	return temp
+}
+
+func (t *temporaries) lowerBlock(b *CheckedBlock, outer *hoisted) {
+	stmts := make([]CheckedStmt, 0, len(b.Stmts))
+	for _, stmt := range b.Stmts {
+		stmts = append(stmts, t.lowerStmt(stmt, outer)...)
+	}
+	b.Stmts = stmts
+}
+
+func (t *temporaries) lowerStmt(stmt CheckedStmt, outer *hoisted) []CheckedStmt {
+	h := &hoisted{outer: outer}
+	switch stmt := stmt.(type) {
+	case *CheckedVar:
+		stmt.Value = t.lowerExpr(stmt.Value, h)
+	case *CheckedExprStmt:
+		if ifExpr, isIfExpr := stmt.Expr.(*CheckedIfExpr); isIfExpr {
+			return t.lowerStmt(&CheckedIf{
+				Binding:  ifExpr.Binding,
+				Cond:     ifExpr.Cond,
+				Body:     ifExpr.Body,
+				ElseBody: ifExpr.ElseBody,
+			}, outer)
+		}
+		stmt.Expr = t.lowerExpr(stmt.Expr, h)
+		if stmt.Expr == nil {
+			return append(h.pre, h.post...)
+		}
+	case *CheckedReturn:
+		stmt.Value = t.lowerExpr(stmt.Value, h)
+		if stmt.Value != nil && len(h.post) > 0 {
+			result, value := t.declare("result", stmt.Value)
+			h.pre = append(h.pre, result)
+			stmt.Value = value
+		}
+		return append(append(h.pre, h.pending()...), stmt)
+	case *CheckedBlock:
+		t.lowerBlock(stmt, outer)
+	case *CheckedIf:
+		stmt.Cond = t.evaluate(t.lowerExpr(stmt.Cond, h), h)
+		t.lowerBlock(stmt.Body, outer)
+		if stmt.ElseBody != nil {
+			t.lowerBlock(stmt.ElseBody, outer)
+		}
+	case *CheckedMatch:
+		stmt.Value = t.evaluate(t.lowerExpr(stmt.Value, h), h)
+		for _, arm := range stmt.Arms {
+			t.lowerBlock(arm.Body, outer)
+		}
+		if stmt.ElseBody != nil {
+			t.lowerBlock(stmt.ElseBody, outer)
+		}
+	case *CheckedWhile:
+		cond := &hoisted{outer: outer}
+		stmt.Cond = t.evaluate(t.lowerExpr(stmt.Cond, cond), cond)
+		t.lowerBlock(stmt.Body, outer)
+		if len(cond.pre) > 0 {
+			// The condition is checked at the top of the body instead, so
+			// that its statements run on every iteration.
+			stmt.Body.Stmts = append(append(cond.pre, breakUnless(stmt.Cond)), stmt.Body.Stmts...)
+			stmt.Cond = &CheckedLiteralExpr{Literal: Token{Kind: TRUE, Content: "true"}, Type: BOOL_TYPE_ID}
+		}
+	case *CheckedFor:
+		return t.lowerFor(stmt, outer)
+	case *CheckedDefer:
+		if stmts := t.lowerStmt(stmt.Stmt, outer); len(stmts) == 1 {
+			stmt.Stmt = stmts[0]
+		} else {
+			stmt.Stmt = &CheckedBlock{Stmts: stmts}
+		}
+	}
+	return append(append(h.pre, stmt), h.post...)
+}
+
+func (t *temporaries) lowerFor(stmt *CheckedFor, outer *hoisted) []CheckedStmt {
+	var init, post, top []CheckedStmt
+	if stmt.Init != nil {
+		init = t.lowerStmt(stmt.Init, outer)
+	}
+	cond := &hoisted{outer: outer}
+	stmt.Cond = t.evaluate(t.lowerExpr(stmt.Cond, cond), cond)
+	if stmt.Post != nil {
+		post = t.lowerStmt(&CheckedExprStmt{Expr: stmt.Post}, outer)
+	}
+	t.lowerBlock(stmt.Body, outer)
+	stmt.Post = nil
+	if len(post) == 1 {
+		if exprStmt, isExprStmt := post[0].(*CheckedExprStmt); isExprStmt {
+			stmt.Post, post = exprStmt.Expr, nil
+		}
+	}
+	if len(post) > 0 {
+		// The post statement runs at the top of every iteration but the
+		// first, where continue still reaches it, followed by the condition.
+		step, stepped := t.declare("step", &CheckedLiteralExpr{Literal: Token{Kind: FALSE, Content: "false"}, Type: BOOL_TYPE_ID})
+		init = append(init, step)
+		top = append(top, &CheckedIf{
+			Cond: stepped,
+			Body: &CheckedBlock{Stmts: post},
+		}, &CheckedExprStmt{
+			Expr: assign(stepped, &CheckedLiteralExpr{Literal: Token{Kind: TRUE, Content: "true"}, Type: BOOL_TYPE_ID}),
+		})
+	}
+	if stmt.Cond != nil && (len(cond.pre) > 0 || len(top) > 0) {
+		top = append(append(top, cond.pre...), breakUnless(stmt.Cond))
+		stmt.Cond = nil
+	}
+	stmt.Body.Stmts = append(top, stmt.Body.Stmts...)
+	if len(init) <= 1 {
+		stmt.Init = nil
+		if len(init) == 1 {
+			stmt.Init = init[0]
+		}
+		return []CheckedStmt{stmt}
+	}
+	stmt.Init = nil
+	return []CheckedStmt{&CheckedBlock{Stmts: append(init, stmt)}}
+}
+
+// lowerExpr returns expr with every part that needs statements replaced by a
+// temporary, and adds the statements computing them to h.
+func (t *temporaries) lowerExpr(expr CheckedExpr, h *hoisted) CheckedExpr {
+	lower := func(expr CheckedExpr) CheckedExpr {
+		return t.lowerExpr(expr, h)
+	}
+	switch expr := expr.(type) {
+	case *CheckedIfExpr:
+		return t.lowerIfExpr(expr, h)
+	case *CheckedBinaryExpr:
+		expr.Left = lower(expr.Left)
+		if expr.Op == CHECKED_AND || expr.Op == CHECKED_OR {
+			return t.lowerShortCircuit(expr, h)
+		}
+		expr.Right = lower(expr.Right)
+	case *CheckedUnaryExpr:
+		expr.Operand = lower(expr.Operand)
+	case *CheckedGroupedExpr:
+		expr.Inner = lower(expr.Inner)
+	case *CheckedCallExpr:
+		expr.Callee = lower(expr.Callee)
+		for i, arg := range expr.Args {
+			expr.Args[i] = lower(arg)
+		}
+	case *CheckedStructInitExpr:
+		for i, field := range expr.Fields {
+			expr.Fields[i].Value = lower(field.Value)
+		}
+	case *CheckedMemberAccessExpr:
+		expr.Object = lower(expr.Object)
+	case *CheckedAsExpr:
+		expr.Value = lower(expr.Value)
+	case *CheckedMethodExpr:
+		expr.Object = lower(expr.Object)
+	case *CheckedArrayLiteralExpr:
+		for i, elem := range expr.Elems {
+			expr.Elems[i] = lower(elem)
+		}
+	case *CheckedIndexExpr:
+		expr.Object = lower(expr.Object)
+		expr.Index = lower(expr.Index)
+	case *CheckedSliceExpr:
+		expr.Object = lower(expr.Object)
+		expr.Low = lower(expr.Low)
+		expr.High = lower(expr.High)
+	case *CheckedLenExpr:
+		expr.Object = lower(expr.Object)
+	case *CheckedOverloadedAssignExpr:
+		// The left side is evaluated once, into a pointer that the method
+		// call reads and the assignment writes through.
+		pointer, temp := t.declare("lhs", &CheckedUnaryExpr{Operator: CHECKED_ADDRESS, Operand: lower(expr.Left), Type: expr.Temp.Type})
+		h.pre = append(h.pre, pointer)
+		expr.Temp.Id = temp.Id
+		return lower(expr.Value)
+	case *CheckedOptionalExpr:
+		expr.Value = lower(expr.Value)
+	case *CheckedResultExpr:
+		expr.Value = lower(expr.Value)
+	case *CheckedPropagateExpr:
+		return t.lowerPropagateExpr(expr, h)
+	case *CheckedInterpolatedStringExpr:
+		for i, arg := range expr.Args {
+			expr.Args[i] = lower(arg)
+		}
+		// The string is formatted into a temporary that's freed once the
+		// statement using it is done.
+		str, temp := t.declare("str", expr)
+		h.pre = append(h.pre, str)
+		h.post = append(h.post, &CheckedExprStmt{
+			Expr: &CheckedCallExpr{
+				Callee: &CheckedIdExpr{
+					Id:   &Token{Kind: IDENTIFIER, Content: "free"},
+					Type: t.file.TypeId(&FunctionType{Params: []TypeId{temp.Type}, Returns: UNIT_TYPE_ID, Extern: true}),
+				},
+				Args: []CheckedExpr{temp},
+				Type: UNIT_TYPE_ID,
+			},
+		})
+		return temp
+	}
+	return expr
+}
+
+// lowerIfExpr keeps an if expression that C can write as a conditional
+// expression, and otherwise declares a temporary that each branch assigns.
+func (t *temporaries) lowerIfExpr(expr *CheckedIfExpr, h *hoisted) CheckedExpr {
+	expr.Cond = t.lowerExpr(expr.Cond, h)
+	if isConditionalExpr(expr) {
+		return expr
+	}
+	// A null of any type is generated as its zero value.
+	result, value := t.declare("if", &CheckedLiteralExpr{Literal: Token{Kind: NULL}, Type: expr.Type})
+	branch := func(b *CheckedBlock) *CheckedBlock {
+		last := len(b.Stmts) - 1
+		stmts := append([]CheckedStmt{}, b.Stmts[:last]...)
+		branch := &CheckedBlock{
+			Stmts: append(stmts, &CheckedExprStmt{Expr: assign(value, b.Stmts[last].(*CheckedExprStmt).Expr)}),
+		}
+		t.lowerBlock(branch, &hoisted{outer: h})
+		return branch
+	}
+	h.pre = append(h.pre, result, &CheckedIf{
+		Binding:  expr.Binding,
+		Cond:     expr.Cond,
+		Body:     branch(expr.Body),
+		ElseBody: branch(expr.ElseBody),
+	})
+	return value
+}
+
+// lowerPropagateExpr unwraps a result into a temporary, returning its error
+// if there is one. The return is an ordinary statement, so LowerDefers runs
+// the pending defers before it. A unit value lowers to no expression at all.
+func (t *temporaries) lowerPropagateExpr(expr *CheckedPropagateExpr, h *hoisted) CheckedExpr {
+	result, value := t.declare("try", t.lowerExpr(expr.Value, h))
+	resultType := (*t.file.Types)[value.Type].(*ResultType)
+	h.pre = append(h.pre, result, &CheckedIf{
+		Cond: &CheckedUnaryExpr{
+			Operator: CHECKED_NOT,
+			Operand:  &CheckedMemberAccessExpr{Object: value, Member: Token{Kind: IDENTIFIER, Content: "ok"}, Type: BOOL_TYPE_ID},
+			Type:     BOOL_TYPE_ID,
+		},
+		Body: &CheckedBlock{Stmts: append(h.pending(), &CheckedReturn{
+			Value: &CheckedResultExpr{
+				Value: &CheckedMemberAccessExpr{Object: value, Member: Token{Kind: IDENTIFIER, Content: "error"}, Type: resultType.Error},
+				Error: true,
+				Type:  expr.Returns,
+			},
+		})},
+	})
+	if expr.Type == UNIT_TYPE_ID {
+		return nil
+	}
+	return &CheckedMemberAccessExpr{Object: value, Member: Token{Kind: IDENTIFIER, Content: "value"}, Type: expr.Type}
+}
+
+// lowerShortCircuit keeps the right operand of && and || from running when
+// the left one decides the result, even if the right one needs statements.
+func (t *temporaries) lowerShortCircuit(expr *CheckedBinaryExpr, h *hoisted) CheckedExpr {
+	right := &hoisted{outer: h}
+	value := t.lowerExpr(expr.Right, right)
+	if len(right.pre) == 0 {
+		expr.Right = value
+		return expr
+	}
+	result, cond := t.declare("cond", expr.Left)
+	var evaluate CheckedExpr = cond
+	if expr.Op == CHECKED_OR {
+		evaluate = &CheckedUnaryExpr{Operator: CHECKED_NOT, Operand: cond, Type: BOOL_TYPE_ID}
+	}
+	h.pre = append(h.pre, result, &CheckedIf{
+		Cond: evaluate,
+		Body: &CheckedBlock{Stmts: append(append(right.pre, &CheckedExprStmt{Expr: assign(cond, value)}), right.post...)},
+	})
+	return cond
+}
+
+// isConditionalExpr reports whether an if expression can be generated as a
+// C conditional expression: both branches are a single expression that
+// doesn't need statements of its own.
+func isConditionalExpr(expr *CheckedIfExpr) bool {
+	if expr.Binding != nil || len(expr.Body.Stmts) != 1 || len(expr.ElseBody.Stmts) != 1 {
+		return false
+	}
+	return !needsStmts(expr.Body.Stmts[0].(*CheckedExprStmt).Expr) && !needsStmts(expr.ElseBody.Stmts[0].(*CheckedExprStmt).Expr)
+}
+
+func needsStmts(expr CheckedExpr) bool {
+	needs := false
+	walkExpr(expr, func(expr CheckedExpr) {
+		switch expr := expr.(type) {
+		case *CheckedIfExpr:
+			needs = needs || !isConditionalExpr(expr)
+		case *CheckedPropagateExpr, *CheckedOverloadedAssignExpr, *CheckedInterpolatedStringExpr:
+			needs = true
+		}
+	})
+	return needs
+}
+
+func assign(to *CheckedIdExpr, value CheckedExpr) *CheckedBinaryExpr {
+	return &CheckedBinaryExpr{Left: to, Op: CHECKED_ASSIGN, Right: value, Type: to.Type}
+}
+
+func breakUnless(cond CheckedExpr) *CheckedIf {
+	return &CheckedIf{
+		Cond: &CheckedUnaryExpr{Operator: CHECKED_NOT, Operand: cond, Type: BOOL_TYPE_ID},
+		Body: &CheckedBlock{Stmts: []CheckedStmt{&CheckedBreak{}}},
+	}
+}
+
+func LowerDefers(c *CheckedFile) {
+	lowerDefers(c, make(map[*CheckedFile]struct{}))
+}
+
+func lowerDefers(c *CheckedFile, checked map[*CheckedFile]struct{}) {
+	if _, ok := checked[c]; ok {
+		return
+	}
+	checked[c] = struct{}{}
+	for _, f := range c.Funs {
+		lowerDefersInBlock(f.Body, nil, false)
+	}
+	for _, m := range c.Methods {
+		lowerDefersInBlock(m.Body, nil, false)
+	}
+	for _, closure := range c.Closures {
+		lowerDefersInBlock(closure.Body, nil, false)
+	}
+	for _, imp := range c.Imports {
+		lowerDefers(imp.File, checked)
+	}
+}
+
+type deferFrame struct {
+	defers []CheckedStmt
+	loop   bool
+}
+
+func lowerDefersInBlock(b *CheckedBlock, frames []*deferFrame, loop bool) {
+	frame := &deferFrame{loop: loop}
+	frames = append(frames, frame)
+	stmts := make([]CheckedStmt, 0, len(b.Stmts))
+	exits := false
+	for _, stmt := range b.Stmts {
+		switch stmt := stmt.(type) {
+		case *CheckedDefer:
+			frame.defers = append(frame.defers, lowerDefersInStmt(stmt.Stmt, nil))
+			continue
+		case *CheckedReturn, *CheckedBreak, *CheckedContinue:
+			exits = true
+		}
+		stmts = append(stmts, lowerDefersInStmt(stmt, frames))
+	}
+	if !exits {
+		stmts = append(stmts, unwindDefers(frames[len(frames)-1:])...)
+	}
+	b.Stmts = stmts
+}
+
+func lowerDefersInStmt(stmt CheckedStmt, frames []*deferFrame) CheckedStmt {
+	switch stmt := stmt.(type) {
+	case *CheckedBlock:
+		lowerDefersInBlock(stmt, frames, false)
+	case *CheckedIf:
+		lowerDefersInBlock(stmt.Body, frames, false)
+		if stmt.ElseBody != nil {
+			lowerDefersInBlock(stmt.ElseBody, frames, false)
+		}
+	case *CheckedWhile:
+		lowerDefersInBlock(stmt.Body, frames, true)
+	case *CheckedFor:
+		lowerDefersInBlock(stmt.Body, frames, true)
+	case *CheckedMatch:
+		for _, arm := range stmt.Arms {
+			lowerDefersInBlock(arm.Body, frames, false)
+		}
+		if stmt.ElseBody != nil {
+			lowerDefersInBlock(stmt.ElseBody, frames, false)
+		}
+	case *CheckedReturn:
+		defers := unwindDefers(frames)
+		if len(defers) == 0 {
+			return stmt
+		}
+		if stmt.Value == nil {
+			return &CheckedBlock{
+				Stmts: append(defers, stmt),
+			}
+		}
+		if stmt.Value.TypeId() == UNIT_TYPE_ID {
+			return &CheckedBlock{
+				Stmts: append(append([]CheckedStmt{&CheckedExprStmt{Expr: stmt.Value}}, defers...), &CheckedReturn{}),
+			}
+		}
+		result := &Token{Kind: IDENTIFIER, Content: "_result"}
+		return &CheckedBlock{
+			Stmts: append(append([]CheckedStmt{&CheckedVar{Name: result, Value: stmt.Value}}, defers...), &CheckedReturn{
+				Value: &CheckedIdExpr{
+					Id:   result,
+					Type: stmt.Value.TypeId(),
+				},
+			}),
+		}
+	case *CheckedBreak, *CheckedContinue:
+		i := len(frames) - 1
+		for i > 0 && !frames[i].loop {
+			i--
+		}
+		defers := unwindDefers(frames[i:])
+		if len(defers) == 0 {
+			return stmt
+		}
+		return &CheckedBlock{
+			Stmts: append(defers, stmt),
+		}
+	}
+	return stmt
+}
+
+func unwindDefers(frames []*deferFrame) []CheckedStmt {
+	defers := make([]CheckedStmt, 0)
+	for i := len(frames) - 1; i >= 0; i-- {
+		for j := len(frames[i].defers) - 1; j >= 0; j-- {
+			defers = append(defers, frames[i].defers[j])
+		}
+	}
+	return defers
+}
+
+func stmtExprs(stmt CheckedStmt) []CheckedExpr {
+	switch stmt := stmt.(type) {
+	case *CheckedVar:
+		return []CheckedExpr{stmt.Value}
+	case *CheckedExprStmt:
+		return []CheckedExpr{stmt.Expr}
+	case *CheckedReturn:
+		return []CheckedExpr{stmt.Value}
+	case *CheckedIf:
+		return []CheckedExpr{stmt.Cond}
+	case *CheckedWhile:
+		return []CheckedExpr{stmt.Cond}
+	case *CheckedFor:
+		return append(stmtExprs(stmt.Init), stmt.Cond, stmt.Post)
+	case *CheckedMatch:
+		return []CheckedExpr{stmt.Value}
+	}
+	return nil
+}
+
+func walkExpr(expr CheckedExpr, visit func(CheckedExpr)) {
+	if expr == nil {
+		return
+	}
+	visit(expr)
+	switch expr := expr.(type) {
+	case *CheckedUnaryExpr:
+		walkExpr(expr.Operand, visit)
+	case *CheckedBinaryExpr:
+		walkExpr(expr.Left, visit)
+		walkExpr(expr.Right, visit)
+	case *CheckedGroupedExpr:
+		walkExpr(expr.Inner, visit)
+	case *CheckedCallExpr:
+		walkExpr(expr.Callee, visit)
+		for _, arg := range expr.Args {
+			walkExpr(arg, visit)
+		}
+	case *CheckedStructInitExpr:
+		for _, field := range expr.Fields {
+			walkExpr(field.Value, visit)
+		}
+	case *CheckedMemberAccessExpr:
+		walkExpr(expr.Object, visit)
+	case *CheckedAsExpr:
+		walkExpr(expr.Value, visit)
+	case *CheckedMethodExpr:
+		walkExpr(expr.Object, visit)
+	case *CheckedArrayLiteralExpr:
+		for _, elem := range expr.Elems {
+			walkExpr(elem, visit)
+		}
+	case *CheckedIfExpr:
+		walkExpr(expr.Cond, visit)
+	case *CheckedIndexExpr:
+		walkExpr(expr.Object, visit)
+		walkExpr(expr.Index, visit)
+	case *CheckedSliceExpr:
+		walkExpr(expr.Object, visit)
+		walkExpr(expr.Low, visit)
+		walkExpr(expr.High, visit)
+	case *CheckedLenExpr:
+		walkExpr(expr.Object, visit)
+	case *CheckedOverloadedAssignExpr:
+		walkExpr(expr.Left, visit)
+		walkExpr(expr.Value, visit)
+	case *CheckedOptionalExpr:
+		walkExpr(expr.Value, visit)
+	case *CheckedResultExpr:
+		walkExpr(expr.Value, visit)
+	case *CheckedPropagateExpr:
+		walkExpr(expr.Value, visit)
+	case *CheckedInterpolatedStringExpr:
+		walkExpr(expr.Format, visit)
+		for _, arg := range expr.Args {
+			walkExpr(arg, visit)
+		}
+	}
+}
+
+// CheckClosureEnvs rejects closures whose captured environment could outlive
+// the block that creates it. Environments live on the stack, since nothing
+// would ever free one on the heap.
+func CheckClosureEnvs(c *CheckedFile) error {
+	return checkClosureEnvs(c, make(map[*CheckedFile]struct{}))
+}
+
+func checkClosureEnvs(c *CheckedFile, checked map[*CheckedFile]struct{}) error {
+	if _, ok := checked[c]; ok {
+		return nil
+	}
+	checked[c] = struct{}{}
+	captured := make(map[*Token]struct{})
+	for _, closure := range c.Closures {
+		for _, capture := range closure.Captures {
+			captured[capture.Name] = struct{}{}
+		}
+	}
+	funs := make(map[*Token]*CheckedFunDef)
+	for _, f := range c.Funs {
+		funs[f.Name] = f
+	}
+	bodies := make([]*CheckedBlock, 0, len(c.Funs)+len(c.Methods)+len(c.Closures))
+	for _, f := range c.Funs {
+		bodies = append(bodies, f.Body)
+	}
+	for _, m := range c.Methods {
+		bodies = append(bodies, m.Body)
+	}
+	for _, closure := range c.Closures {
+		bodies = append(bodies, closure.Body)
+	}
+	onlyCalled := make(map[*CheckedFunExpr]struct{})
+	for _, body := range bodies {
+		findOnlyCalledClosures(body, funs, captured, onlyCalled)
+	}
+	for _, closure := range c.Closures {
+		if _, isOnlyCalled := onlyCalled[closure]; len(closure.Captures) > 0 && !isOnlyCalled {
+			return NewError(closure.Name.Pos, "closure captures %s, so it can only be called or passed to functions that call it", closure.Captures[0].Name.Content)
+		}
+	}
+	for _, imp := range c.Imports {
+		if err := checkClosureEnvs(imp.File, checked); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findOnlyCalledClosures collects the capturing closures in a block that
+// can't outlive it: they're called right away, or only called, either
+// through a local variable or by the functions they're passed to.
+func findOnlyCalledClosures(b *CheckedBlock, funs map[*Token]*CheckedFunDef, captured map[*Token]struct{}, onlyCalled map[*CheckedFunExpr]struct{}) {
+	vars := make(map[*Token]*CheckedFunExpr)
+	walkBlock(b, func(stmt CheckedStmt) {
+		if v, isVar := stmt.(*CheckedVar); isVar && v.Mut == nil {
+			if closure, isClosure := v.Value.(*CheckedFunExpr); isClosure && len(closure.Captures) > 0 {
+				vars[v.Name] = closure
+			}
+		}
+	}, func(expr CheckedExpr) {
+		call, isCall := expr.(*CheckedCallExpr)
+		if !isCall {
+			return
+		}
+		if closure, isClosure := call.Callee.(*CheckedFunExpr); isClosure {
+			onlyCalled[closure] = struct{}{}
+			return
+		}
+		callee, isId := call.Callee.(*CheckedIdExpr)
+		if !isId || funs[callee.Id] == nil {
+			return
+		}
+		for i, arg := range call.Args {
+			closure, isClosure := arg.(*CheckedFunExpr)
+			if isClosure && len(closure.Captures) > 0 && i < len(funs[callee.Id].Params) &&
+				isOnlyCalled(funs[callee.Id].Params[i].Name, funs[callee.Id].Body, funs, captured, make(map[*Token]struct{})) {
+				onlyCalled[closure] = struct{}{}
+			}
+		}
+	})
+	for name, closure := range vars {
+		if isOnlyCalled(name, b, funs, captured, make(map[*Token]struct{})) {
+			onlyCalled[closure] = struct{}{}
+		}
+	}
+}
+
+func isOnlyCalled(name *Token, b *CheckedBlock, funs map[*Token]*CheckedFunDef, captured map[*Token]struct{}, pending map[*Token]struct{}) bool {
+	if _, isCaptured := captured[name]; isCaptured {
+		return false
+	}
+	pending[name] = struct{}{}
+	defer delete(pending, name)
+	uses, calls := 0, 0
+	walkBlock(b, func(CheckedStmt) {}, func(expr CheckedExpr) {
+		switch expr := expr.(type) {
+		case *CheckedIdExpr:
+			if expr.Id == name {
+				uses++
+			}
+		case *CheckedCallExpr:
+			callee, isId := expr.Callee.(*CheckedIdExpr)
+			if !isId {
+				return
+			}
+			if callee.Id == name {
+				calls++
+			}
+			f := funs[callee.Id]
+			if f == nil {
+				return
+			}
+			for i, arg := range expr.Args {
+				if arg, isId := arg.(*CheckedIdExpr); !isId || arg.Id != name || i >= len(f.Params) {
+					continue
+				}
+				if _, isPending := pending[f.Params[i].Name]; isPending || isOnlyCalled(f.Params[i].Name, f.Body, funs, captured, pending) {
+					calls++
+				}
+			}
+		}
+	})
+	return uses == calls
+}
+
+func walkBlock(b *CheckedBlock, visitStmt func(CheckedStmt), visitExpr func(CheckedExpr)) {
+	if b == nil {
+		return
+	}
+	for _, stmt := range b.Stmts {
+		walkStmt(stmt, visitStmt, visitExpr)
+	}
+}
+
+func walkStmt(stmt CheckedStmt, visitStmt func(CheckedStmt), visitExpr func(CheckedExpr)) {
+	if stmt == nil {
+		return
+	}
+	visitStmt(stmt)
+	for _, expr := range stmtExprs(stmt) {
+		walkExpr(expr, func(expr CheckedExpr) {
+			visitExpr(expr)
+			if ifExpr, isIfExpr := expr.(*CheckedIfExpr); isIfExpr {
+				walkBlock(ifExpr.Body, visitStmt, visitExpr)
+				walkBlock(ifExpr.ElseBody, visitStmt, visitExpr)
+			}
+		})
+	}
+	switch stmt := stmt.(type) {
+	case *CheckedBlock:
+		walkBlock(stmt, visitStmt, visitExpr)
+	case *CheckedIf:
+		walkBlock(stmt.Body, visitStmt, visitExpr)
+		walkBlock(stmt.ElseBody, visitStmt, visitExpr)
+	case *CheckedWhile:
+		walkBlock(stmt.Body, visitStmt, visitExpr)
+	case *CheckedFor:
+		walkBlock(stmt.Body, visitStmt, visitExpr)
+	case *CheckedMatch:
+		for _, arm := range stmt.Arms {
+			walkBlock(arm.Body, visitStmt, visitExpr)
+		}
+		walkBlock(stmt.ElseBody, visitStmt, visitExpr)
+	case *CheckedDefer:
+		walkStmt(stmt.Stmt, visitStmt, visitExpr)
+	}
+}
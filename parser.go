@@ -165,42 +165,11 @@ func (p *Parser) ParseDef() (ParsedDef, error) {
 		}
 		return nil, NewError(p.next().Pos, "expected FUN, but got %s", p.next().Kind)
 	case FUN:
-		fun := p.advance()
-		var typename, dot *Token
-		if p.peek(1).Kind == DOT {
-			typenameT := p.advance()
-			dotT := p.advance()
-			typename = &typenameT
-			dot = &dotT
-		}
-		id, err := p.match(IDENTIFIER)
-		if err != nil {
-			return nil, err
-		}
-		params, err := p.parseFunParams()
-		if err != nil {
-			return nil, err
-		}
-		var returnType ParsedType = nil
-		if p.next().Kind != LEFTBRACE {
-			returnType, err = p.parseType()
-			if err != nil {
-				return nil, err
-			}
-		}
-		body, err := p.parseBlock()
+		funDef, err := p.parseFunDef()
 		if err != nil {
 			return nil, err
 		}
-		return &ParsedFunDef{
-			Fun:        fun,
-			Typename:   typename,
-			Dot:        dot,
-			Id:         id,
-			Params:     params,
-			ReturnType: returnType,
-			Body:       body,
-		}, err
+		return funDef, nil
 	case IMPORT:
 		kw := p.advance()
 		name, err := p.match(IDENTIFIER)
@@ -217,14 +186,22 @@ func (p *Parser) ParseDef() (ParsedDef, error) {
 		if err != nil {
 			return nil, err
 		}
+		var typeParams []Token
+		if p.next().Kind == LEFTBRACKET {
+			typeParams, err = p.parseTypeParams()
+			if err != nil {
+				return nil, err
+			}
+		}
 		fields, err := p.parseStructBody()
 		if err != nil {
 			return nil, err
 		}
 		return &ParsedStructDef{
-			Struct: kw,
-			Name:   name,
-			Fields: fields,
+			Struct:     kw,
+			Name:       name,
+			TypeParams: typeParams,
+			Fields:     fields,
 		}, nil
 	case TYPEALIAS:
 		typealias := p.advance()
@@ -241,10 +218,162 @@ func (p *Parser) ParseDef() (ParsedDef, error) {
 			Name:      name,
 			Type:      typ,
 		}, nil
+	case ENUM:
+		kw := p.advance()
+		name, err := p.match(IDENTIFIER)
+		if err != nil {
+			return nil, err
+		}
+		variants, err := p.parseEnumBody()
+		if err != nil {
+			return nil, err
+		}
+		return &ParsedEnumDef{
+			Enum:     kw,
+			Name:     name,
+			Variants: variants,
+		}, nil
+	case CONST:
+		kw := p.advance()
+		name, err := p.match(IDENTIFIER)
+		if err != nil {
+			return nil, err
+		}
+		eq, err := p.match(EQ)
+		if err != nil {
+			return nil, err
+		}
+		value, err := p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return &ParsedConstDef{
+			Const: kw,
+			Name:  name,
+			Eq:    eq,
+			Value: value,
+		}, nil
+	case MUT, VAR:
+		var mut *Token
+		if p.next().Kind == MUT {
+			mutT := p.advance()
+			mut = &mutT
+		}
+		kw, err := p.match(VAR)
+		if err != nil {
+			return nil, err
+		}
+		name, err := p.match(IDENTIFIER)
+		if err != nil {
+			return nil, err
+		}
+		eq, err := p.match(EQ)
+		if err != nil {
+			return nil, err
+		}
+		value, err := p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return &ParsedVarDef{
+			Mut:   mut,
+			Var:   kw,
+			Name:  name,
+			Eq:    eq,
+			Value: value,
+		}, nil
+	case TRAIT:
+		kw := p.advance()
+		name, err := p.match(IDENTIFIER)
+		if err != nil {
+			return nil, err
+		}
+		methods, err := p.parseTraitBody()
+		if err != nil {
+			return nil, err
+		}
+		return &ParsedTraitDef{
+			Trait:   kw,
+			Name:    name,
+			Methods: methods,
+		}, nil
+	case IMPL:
+		impl := &ParsedImplDef{
+			Impl: p.advance(),
+		}
+		var err error
+		impl.Trait, err = p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		impl.For, err = p.match(FOR)
+		if err != nil {
+			return nil, err
+		}
+		impl.Typename, err = p.match(IDENTIFIER)
+		if err != nil {
+			return nil, err
+		}
+		impl.Methods, err = p.parseImplBody(&impl.Typename)
+		if err != nil {
+			return nil, err
+		}
+		return impl, nil
 	}
 	return nil, NewError(p.next().Pos, "expected definition, but got %s", p.next().Kind)
 }
 
+func (p *Parser) parseFunDef() (*ParsedFunDef, error) {
+	fun := p.advance()
+	var typename, dot *Token
+	var typeParams []Token
+	id, err := p.match(IDENTIFIER)
+	if err != nil {
+		return nil, err
+	}
+	if p.next().Kind == LEFTBRACKET {
+		typeParams, err = p.parseTypeParams()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if p.next().Kind == DOT {
+		typenameT := id
+		dotT := p.advance()
+		typename = &typenameT
+		dot = &dotT
+		id, err = p.match(IDENTIFIER)
+		if err != nil {
+			return nil, err
+		}
+	}
+	params, err := p.parseFunParams()
+	if err != nil {
+		return nil, err
+	}
+	var returnType ParsedType = nil
+	if p.next().Kind != LEFTBRACE {
+		returnType, err = p.parseType()
+		if err != nil {
+			return nil, err
+		}
+	}
+	body, err := p.parseBlock()
+	if err != nil {
+		return nil, err
+	}
+	return &ParsedFunDef{
+		Fun:        fun,
+		Typename:   typename,
+		TypeParams: typeParams,
+		Dot:        dot,
+		Id:         id,
+		Params:     params,
+		ReturnType: returnType,
+		Body:       body,
+	}, err
+}
+
 func (p *Parser) ParseDefAndEof() (ParsedDef, error) {
 	def, err := p.ParseDef()
 	if err != nil {
@@ -278,33 +407,7 @@ func (p *Parser) ParseStmt() (ParsedStmt, error) {
 	case LEFTBRACE:
 		return p.parseBlock()
 	case IF:
-		kw := p.advance()
-		cond, err := p.ParseExpr()
-		if err != nil {
-			return nil, err
-		}
-		body, err := p.parseBlock()
-		if err != nil {
-			return nil, err
-		}
-		if p.next().Kind == ELSE {
-			p.advance()
-			elseBody, err := p.parseBlock()
-			if err != nil {
-				return nil, err
-			}
-			return &ParsedIf{
-				If:        kw,
-				Condition: cond,
-				Body:      body,
-				ElseBody:  elseBody,
-			}, nil
-		}
-		return &ParsedIf{
-			If:        kw,
-			Condition: cond,
-			Body:      body,
-		}, nil
+		return p.parseIf()
 	case WHILE:
 		kw := p.advance()
 		cond, err := p.ParseExpr()
@@ -320,6 +423,10 @@ func (p *Parser) ParseStmt() (ParsedStmt, error) {
 			Condition: cond,
 			Body:      body,
 		}, nil
+	case FOR:
+		return p.parseFor()
+	case MATCH:
+		return p.parseMatch()
 	case BREAK:
 		return &ParsedBreak{
 			Break: p.advance(),
@@ -328,6 +435,29 @@ func (p *Parser) ParseStmt() (ParsedStmt, error) {
 		return &ParsedContinue{
 			Continue: p.advance(),
 		}, nil
+	case DEFER:
+		kw := p.advance()
+		stmt, err := p.ParseStmt()
+		if err != nil {
+			return nil, err
+		}
+		return &ParsedDefer{
+			Defer: kw,
+			Stmt:  stmt,
+		}, nil
+	case UNSAFE:
+		kw := p.advance()
+		if p.next().Kind != LEFTBRACE {
+			return nil, NewError(p.next().Pos, "expected { after unsafe, but got %s", p.next().Kind)
+		}
+		body, err := p.parseBlock()
+		if err != nil {
+			return nil, err
+		}
+		return &ParsedUnsafe{
+			Unsafe: kw,
+			Body:   body,
+		}, nil
 	case MUT, IDENTIFIER:
 		if (p.next().Kind == MUT && p.peek(1).Kind == IDENTIFIER && p.peek(2).Kind == COLONEQ) || (p.peek(1).Kind == COLONEQ) {
 			return p.parseVar()
@@ -342,6 +472,141 @@ func (p *Parser) ParseStmt() (ParsedStmt, error) {
 	}, nil
 }
 
+func (p *Parser) parseIf() (*ParsedIf, error) {
+	kw := p.advance()
+	var binding *Token
+	if p.next().Kind == IDENTIFIER && p.peek(1).Kind == COLONEQ {
+		id := p.advance()
+		p.advance()
+		binding = &id
+	}
+	cond, err := p.ParseExpr()
+	if err != nil {
+		return nil, err
+	}
+	body, err := p.parseBlock()
+	if err != nil {
+		return nil, err
+	}
+	if p.next().Kind == ELSE {
+		p.advance()
+		if p.next().Kind == IF {
+			elseIf, err := p.parseIf()
+			if err != nil {
+				return nil, err
+			}
+			return &ParsedIf{
+				If:        kw,
+				Binding:   binding,
+				Condition: cond,
+				Body:      body,
+				ElseIf:    elseIf,
+			}, nil
+		}
+		elseBody, err := p.parseBlock()
+		if err != nil {
+			return nil, err
+		}
+		return &ParsedIf{
+			If:        kw,
+			Binding:   binding,
+			Condition: cond,
+			Body:      body,
+			ElseBody:  elseBody,
+		}, nil
+	}
+	return &ParsedIf{
+		If:        kw,
+		Binding:   binding,
+		Condition: cond,
+		Body:      body,
+	}, nil
+}
+
+func (p *Parser) parseFor() (ParsedStmt, error) {
+	kw := p.advance()
+	if p.next().Kind == IDENTIFIER && p.peek(1).Kind == IN {
+		return p.parseForIn(kw)
+	}
+	var init ParsedStmt
+	var err error
+	if p.next().Kind != SEMICOLON {
+		init, err = p.ParseStmt()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.match(SEMICOLON); err != nil {
+		return nil, err
+	}
+	var cond ParsedExpr
+	if p.next().Kind != SEMICOLON {
+		cond, err = p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.match(SEMICOLON); err != nil {
+		return nil, err
+	}
+	var post ParsedStmt
+	if p.next().Kind != LEFTBRACE {
+		post, err = p.ParseStmt()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if p.next().Kind != LEFTBRACE {
+		return nil, NewError(p.next().Pos, "expected { after for clauses, but got %s", p.next().Kind)
+	}
+	body, err := p.parseBlock()
+	if err != nil {
+		return nil, err
+	}
+	return &ParsedFor{
+		For:  kw,
+		Init: init,
+		Cond: cond,
+		Post: post,
+		Body: body,
+	}, nil
+}
+
+func (p *Parser) parseForIn(kw Token) (*ParsedForIn, error) {
+	name := p.advance()
+	in := p.advance()
+	iterable, err := p.ParseExpr()
+	if err != nil {
+		return nil, err
+	}
+	var dotdot *Token
+	var end ParsedExpr
+	if p.next().Kind == DOTDOT {
+		t := p.advance()
+		dotdot = &t
+		end, err = p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if p.next().Kind != LEFTBRACE {
+		return nil, NewError(p.next().Pos, "expected { after for clauses, but got %s", p.next().Kind)
+	}
+	body, err := p.parseBlock()
+	if err != nil {
+		return nil, err
+	}
+	return &ParsedForIn{
+		For:      kw,
+		Name:     name,
+		In:       in,
+		Iterable: iterable,
+		DotDot:   dotdot,
+		End:      end,
+		Body:     body,
+	}, nil
+}
+
 func (p *Parser) parseVar() (*ParsedVar, error) {
 	var mut *Token
 	if p.next().Kind == MUT {
@@ -381,6 +646,9 @@ func (p *Parser) parseBlock() (*ParsedBlock, error) {
 			return nil, err
 		}
 		stmts = append(stmts, stmt)
+		if p.next().Kind == RIGHTBRACE {
+			break
+		}
 		_, err = p.match(NEWLINE)
 		if err != nil {
 			return nil, err
@@ -456,27 +724,39 @@ func (p *Parser) parseExpr(lhs ParsedExpr, minPrec int) (ParsedExpr, error) {
 }
 
 func IsRightAssoc(t TokenKind) bool {
-	return t == EQ
+	return t == EQ || isCompoundAssign(t)
+}
+
+func isCompoundAssign(t TokenKind) bool {
+	switch t {
+	case PLUSEQ, MINUSEQ, STAREQ, SLASHEQ, PERCENTEQ, AMPEQ, PIPEEQ, CARETEQ, LTLTEQ, GTGTEQ:
+		return true
+	}
+	return false
 }
 
 func precedence(t TokenKind) int {
 	switch t {
-	case STAR, SLASH:
+	case STAR, SLASH, PERCENT, LTLT, GTGT, AMP:
 		return 20
-	case PLUS, MINUS:
+	case PLUS, MINUS, PIPE, CARET:
 		return 10
 	case LT, LTEQ, GT, GTEQ:
 		return 6
 	case EQEQ, BANGEQ:
 		return 5
-	case EQ:
+	case AMPAMP:
+		return 3
+	case PIPEPIPE:
+		return 2
+	case EQ, PLUSEQ, MINUSEQ, STAREQ, SLASHEQ, PERCENTEQ, AMPEQ, PIPEEQ, CARETEQ, LTLTEQ, GTGTEQ:
 		return 1
 	}
 	return -1
 }
 
 func isUnaryOp(t TokenKind) bool {
-	return t == PLUS || t == MINUS || t == STAR || t == AMP
+	return t == PLUS || t == MINUS || t == STAR || t == AMP || t == BANG || t == TILDE
 }
 
 func (p *Parser) parsePrimary() (expr ParsedExpr, err error) {
@@ -493,8 +773,13 @@ func (p *Parser) parsePrimary() (expr ParsedExpr, err error) {
 		}, nil
 	default:
 		switch p.next().Kind {
-		case INTEGER, FLOAT, STRING, TRUE, FALSE:
+		case INTEGER, FLOAT, STRING, CHARACTER, TRUE, FALSE, NULL:
 			expr = &ParsedLiteralExpr{Token: p.advance()}
+		case STRINGHEAD:
+			expr, err = p.parseInterpolatedString()
+			if err != nil {
+				return nil, err
+			}
 		case IDENTIFIER:
 			expr, err = p.parseId()
 			if err != nil {
@@ -515,6 +800,22 @@ func (p *Parser) parsePrimary() (expr ParsedExpr, err error) {
 				Inner: inner,
 				Right: right,
 			}
+		case LEFTBRACKET:
+			expr, err = p.parseArrayLiteral()
+			if err != nil {
+				return nil, err
+			}
+		case IF:
+			ifExpr, err := p.parseIf()
+			if err != nil {
+				return nil, err
+			}
+			expr = &ParsedIfExpr{If: ifExpr}
+		case FUN:
+			expr, err = p.parseFunExpr()
+			if err != nil {
+				return nil, err
+			}
 		case DOT:
 			break
 		default:
@@ -540,6 +841,15 @@ Loop:
 				Dot:    dot,
 				Member: member,
 			}
+		case LEFTBRACKET:
+			if typeArgs := p.tryParseTypeArgsExpr(expr); typeArgs != nil {
+				expr = typeArgs
+				continue
+			}
+			expr, err = p.parseIndexOrSlice(expr)
+			if err != nil {
+				return nil, err
+			}
 		case AS:
 			as := p.advance()
 			typ, err := p.parseType()
@@ -554,8 +864,13 @@ Loop:
 			if err != nil {
 				return nil, err
 			}
+		case QUESTION:
+			expr = &ParsedPropagateExpr{
+				Value:    expr,
+				Question: p.advance(),
+			}
 		case LEFTBRACE:
-			if (p.peek(1).Kind == RIGHTBRACE) || (p.peek(1).Kind == IDENTIFIER && p.peek(2).Kind == COLON) || (p.peek(1).Kind == NEWLINE && p.peek(2).Kind == IDENTIFIER && p.peek(3).Kind == COLON) {
+			if p.isStructInitBody() {
 				expr, err = p.parseStructInitBody(expr)
 				if err != nil {
 					return nil, err
@@ -570,21 +885,50 @@ Loop:
 	return
 }
 
-func (p *Parser) parseId() (expr ParsedExpr, err error) {
-	if p.peek(1).Kind != COLONCOLON {
-		return &ParsedIdExpr{Token: p.advance()}, nil
+func (p *Parser) parseFunExpr() (*ParsedFunExpr, error) {
+	fun := p.advance()
+	params, err := p.parseFunParams()
+	if err != nil {
+		return nil, err
 	}
-	path := make([]Token, 0, 1)
-	coloncolons := make([]Token, 0, 1)
-	for p.peek(1).Kind == COLONCOLON {
-		module, err := p.match(IDENTIFIER)
+	var returnType ParsedType
+	if p.next().Kind != LEFTBRACE {
+		returnType, err = p.parseType()
 		if err != nil {
 			return nil, err
 		}
-		path = append(path, module)
-		coloncolons = append(coloncolons, p.advance())
 	}
-	id, err := p.match(IDENTIFIER)
+	body, err := p.parseBlock()
+	if err != nil {
+		return nil, err
+	}
+	return &ParsedFunExpr{
+		Fun:        fun,
+		Params:     params,
+		ReturnType: returnType,
+		Body:       body,
+	}, nil
+}
+
+func (p *Parser) isStructInitBody() bool {
+	return (p.peek(1).Kind == RIGHTBRACE) || (p.peek(1).Kind == IDENTIFIER && p.peek(2).Kind == COLON) || (p.peek(1).Kind == NEWLINE && p.peek(2).Kind == IDENTIFIER && p.peek(3).Kind == COLON)
+}
+
+func (p *Parser) parseId() (expr ParsedExpr, err error) {
+	if p.peek(1).Kind != COLONCOLON {
+		return &ParsedIdExpr{Token: p.advance()}, nil
+	}
+	path := make([]Token, 0, 1)
+	coloncolons := make([]Token, 0, 1)
+	for p.peek(1).Kind == COLONCOLON {
+		module, err := p.match(IDENTIFIER)
+		if err != nil {
+			return nil, err
+		}
+		path = append(path, module)
+		coloncolons = append(coloncolons, p.advance())
+	}
+	id, err := p.match(IDENTIFIER)
 	if err != nil {
 		return nil, err
 	}
@@ -601,6 +945,189 @@ func (p *Parser) parseId() (expr ParsedExpr, err error) {
 	return expr, nil
 }
 
+func (p *Parser) tryParseTypeArgsExpr(object ParsedExpr) *ParsedTypeArgsExpr {
+	switch object.(type) {
+	case *ParsedIdExpr, *ParsedModuleAccessExpr:
+	default:
+		return nil
+	}
+	start := p.index
+	left, args, right, err := p.parseTypeArgs()
+	if err == nil && (p.next().Kind == LEFTPAREN || (p.next().Kind == LEFTBRACE && p.isStructInitBody())) {
+		end := p.index
+		p.index = start
+		index, err := p.parseIndexOrSlice(object)
+		if err != nil || p.index != end {
+			index = nil
+		}
+		p.index = end
+		return &ParsedTypeArgsExpr{
+			Object: object,
+			Left:   left,
+			Args:   args,
+			Right:  right,
+			Index:  index,
+		}
+	}
+	p.index = start
+	return nil
+}
+
+func (p *Parser) parseTypeArgs() (left Token, args []ParsedType, right Token, err error) {
+	left, err = p.match(LEFTBRACKET)
+	if err != nil {
+		return
+	}
+	args = make([]ParsedType, 0)
+	for {
+		var arg ParsedType
+		arg, err = p.parseType()
+		if err != nil {
+			return
+		}
+		args = append(args, arg)
+		if p.next().Kind != COMMA {
+			break
+		}
+		p.advance()
+	}
+	right, err = p.match(RIGHTBRACKET)
+	return
+}
+
+func (p *Parser) parseTypeParams() ([]Token, error) {
+	if _, err := p.match(LEFTBRACKET); err != nil {
+		return nil, err
+	}
+	params := make([]Token, 0)
+	for {
+		param, err := p.match(IDENTIFIER)
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, param)
+		if p.next().Kind != COMMA {
+			break
+		}
+		p.advance()
+	}
+	if _, err := p.match(RIGHTBRACKET); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+func (p *Parser) parseIndexOrSlice(object ParsedExpr) (ParsedExpr, error) {
+	left := p.advance()
+	var low ParsedExpr
+	if p.next().Kind != COLON {
+		index, err := p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().Kind != COLON {
+			right, err := p.match(RIGHTBRACKET)
+			if err != nil {
+				return nil, err
+			}
+			return &ParsedIndexExpr{
+				Object: object,
+				Left:   left,
+				Index:  index,
+				Right:  right,
+			}, nil
+		}
+		low = index
+	}
+	colon := p.advance()
+	var high ParsedExpr
+	if p.next().Kind != RIGHTBRACKET {
+		var err error
+		high, err = p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+	}
+	right, err := p.match(RIGHTBRACKET)
+	if err != nil {
+		return nil, err
+	}
+	return &ParsedSliceExpr{
+		Object: object,
+		Left:   left,
+		Low:    low,
+		Colon:  colon,
+		High:   high,
+		Right:  right,
+	}, nil
+}
+
+func (p *Parser) parseInterpolatedString() (*ParsedInterpolatedStringExpr, error) {
+	expr := &ParsedInterpolatedStringExpr{Parts: []Token{p.advance()}}
+	for {
+		value, err := p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+		expr.Exprs = append(expr.Exprs, value)
+		switch p.next().Kind {
+		case STRINGMIDDLE:
+			expr.Parts = append(expr.Parts, p.advance())
+		case STRINGTAIL:
+			expr.Parts = append(expr.Parts, p.advance())
+			return expr, nil
+		default:
+			return nil, NewError(p.next().Pos, "expected } after an interpolated expression, but got %s", p.next().Kind)
+		}
+	}
+}
+
+func (p *Parser) parseArrayLiteral() (*ParsedArrayLiteralExpr, error) {
+	start := p.index
+	if typ, err := p.parseType(); err == nil && p.next().Kind == LEFTBRACE {
+		if arrayType, ok := typ.(*ParsedArrayType); ok {
+			return p.parseArrayLiteralBody(arrayType, RIGHTBRACE)
+		}
+	}
+	p.index = start
+	return p.parseArrayLiteralBody(nil, RIGHTBRACKET)
+}
+
+func (p *Parser) parseArrayLiteralBody(typ *ParsedArrayType, closing TokenKind) (*ParsedArrayLiteralExpr, error) {
+	left := p.advance()
+	elems := make([]ParsedExpr, 0)
+	for p.next().Kind != closing {
+		if p.next().Kind == NEWLINE {
+			p.advance()
+			continue
+		}
+		elem, err := p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, elem)
+		for p.next().Kind == NEWLINE {
+			p.advance()
+		}
+		if p.next().Kind == closing {
+			break
+		}
+		if _, err := p.match(COMMA); err != nil {
+			return nil, err
+		}
+	}
+	right, err := p.match(closing)
+	if err != nil {
+		return nil, err
+	}
+	return &ParsedArrayLiteralExpr{
+		Type:  typ,
+		Left:  left,
+		Elems: elems,
+		Right: right,
+	}, nil
+}
+
 func (p *Parser) parseStructInitBody(name ParsedExpr) (*ParsedStructInitExpr, error) {
 	typ, err := parsedExprToParsedType(name)
 	if err != nil {
@@ -668,6 +1195,17 @@ func parsedExprToParsedType(p ParsedExpr) (ParsedType, error) {
 			Coloncolon: p.Coloncolon,
 			Member:     member,
 		}, nil
+	case *ParsedTypeArgsExpr:
+		typ, err := parsedExprToParsedType(p.Object)
+		if err != nil {
+			return nil, err
+		}
+		return &ParsedGenericType{
+			Type:  typ,
+			Left:  p.Left,
+			Args:  p.Args,
+			Right: p.Right,
+		}, nil
 	}
 	return nil, NewError(p.pos(), "an invalid type in the struct initializer")
 }
@@ -762,6 +1300,23 @@ func (p *Parser) parseFunParams() (params []ParsedFunParam, err error) {
 }
 
 func (p *Parser) parseType() (ParsedType, error) {
+	typ, err := p.parseTypeOperand()
+	if err != nil || p.next().Kind != BANG {
+		return typ, err
+	}
+	bang := p.advance()
+	errorType, err := p.parseTypeOperand()
+	if err != nil {
+		return nil, err
+	}
+	return &ParsedResultType{
+		Value: typ,
+		Bang:  bang,
+		Error: errorType,
+	}, nil
+}
+
+func (p *Parser) parseTypeOperand() (ParsedType, error) {
 	switch p.next().Kind {
 	case LEFTPAREN:
 		l := p.advance()
@@ -777,10 +1332,23 @@ func (p *Parser) parseType() (ParsedType, error) {
 		if err != nil {
 			return nil, err
 		}
-		return parsedExprToParsedType(expr)
+		typ, err := parsedExprToParsedType(expr)
+		if err != nil || p.next().Kind != LEFTBRACKET {
+			return typ, err
+		}
+		left, args, right, err := p.parseTypeArgs()
+		if err != nil {
+			return nil, err
+		}
+		return &ParsedGenericType{
+			Type:  typ,
+			Left:  left,
+			Args:  args,
+			Right: right,
+		}, nil
 	case STAR:
 		star := p.advance()
-		to, err := p.parseType()
+		to, err := p.parseTypeOperand()
 		if err != nil {
 			return nil, err
 		}
@@ -788,10 +1356,241 @@ func (p *Parser) parseType() (ParsedType, error) {
 			Star: star,
 			To:   to,
 		}, nil
+	case QUESTION:
+		question := p.advance()
+		elem, err := p.parseTypeOperand()
+		if err != nil {
+			return nil, err
+		}
+		return &ParsedOptionalType{
+			Question: question,
+			Elem:     elem,
+		}, nil
+	case LEFTBRACKET:
+		left := p.advance()
+		if p.next().Kind == RIGHTBRACKET {
+			right := p.advance()
+			elem, err := p.parseTypeOperand()
+			if err != nil {
+				return nil, err
+			}
+			return &ParsedSliceType{
+				Left:  left,
+				Right: right,
+				Elem:  elem,
+			}, nil
+		}
+		length, err := p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+		right, err := p.match(RIGHTBRACKET)
+		if err != nil {
+			return nil, err
+		}
+		elem, err := p.parseTypeOperand()
+		if err != nil {
+			return nil, err
+		}
+		return &ParsedArrayType{
+			Left:  left,
+			Len:   length,
+			Right: right,
+			Elem:  elem,
+		}, nil
+	case FUN:
+		return p.parseFunType()
 	}
 	return nil, NewError(p.next().Pos, "expected type, but got %s", p.next().Kind)
 }
 
+func (p *Parser) parseFunType() (*ParsedFunType, error) {
+	fun := p.advance()
+	left, err := p.match(LEFTPAREN)
+	if err != nil {
+		return nil, err
+	}
+	params := make([]ParsedType, 0)
+	for p.next().Kind != RIGHTPAREN {
+		param, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, param)
+		if p.next().Kind == RIGHTPAREN {
+			break
+		}
+		if _, err := p.match(COMMA); err != nil {
+			return nil, err
+		}
+	}
+	right, err := p.match(RIGHTPAREN)
+	if err != nil {
+		return nil, err
+	}
+	var returns ParsedType
+	if p.isTypeStart() {
+		returns, err = p.parseType()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &ParsedFunType{
+		Fun:     fun,
+		Left:    left,
+		Params:  params,
+		Right:   right,
+		Returns: returns,
+	}, nil
+}
+
+func (p *Parser) isTypeStart() bool {
+	switch p.next().Kind {
+	case IDENTIFIER, STAR, LEFTBRACKET, FUN, QUESTION:
+		return true
+	case LEFTPAREN:
+		return p.peek(1).Kind == RIGHTPAREN
+	}
+	return false
+}
+
+func (p *Parser) parseEnumBody() (variants []ParsedEnumVariant, err error) {
+	_, err = p.match(LEFTBRACE)
+	if err != nil {
+		return variants, err
+	}
+	variants = make([]ParsedEnumVariant, 0)
+	for p.next().Kind != RIGHTBRACE {
+		if p.next().Kind == NEWLINE {
+			p.advance()
+			continue
+		}
+		name, err := p.match(IDENTIFIER)
+		if err != nil {
+			return variants, err
+		}
+		variant := ParsedEnumVariant{
+			Name: name,
+		}
+		if p.next().Kind == LEFTPAREN {
+			variant.Payload, err = p.parseEnumPayload()
+			if err != nil {
+				return variants, err
+			}
+		}
+		if p.next().Kind == EQ {
+			eq := p.advance()
+			variant.Eq = &eq
+			variant.Value, err = p.ParseExpr()
+			if err != nil {
+				return variants, err
+			}
+		}
+		variants = append(variants, variant)
+		if p.next().Kind == COMMA || p.next().Kind == NEWLINE {
+			p.advance()
+			continue
+		}
+		if p.next().Kind == RIGHTBRACE {
+			break
+		}
+		return variants, NewError(p.next().Pos, "expected comma, newline or }, but got %s", p.next().Kind)
+	}
+	_, err = p.match(RIGHTBRACE)
+	if err != nil {
+		return nil, err
+	}
+	return variants, nil
+}
+
+func (p *Parser) parseEnumPayload() (payload []ParsedType, err error) {
+	p.advance()
+	payload = make([]ParsedType, 0)
+	for p.next().Kind != RIGHTPAREN {
+		typ, err := p.parseType()
+		if err != nil {
+			return payload, err
+		}
+		payload = append(payload, typ)
+		if p.next().Kind == RIGHTPAREN {
+			break
+		}
+		if _, err := p.match(COMMA); err != nil {
+			return payload, err
+		}
+	}
+	_, err = p.match(RIGHTPAREN)
+	return payload, err
+}
+
+func (p *Parser) parseMatch() (*ParsedMatch, error) {
+	kw := p.advance()
+	value, err := p.ParseExpr()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.match(LEFTBRACE)
+	if err != nil {
+		return nil, err
+	}
+	match := &ParsedMatch{
+		Match: kw,
+		Value: value,
+		Arms:  make([]ParsedMatchArm, 0),
+	}
+	for p.next().Kind != RIGHTBRACE {
+		if p.next().Kind == NEWLINE {
+			p.advance()
+			continue
+		}
+		if p.next().Kind == ELSE {
+			p.advance()
+			match.ElseBody, err = p.parseBlock()
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		variant, err := p.match(IDENTIFIER)
+		if err != nil {
+			return nil, err
+		}
+		arm := ParsedMatchArm{
+			Variant: variant,
+		}
+		if p.next().Kind == LEFTPAREN {
+			p.advance()
+			arm.Bindings = make([]Token, 0)
+			for p.next().Kind != RIGHTPAREN {
+				binding, err := p.match(IDENTIFIER)
+				if err != nil {
+					return nil, err
+				}
+				arm.Bindings = append(arm.Bindings, binding)
+				if p.next().Kind == RIGHTPAREN {
+					break
+				}
+				if _, err := p.match(COMMA); err != nil {
+					return nil, err
+				}
+			}
+			if _, err := p.match(RIGHTPAREN); err != nil {
+				return nil, err
+			}
+		}
+		if p.next().Kind != LEFTBRACE {
+			return nil, NewError(p.next().Pos, "expected {, but got %s", p.next().Kind)
+		}
+		arm.Body, err = p.parseBlock()
+		if err != nil {
+			return nil, err
+		}
+		match.Arms = append(match.Arms, arm)
+	}
+	p.advance()
+	return match, nil
+}
+
 func (p *Parser) parseStructBody() (fields []ParsedStructField, err error) {
 	_, err = p.match(LEFTBRACE)
 	if err != nil {
@@ -830,3 +1629,94 @@ func (p *Parser) parseStructBody() (fields []ParsedStructField, err error) {
 	}
 	return fields, nil
 }
+
+func (p *Parser) parseTraitBody() (methods []ParsedTraitMethod, err error) {
+	_, err = p.match(LEFTBRACE)
+	if err != nil {
+		return methods, err
+	}
+	methods = make([]ParsedTraitMethod, 0)
+	for p.next().Kind != RIGHTBRACE {
+		if p.next().Kind == NEWLINE {
+			p.advance()
+			continue
+		}
+		fun, err := p.match(FUN)
+		if err != nil {
+			return methods, err
+		}
+		id, err := p.match(IDENTIFIER)
+		if err != nil {
+			return methods, err
+		}
+		params, err := p.parseFunParams()
+		if err != nil {
+			return methods, err
+		}
+		var returnType ParsedType = nil
+		if p.next().Kind != NEWLINE && p.next().Kind != RIGHTBRACE {
+			returnType, err = p.parseType()
+			if err != nil {
+				return methods, err
+			}
+		}
+		methods = append(methods, ParsedTraitMethod{
+			Fun:        fun,
+			Id:         id,
+			Params:     params,
+			ReturnType: returnType,
+		})
+		if p.next().Kind == NEWLINE {
+			p.advance()
+			continue
+		}
+		if p.next().Kind == RIGHTBRACE {
+			break
+		}
+		return methods, NewError(p.next().Pos, "expected newline or }, but got %s", p.next().Kind)
+	}
+	_, err = p.match(RIGHTBRACE)
+	if err != nil {
+		return nil, err
+	}
+	return methods, nil
+}
+
+func (p *Parser) parseImplBody(typename *Token) (methods []*ParsedFunDef, err error) {
+	_, err = p.match(LEFTBRACE)
+	if err != nil {
+		return methods, err
+	}
+	methods = make([]*ParsedFunDef, 0)
+	for p.next().Kind != RIGHTBRACE {
+		if p.next().Kind == NEWLINE {
+			p.advance()
+			continue
+		}
+		if p.next().Kind != FUN {
+			return methods, NewError(p.next().Pos, "expected FUN, but got %s", p.next().Kind)
+		}
+		method, err := p.parseFunDef()
+		if err != nil {
+			return methods, err
+		}
+		if method.Typename != nil || method.TypeParams != nil {
+			return methods, NewError(method.Id.Pos, "methods in impl blocks can't have a receiver type or type parameters")
+		}
+		method.Typename = typename
+		methods = append(methods, method)
+		if p.next().Kind == NEWLINE {
+			p.advance()
+			continue
+		}
+		if p.next().Kind == RIGHTBRACE {
+			break
+		}
+		return methods, NewError(p.next().Pos, "expected newline or }, but got %s", p.next().Kind)
+	}
+	_, err = p.match(RIGHTBRACE)
+	if err != nil {
+		return nil, err
+	}
+	return methods, nil
+}
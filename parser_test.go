@@ -2,6 +2,7 @@ package wall_test
 
 import (
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"wall"
@@ -55,6 +56,8 @@ var unaryOps = []wall.Token{
 	{Kind: wall.MINUS},
 	{Kind: wall.AMP},
 	{Kind: wall.STAR},
+	{Kind: wall.BANG},
+	{Kind: wall.TILDE},
 }
 
 func TestParseUnaryExpr(t *testing.T) {
@@ -78,7 +81,25 @@ var binaryOps = []wall.Token{
 	{Kind: wall.LTEQ},
 	{Kind: wall.GT},
 	{Kind: wall.GTEQ},
+	{Kind: wall.AMPAMP},
+	{Kind: wall.PIPEPIPE},
+	{Kind: wall.PERCENT},
+	{Kind: wall.AMP},
+	{Kind: wall.PIPE},
+	{Kind: wall.CARET},
+	{Kind: wall.LTLT},
+	{Kind: wall.GTGT},
 	{Kind: wall.EQ},
+	{Kind: wall.PLUSEQ},
+	{Kind: wall.MINUSEQ},
+	{Kind: wall.STAREQ},
+	{Kind: wall.SLASHEQ},
+	{Kind: wall.PERCENTEQ},
+	{Kind: wall.AMPEQ},
+	{Kind: wall.PIPEEQ},
+	{Kind: wall.CARETEQ},
+	{Kind: wall.LTLTEQ},
+	{Kind: wall.GTGTEQ},
 }
 
 func TestParseBinaryExpr(t *testing.T) {
@@ -104,7 +125,7 @@ func TestParseBinaryExpr(t *testing.T) {
 				},
 			}
 			assert.Equal(t, res, expected)
-			return
+			continue
 		}
 		expected := &wall.ParsedBinaryExpr{
 			Left: &wall.ParsedBinaryExpr{
@@ -125,6 +146,25 @@ func TestParseBinaryExpr(t *testing.T) {
 	}
 }
 
+func TestParseLogicalExprPrecedence(t *testing.T) {
+	pr := wall.NewParser([]wall.Token{{Kind: wall.IDENTIFIER, Content: "a"}, {Kind: wall.PIPEPIPE}, {Kind: wall.IDENTIFIER, Content: "b"}, {Kind: wall.AMPAMP}, {Kind: wall.IDENTIFIER, Content: "c"}, {Kind: wall.EQEQ}, {Kind: wall.IDENTIFIER, Content: "d"}})
+	res, err := pr.ParseExprAndEof()
+	assert.NoError(t, err)
+	assert.Equal(t, &wall.ParsedBinaryExpr{
+		Left: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "a"}},
+		Op:   wall.Token{Kind: wall.PIPEPIPE},
+		Right: &wall.ParsedBinaryExpr{
+			Left: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "b"}},
+			Op:   wall.Token{Kind: wall.AMPAMP},
+			Right: &wall.ParsedBinaryExpr{
+				Left:  &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "c"}},
+				Op:    wall.Token{Kind: wall.EQEQ},
+				Right: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "d"}},
+			},
+		},
+	}, res)
+}
+
 func TestParseGroupedExpr(t *testing.T) {
 	pr := wall.NewParser([]wall.Token{{Kind: wall.LEFTPAREN}, {Kind: wall.IDENTIFIER}, {Kind: wall.RIGHTPAREN}})
 	expr, err := pr.ParseExprAndEof()
@@ -373,6 +413,158 @@ var parseIfStmtTests = []parseIfStmtTest{
 			},
 		},
 	},
+	{
+		tokens: []wall.Token{
+			{Kind: wall.IF}, {Kind: wall.IDENTIFIER, Content: "x"}, {Kind: wall.COLONEQ}, {Kind: wall.IDENTIFIER, Content: "opt"}, {Kind: wall.LEFTBRACE}, {Kind: wall.IDENTIFIER, Content: "x"}, {Kind: wall.NEWLINE}, {Kind: wall.RIGHTBRACE},
+		},
+		expected: &wall.ParsedIf{
+			If:      wall.Token{Kind: wall.IF},
+			Binding: &wall.Token{Kind: wall.IDENTIFIER, Content: "x"},
+			Condition: &wall.ParsedIdExpr{
+				Token: wall.Token{Kind: wall.IDENTIFIER, Content: "opt"},
+			},
+			Body: &wall.ParsedBlock{
+				Left:  wall.Token{Kind: wall.LEFTBRACE},
+				Stmts: []wall.ParsedStmt{&wall.ParsedExprStmt{Expr: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "x"}}}},
+				Right: wall.Token{Kind: wall.RIGHTBRACE},
+			},
+		},
+	},
+	{
+		tokens: []wall.Token{
+			{Kind: wall.IF}, {Kind: wall.IDENTIFIER, Content: "a"}, {Kind: wall.LEFTBRACE}, {Kind: wall.IDENTIFIER, Content: "x"}, {Kind: wall.RIGHTBRACE},
+			{Kind: wall.ELSE}, {Kind: wall.IF}, {Kind: wall.IDENTIFIER, Content: "b"}, {Kind: wall.LEFTBRACE}, {Kind: wall.IDENTIFIER, Content: "y"}, {Kind: wall.RIGHTBRACE},
+			{Kind: wall.ELSE}, {Kind: wall.LEFTBRACE}, {Kind: wall.IDENTIFIER, Content: "z"}, {Kind: wall.RIGHTBRACE},
+		},
+		expected: &wall.ParsedIf{
+			If:        wall.Token{Kind: wall.IF},
+			Condition: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "a"}},
+			Body: &wall.ParsedBlock{
+				Left:  wall.Token{Kind: wall.LEFTBRACE},
+				Stmts: []wall.ParsedStmt{&wall.ParsedExprStmt{Expr: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "x"}}}},
+				Right: wall.Token{Kind: wall.RIGHTBRACE},
+			},
+			ElseIf: &wall.ParsedIf{
+				If:        wall.Token{Kind: wall.IF},
+				Condition: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "b"}},
+				Body: &wall.ParsedBlock{
+					Left:  wall.Token{Kind: wall.LEFTBRACE},
+					Stmts: []wall.ParsedStmt{&wall.ParsedExprStmt{Expr: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "y"}}}},
+					Right: wall.Token{Kind: wall.RIGHTBRACE},
+				},
+				ElseBody: &wall.ParsedBlock{
+					Left:  wall.Token{Kind: wall.LEFTBRACE},
+					Stmts: []wall.ParsedStmt{&wall.ParsedExprStmt{Expr: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "z"}}}},
+					Right: wall.Token{Kind: wall.RIGHTBRACE},
+				},
+			},
+		},
+	},
+}
+
+func TestParseIfExpr(t *testing.T) {
+	pr := wall.NewParser([]wall.Token{
+		{Kind: wall.IDENTIFIER, Content: "f"}, {Kind: wall.LEFTPAREN},
+		{Kind: wall.IF}, {Kind: wall.IDENTIFIER, Content: "c"}, {Kind: wall.LEFTBRACE}, {Kind: wall.INTEGER, Content: "1"}, {Kind: wall.RIGHTBRACE},
+		{Kind: wall.ELSE}, {Kind: wall.LEFTBRACE}, {Kind: wall.INTEGER, Content: "2"}, {Kind: wall.RIGHTBRACE},
+		{Kind: wall.RIGHTPAREN}, {Kind: wall.EOF},
+	})
+	got, err := pr.ParseExpr()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &wall.ParsedCallExpr{
+			Callee: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "f"}},
+			Args: []wall.ParsedExpr{
+				&wall.ParsedIfExpr{
+					If: &wall.ParsedIf{
+						If:        wall.Token{Kind: wall.IF},
+						Condition: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "c"}},
+						Body: &wall.ParsedBlock{
+							Left:  wall.Token{Kind: wall.LEFTBRACE},
+							Stmts: []wall.ParsedStmt{&wall.ParsedExprStmt{Expr: &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "1"}}}},
+							Right: wall.Token{Kind: wall.RIGHTBRACE},
+						},
+						ElseBody: &wall.ParsedBlock{
+							Left:  wall.Token{Kind: wall.LEFTBRACE},
+							Stmts: []wall.ParsedStmt{&wall.ParsedExprStmt{Expr: &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "2"}}}},
+							Right: wall.Token{Kind: wall.RIGHTBRACE},
+						},
+					},
+				},
+			},
+		}, got)
+	}
+}
+
+func TestParseForStmt(t *testing.T) {
+	id := func(name string) *wall.ParsedIdExpr {
+		return &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: name}}
+	}
+	body := &wall.ParsedBlock{
+		Left:  wall.Token{Kind: wall.LEFTBRACE},
+		Stmts: []wall.ParsedStmt{&wall.ParsedBreak{Break: wall.Token{Kind: wall.BREAK}}},
+		Right: wall.Token{Kind: wall.RIGHTBRACE},
+	}
+	bodyTokens := []wall.Token{{Kind: wall.LEFTBRACE}, {Kind: wall.BREAK}, {Kind: wall.NEWLINE}, {Kind: wall.RIGHTBRACE}}
+	tests := []struct {
+		tokens   []wall.Token
+		expected wall.ParsedStmt
+	}{
+		{
+			[]wall.Token{{Kind: wall.FOR}, {Kind: wall.IDENTIFIER, Content: "i"}, {Kind: wall.IN}, {Kind: wall.INTEGER, Content: "0"}, {Kind: wall.DOTDOT}, {Kind: wall.IDENTIFIER, Content: "n"}},
+			&wall.ParsedForIn{
+				For:      wall.Token{Kind: wall.FOR},
+				Name:     wall.Token{Kind: wall.IDENTIFIER, Content: "i"},
+				In:       wall.Token{Kind: wall.IN},
+				Iterable: &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "0"}},
+				DotDot:   &wall.Token{Kind: wall.DOTDOT},
+				End:      id("n"),
+				Body:     body,
+			},
+		},
+		{
+			[]wall.Token{{Kind: wall.FOR}, {Kind: wall.IDENTIFIER, Content: "x"}, {Kind: wall.IN}, {Kind: wall.IDENTIFIER, Content: "xs"}},
+			&wall.ParsedForIn{
+				For:      wall.Token{Kind: wall.FOR},
+				Name:     wall.Token{Kind: wall.IDENTIFIER, Content: "x"},
+				In:       wall.Token{Kind: wall.IN},
+				Iterable: id("xs"),
+				Body:     body,
+			},
+		},
+		{
+			[]wall.Token{
+				{Kind: wall.FOR}, {Kind: wall.MUT}, {Kind: wall.IDENTIFIER, Content: "i"}, {Kind: wall.COLONEQ}, {Kind: wall.INTEGER, Content: "0"}, {Kind: wall.SEMICOLON},
+				{Kind: wall.IDENTIFIER, Content: "i"}, {Kind: wall.LT}, {Kind: wall.IDENTIFIER, Content: "n"}, {Kind: wall.SEMICOLON},
+				{Kind: wall.IDENTIFIER, Content: "i"}, {Kind: wall.PLUSEQ}, {Kind: wall.INTEGER, Content: "1"},
+			},
+			&wall.ParsedFor{
+				For: wall.Token{Kind: wall.FOR},
+				Init: &wall.ParsedVar{
+					Mut:     &wall.Token{Kind: wall.MUT},
+					Id:      wall.Token{Kind: wall.IDENTIFIER, Content: "i"},
+					ColonEq: wall.Token{Kind: wall.COLONEQ},
+					Value:   &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "0"}},
+				},
+				Cond: &wall.ParsedBinaryExpr{Left: id("i"), Op: wall.Token{Kind: wall.LT}, Right: id("n")},
+				Post: &wall.ParsedExprStmt{Expr: &wall.ParsedBinaryExpr{Left: id("i"), Op: wall.Token{Kind: wall.PLUSEQ}, Right: &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "1"}}}},
+				Body: body,
+			},
+		},
+		{
+			[]wall.Token{{Kind: wall.FOR}, {Kind: wall.SEMICOLON}, {Kind: wall.SEMICOLON}},
+			&wall.ParsedFor{
+				For:  wall.Token{Kind: wall.FOR},
+				Body: body,
+			},
+		},
+	}
+	for _, test := range tests {
+		pr := wall.NewParser(append(test.tokens, bodyTokens...))
+		got, err := pr.ParseStmtAndEof()
+		if assert.NoError(t, err) {
+			assert.Equal(t, test.expected, got)
+		}
+	}
 }
 
 func TestParseWhileStmt(t *testing.T) {
@@ -400,6 +592,40 @@ func TestParseWhileStmt(t *testing.T) {
 	}
 }
 
+func TestParseDeferStmt(t *testing.T) {
+	pr := wall.NewParser([]wall.Token{{Kind: wall.DEFER}, {Kind: wall.IDENTIFIER, Content: "close"}, {Kind: wall.LEFTPAREN}, {Kind: wall.RIGHTPAREN}})
+	got, err := pr.ParseStmtAndEof()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &wall.ParsedDefer{
+			Defer: wall.Token{Kind: wall.DEFER},
+			Stmt: &wall.ParsedExprStmt{
+				Expr: &wall.ParsedCallExpr{
+					Callee: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "close"}},
+					Args:   []wall.ParsedExpr{},
+				},
+			},
+		}, got)
+	}
+}
+
+func TestParseUnsafeStmt(t *testing.T) {
+	pr := wall.NewParser([]wall.Token{{Kind: wall.UNSAFE}, {Kind: wall.LEFTBRACE}, {Kind: wall.IDENTIFIER, Content: "p"}, {Kind: wall.NEWLINE}, {Kind: wall.RIGHTBRACE}})
+	got, err := pr.ParseStmtAndEof()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &wall.ParsedUnsafe{
+			Unsafe: wall.Token{Kind: wall.UNSAFE},
+			Body: &wall.ParsedBlock{
+				Left:  wall.Token{Kind: wall.LEFTBRACE},
+				Stmts: []wall.ParsedStmt{&wall.ParsedExprStmt{Expr: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "p"}}}},
+				Right: wall.Token{Kind: wall.RIGHTBRACE},
+			},
+		}, got)
+	}
+	pr = wall.NewParser([]wall.Token{{Kind: wall.UNSAFE}, {Kind: wall.IDENTIFIER, Content: "p"}})
+	_, err = pr.ParseStmtAndEof()
+	assert.Error(t, err)
+}
+
 func TestParseIfStmt(t *testing.T) {
 	for _, test := range parseIfStmtTests {
 		pr := wall.NewParser(test.tokens)
@@ -669,16 +895,17 @@ func TestParseFile(t *testing.T) {
 }
 
 func TestParseCompilationUnit(t *testing.T) {
-	if err := os.WriteFile("A.wall", []byte("import B\nfun a() {}\n"), 0666); err != nil {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "A.wall"), []byte("import B\nfun a() {}\n"), 0666); err != nil {
 		t.Fatal(err)
 	}
-	if err := os.WriteFile("B.wall", []byte("import C\nfun b() {}\n"), 0666); err != nil {
+	if err := os.WriteFile(filepath.Join(dir, "B.wall"), []byte("import C\nfun b() {}\n"), 0666); err != nil {
 		t.Fatal(err)
 	}
-	if err := os.WriteFile("C.wall", []byte("import A\nfun c() {}\n"), 0666); err != nil {
+	if err := os.WriteFile(filepath.Join(dir, "C.wall"), []byte("import A\nfun c() {}\n"), 0666); err != nil {
 		t.Fatal(err)
 	}
-	A, err := wall.ParseCompilationUnit("A.wall", "import B\nfun a() {}\n", "")
+	A, err := wall.ParseCompilationUnit("A.wall", "import B\nfun a() {}\n", dir)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -749,6 +976,65 @@ func TestParseTypealiasDef(t *testing.T) {
 	}
 }
 
+func TestParseConstDef(t *testing.T) {
+	pr := wall.NewParser([]wall.Token{{Kind: wall.CONST}, {Kind: wall.IDENTIFIER, Content: "N"}, {Kind: wall.EQ}, {Kind: wall.INTEGER, Content: "1"}, {Kind: wall.PLUS}, {Kind: wall.INTEGER, Content: "2"}})
+	got, err := pr.ParseDef()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &wall.ParsedConstDef{
+			Const: wall.Token{Kind: wall.CONST},
+			Name:  wall.Token{Kind: wall.IDENTIFIER, Content: "N"},
+			Eq:    wall.Token{Kind: wall.EQ},
+			Value: &wall.ParsedBinaryExpr{
+				Left:  &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "1"}},
+				Op:    wall.Token{Kind: wall.PLUS},
+				Right: &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "2"}},
+			},
+		}, got)
+	}
+}
+
+func TestParseInterpolatedString(t *testing.T) {
+	pr := wall.NewParser([]wall.Token{
+		{Kind: wall.STRINGHEAD, Content: "a = "}, {Kind: wall.IDENTIFIER, Content: "a"},
+		{Kind: wall.STRINGMIDDLE, Content: ", b = "}, {Kind: wall.IDENTIFIER, Content: "b"}, {Kind: wall.PLUS}, {Kind: wall.INTEGER, Content: "1"},
+		{Kind: wall.STRINGTAIL, Content: ""},
+	})
+	got, err := pr.ParseExpr()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &wall.ParsedInterpolatedStringExpr{
+			Parts: []wall.Token{{Kind: wall.STRINGHEAD, Content: "a = "}, {Kind: wall.STRINGMIDDLE, Content: ", b = "}, {Kind: wall.STRINGTAIL, Content: ""}},
+			Exprs: []wall.ParsedExpr{
+				&wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "a"}},
+				&wall.ParsedBinaryExpr{
+					Left:  &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "b"}},
+					Op:    wall.Token{Kind: wall.PLUS},
+					Right: &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "1"}},
+				},
+			},
+		}, got)
+	}
+	pr = wall.NewParser([]wall.Token{{Kind: wall.STRINGHEAD}, {Kind: wall.IDENTIFIER, Content: "a"}, {Kind: wall.EOF}})
+	_, err = pr.ParseExpr()
+	assert.Error(t, err)
+}
+
+func TestParseVarDef(t *testing.T) {
+	pr := wall.NewParser([]wall.Token{{Kind: wall.MUT}, {Kind: wall.VAR}, {Kind: wall.IDENTIFIER, Content: "count"}, {Kind: wall.EQ}, {Kind: wall.INTEGER, Content: "0"}})
+	got, err := pr.ParseDef()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &wall.ParsedVarDef{
+			Mut:   &wall.Token{Kind: wall.MUT},
+			Var:   wall.Token{Kind: wall.VAR},
+			Name:  wall.Token{Kind: wall.IDENTIFIER, Content: "count"},
+			Eq:    wall.Token{Kind: wall.EQ},
+			Value: &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "0"}},
+		}, got)
+	}
+	pr = wall.NewParser([]wall.Token{{Kind: wall.MUT}, {Kind: wall.IDENTIFIER, Content: "count"}, {Kind: wall.EQ}, {Kind: wall.INTEGER, Content: "0"}})
+	_, err = pr.ParseDef()
+	assert.Error(t, err)
+}
+
 func TestParseThisExpr(t *testing.T) {
 	pr := wall.NewParser([]wall.Token{{Kind: wall.DOT}, {Kind: wall.IDENTIFIER}})
 	got, err := pr.ParseExprAndEof()
@@ -760,3 +1046,420 @@ func TestParseThisExpr(t *testing.T) {
 		}, got)
 	}
 }
+
+func TestParseArrayLiteralExpr(t *testing.T) {
+	pr := wall.NewParser([]wall.Token{{Kind: wall.LEFTBRACKET}, {Kind: wall.INTEGER, Content: "1"}, {Kind: wall.COMMA}, {Kind: wall.INTEGER, Content: "2"}, {Kind: wall.RIGHTBRACKET}})
+	got, err := pr.ParseExprAndEof()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &wall.ParsedArrayLiteralExpr{
+			Left: wall.Token{Kind: wall.LEFTBRACKET},
+			Elems: []wall.ParsedExpr{
+				&wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "1"}},
+				&wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "2"}},
+			},
+			Right: wall.Token{Kind: wall.RIGHTBRACKET},
+		}, got)
+	}
+}
+
+func TestParseTypedArrayLiteralExpr(t *testing.T) {
+	pr := wall.NewParser([]wall.Token{{Kind: wall.LEFTBRACKET}, {Kind: wall.INTEGER, Content: "4"}, {Kind: wall.RIGHTBRACKET}, {Kind: wall.IDENTIFIER, Content: "int32"}, {Kind: wall.LEFTBRACE}, {Kind: wall.INTEGER, Content: "1"}, {Kind: wall.RIGHTBRACE}})
+	got, err := pr.ParseExprAndEof()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &wall.ParsedArrayLiteralExpr{
+			Type: &wall.ParsedArrayType{
+				Left:  wall.Token{Kind: wall.LEFTBRACKET},
+				Len:   &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "4"}},
+				Right: wall.Token{Kind: wall.RIGHTBRACKET},
+				Elem:  &wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "int32"}},
+			},
+			Left: wall.Token{Kind: wall.LEFTBRACE},
+			Elems: []wall.ParsedExpr{
+				&wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "1"}},
+			},
+			Right: wall.Token{Kind: wall.RIGHTBRACE},
+		}, got)
+	}
+}
+
+func TestParseIndexExpr(t *testing.T) {
+	pr := wall.NewParser([]wall.Token{{Kind: wall.IDENTIFIER, Content: "a"}, {Kind: wall.LEFTBRACKET}, {Kind: wall.INTEGER, Content: "0"}, {Kind: wall.RIGHTBRACKET}})
+	got, err := pr.ParseExprAndEof()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &wall.ParsedIndexExpr{
+			Object: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "a"}},
+			Left:   wall.Token{Kind: wall.LEFTBRACKET},
+			Index:  &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "0"}},
+			Right:  wall.Token{Kind: wall.RIGHTBRACKET},
+		}, got)
+	}
+}
+
+func TestParseSliceExpr(t *testing.T) {
+	pr := wall.NewParser([]wall.Token{{Kind: wall.IDENTIFIER, Content: "a"}, {Kind: wall.LEFTBRACKET}, {Kind: wall.COLON}, {Kind: wall.INTEGER, Content: "2"}, {Kind: wall.RIGHTBRACKET}})
+	got, err := pr.ParseExprAndEof()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &wall.ParsedSliceExpr{
+			Object: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "a"}},
+			Left:   wall.Token{Kind: wall.LEFTBRACKET},
+			Colon:  wall.Token{Kind: wall.COLON},
+			High:   &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "2"}},
+			Right:  wall.Token{Kind: wall.RIGHTBRACKET},
+		}, got)
+	}
+	pr = wall.NewParser([]wall.Token{{Kind: wall.IDENTIFIER, Content: "a"}, {Kind: wall.LEFTBRACKET}, {Kind: wall.INTEGER, Content: "1"}, {Kind: wall.COLON}, {Kind: wall.RIGHTBRACKET}})
+	got, err = pr.ParseExprAndEof()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &wall.ParsedSliceExpr{
+			Object: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "a"}},
+			Left:   wall.Token{Kind: wall.LEFTBRACKET},
+			Low:    &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "1"}},
+			Colon:  wall.Token{Kind: wall.COLON},
+			Right:  wall.Token{Kind: wall.RIGHTBRACKET},
+		}, got)
+	}
+}
+
+func TestParseSliceType(t *testing.T) {
+	pr := wall.NewParser([]wall.Token{{Kind: wall.FUN}, {Kind: wall.IDENTIFIER, Content: "f"}, {Kind: wall.LEFTPAREN}, {Kind: wall.IDENTIFIER, Content: "xs"}, {Kind: wall.LEFTBRACKET}, {Kind: wall.RIGHTBRACKET}, {Kind: wall.IDENTIFIER, Content: "int32"}, {Kind: wall.RIGHTPAREN}, {Kind: wall.LEFTBRACE}, {Kind: wall.RIGHTBRACE}})
+	got, err := pr.ParseDefAndEof()
+	if assert.NoError(t, err) {
+		assert.Equal(t, []wall.ParsedFunParam{
+			{
+				Id: wall.Token{Kind: wall.IDENTIFIER, Content: "xs"},
+				Type: &wall.ParsedSliceType{
+					Left:  wall.Token{Kind: wall.LEFTBRACKET},
+					Right: wall.Token{Kind: wall.RIGHTBRACKET},
+					Elem:  &wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "int32"}},
+				},
+			},
+		}, got.(*wall.ParsedFunDef).Params)
+	}
+}
+
+func TestParseEnumDef(t *testing.T) {
+	pr := wall.NewParser([]wall.Token{{Kind: wall.ENUM}, {Kind: wall.IDENTIFIER, Content: "Color"}, {Kind: wall.LEFTBRACE}, {Kind: wall.IDENTIFIER, Content: "Red"}, {Kind: wall.COMMA}, {Kind: wall.NEWLINE}, {Kind: wall.IDENTIFIER, Content: "Green"}, {Kind: wall.EQ}, {Kind: wall.INTEGER, Content: "5"}, {Kind: wall.NEWLINE}, {Kind: wall.RIGHTBRACE}})
+	got, err := pr.ParseDefAndEof()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &wall.ParsedEnumDef{
+			Enum: wall.Token{Kind: wall.ENUM},
+			Name: wall.Token{Kind: wall.IDENTIFIER, Content: "Color"},
+			Variants: []wall.ParsedEnumVariant{
+				{Name: wall.Token{Kind: wall.IDENTIFIER, Content: "Red"}},
+				{
+					Name:  wall.Token{Kind: wall.IDENTIFIER, Content: "Green"},
+					Eq:    &wall.Token{Kind: wall.EQ},
+					Value: &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "5"}},
+				},
+			},
+		}, got)
+	}
+}
+
+func TestParseEnumPayload(t *testing.T) {
+	pr := wall.NewParser([]wall.Token{{Kind: wall.ENUM}, {Kind: wall.IDENTIFIER, Content: "Shape"}, {Kind: wall.LEFTBRACE}, {Kind: wall.IDENTIFIER, Content: "Rect"}, {Kind: wall.LEFTPAREN}, {Kind: wall.IDENTIFIER, Content: "float64"}, {Kind: wall.COMMA}, {Kind: wall.IDENTIFIER, Content: "float64"}, {Kind: wall.RIGHTPAREN}, {Kind: wall.COMMA}, {Kind: wall.IDENTIFIER, Content: "Empty"}, {Kind: wall.RIGHTBRACE}})
+	got, err := pr.ParseDefAndEof()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &wall.ParsedEnumDef{
+			Enum: wall.Token{Kind: wall.ENUM},
+			Name: wall.Token{Kind: wall.IDENTIFIER, Content: "Shape"},
+			Variants: []wall.ParsedEnumVariant{
+				{
+					Name: wall.Token{Kind: wall.IDENTIFIER, Content: "Rect"},
+					Payload: []wall.ParsedType{
+						&wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "float64"}},
+						&wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "float64"}},
+					},
+				},
+				{Name: wall.Token{Kind: wall.IDENTIFIER, Content: "Empty"}},
+			},
+		}, got)
+	}
+}
+
+func TestParseMatch(t *testing.T) {
+	pr := wall.NewParser([]wall.Token{{Kind: wall.MATCH}, {Kind: wall.IDENTIFIER, Content: "s"}, {Kind: wall.LEFTBRACE}, {Kind: wall.NEWLINE}, {Kind: wall.IDENTIFIER, Content: "Rect"}, {Kind: wall.LEFTPAREN}, {Kind: wall.IDENTIFIER, Content: "w"}, {Kind: wall.COMMA}, {Kind: wall.IDENTIFIER, Content: "h"}, {Kind: wall.RIGHTPAREN}, {Kind: wall.LEFTBRACE}, {Kind: wall.RIGHTBRACE}, {Kind: wall.NEWLINE}, {Kind: wall.IDENTIFIER, Content: "Empty"}, {Kind: wall.LEFTBRACE}, {Kind: wall.RIGHTBRACE}, {Kind: wall.NEWLINE}, {Kind: wall.ELSE}, {Kind: wall.LEFTBRACE}, {Kind: wall.RIGHTBRACE}, {Kind: wall.NEWLINE}, {Kind: wall.RIGHTBRACE}})
+	got, err := pr.ParseStmtAndEof()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &wall.ParsedMatch{
+			Match: wall.Token{Kind: wall.MATCH},
+			Value: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "s"}},
+			Arms: []wall.ParsedMatchArm{
+				{
+					Variant:  wall.Token{Kind: wall.IDENTIFIER, Content: "Rect"},
+					Bindings: []wall.Token{{Kind: wall.IDENTIFIER, Content: "w"}, {Kind: wall.IDENTIFIER, Content: "h"}},
+					Body:     &wall.ParsedBlock{Left: wall.Token{Kind: wall.LEFTBRACE}, Stmts: []wall.ParsedStmt{}, Right: wall.Token{Kind: wall.RIGHTBRACE}},
+				},
+				{
+					Variant: wall.Token{Kind: wall.IDENTIFIER, Content: "Empty"},
+					Body:    &wall.ParsedBlock{Left: wall.Token{Kind: wall.LEFTBRACE}, Stmts: []wall.ParsedStmt{}, Right: wall.Token{Kind: wall.RIGHTBRACE}},
+				},
+			},
+			ElseBody: &wall.ParsedBlock{Left: wall.Token{Kind: wall.LEFTBRACE}, Stmts: []wall.ParsedStmt{}, Right: wall.Token{Kind: wall.RIGHTBRACE}},
+		}, got)
+	}
+	pr = wall.NewParser([]wall.Token{{Kind: wall.MATCH}, {Kind: wall.IDENTIFIER, Content: "s"}, {Kind: wall.LEFTBRACE}, {Kind: wall.NEWLINE}, {Kind: wall.IDENTIFIER, Content: "Empty"}, {Kind: wall.NEWLINE}, {Kind: wall.RIGHTBRACE}})
+	_, err = pr.ParseStmtAndEof()
+	assert.Error(t, err)
+}
+
+func TestParseGenericStructDef(t *testing.T) {
+	pr := wall.NewParser([]wall.Token{{Kind: wall.STRUCT}, {Kind: wall.IDENTIFIER, Content: "Pair"}, {Kind: wall.LEFTBRACKET}, {Kind: wall.IDENTIFIER, Content: "A"}, {Kind: wall.COMMA}, {Kind: wall.IDENTIFIER, Content: "B"}, {Kind: wall.RIGHTBRACKET}, {Kind: wall.LEFTBRACE}, {Kind: wall.NEWLINE}, {Kind: wall.IDENTIFIER, Content: "first"}, {Kind: wall.IDENTIFIER, Content: "A"}, {Kind: wall.NEWLINE}, {Kind: wall.RIGHTBRACE}})
+	got, err := pr.ParseDefAndEof()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &wall.ParsedStructDef{
+			Struct:     wall.Token{Kind: wall.STRUCT},
+			Name:       wall.Token{Kind: wall.IDENTIFIER, Content: "Pair"},
+			TypeParams: []wall.Token{{Kind: wall.IDENTIFIER, Content: "A"}, {Kind: wall.IDENTIFIER, Content: "B"}},
+			Fields: []wall.ParsedStructField{
+				{
+					Name: wall.Token{Kind: wall.IDENTIFIER, Content: "first"},
+					Type: &wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "A"}},
+				},
+			},
+		}, got)
+	}
+}
+
+func TestParseGenericFunDef(t *testing.T) {
+	pr := wall.NewParser([]wall.Token{{Kind: wall.FUN}, {Kind: wall.IDENTIFIER, Content: "id"}, {Kind: wall.LEFTBRACKET}, {Kind: wall.IDENTIFIER, Content: "T"}, {Kind: wall.RIGHTBRACKET}, {Kind: wall.LEFTPAREN}, {Kind: wall.IDENTIFIER, Content: "x"}, {Kind: wall.IDENTIFIER, Content: "T"}, {Kind: wall.RIGHTPAREN}, {Kind: wall.IDENTIFIER, Content: "T"}, {Kind: wall.LEFTBRACE}, {Kind: wall.RIGHTBRACE}})
+	got, err := pr.ParseDefAndEof()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &wall.ParsedFunDef{
+			Fun:        wall.Token{Kind: wall.FUN},
+			Id:         wall.Token{Kind: wall.IDENTIFIER, Content: "id"},
+			TypeParams: []wall.Token{{Kind: wall.IDENTIFIER, Content: "T"}},
+			Params: []wall.ParsedFunParam{
+				{
+					Id:   wall.Token{Kind: wall.IDENTIFIER, Content: "x"},
+					Type: &wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "T"}},
+				},
+			},
+			ReturnType: &wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "T"}},
+			Body:       &wall.ParsedBlock{Left: wall.Token{Kind: wall.LEFTBRACE}, Stmts: []wall.ParsedStmt{}, Right: wall.Token{Kind: wall.RIGHTBRACE}},
+		}, got)
+	}
+	pr = wall.NewParser([]wall.Token{{Kind: wall.FUN}, {Kind: wall.IDENTIFIER, Content: "Box"}, {Kind: wall.LEFTBRACKET}, {Kind: wall.IDENTIFIER, Content: "T"}, {Kind: wall.RIGHTBRACKET}, {Kind: wall.DOT}, {Kind: wall.IDENTIFIER, Content: "get"}, {Kind: wall.LEFTPAREN}, {Kind: wall.RIGHTPAREN}, {Kind: wall.LEFTBRACE}, {Kind: wall.RIGHTBRACE}})
+	got, err = pr.ParseDefAndEof()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &wall.ParsedFunDef{
+			Fun:        wall.Token{Kind: wall.FUN},
+			Typename:   &wall.Token{Kind: wall.IDENTIFIER, Content: "Box"},
+			TypeParams: []wall.Token{{Kind: wall.IDENTIFIER, Content: "T"}},
+			Dot:        &wall.Token{Kind: wall.DOT},
+			Id:         wall.Token{Kind: wall.IDENTIFIER, Content: "get"},
+			Params:     []wall.ParsedFunParam{},
+			Body:       &wall.ParsedBlock{Left: wall.Token{Kind: wall.LEFTBRACE}, Stmts: []wall.ParsedStmt{}, Right: wall.Token{Kind: wall.RIGHTBRACE}},
+		}, got)
+	}
+}
+
+func TestParseGenericType(t *testing.T) {
+	pr := wall.NewParser([]wall.Token{{Kind: wall.IDENTIFIER, Content: "x"}, {Kind: wall.AS}, {Kind: wall.IDENTIFIER, Content: "Pair"}, {Kind: wall.LEFTBRACKET}, {Kind: wall.IDENTIFIER, Content: "int32"}, {Kind: wall.COMMA}, {Kind: wall.STAR}, {Kind: wall.IDENTIFIER, Content: "char"}, {Kind: wall.RIGHTBRACKET}})
+	got, err := pr.ParseExprAndEof()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &wall.ParsedGenericType{
+			Type: &wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "Pair"}},
+			Left: wall.Token{Kind: wall.LEFTBRACKET},
+			Args: []wall.ParsedType{
+				&wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "int32"}},
+				&wall.ParsedPointerType{Star: wall.Token{Kind: wall.STAR}, To: &wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "char"}}},
+			},
+			Right: wall.Token{Kind: wall.RIGHTBRACKET},
+		}, got.(*wall.ParsedAsExpr).Type)
+	}
+}
+
+func TestParseTypeArgsExpr(t *testing.T) {
+	pr := wall.NewParser([]wall.Token{{Kind: wall.IDENTIFIER, Content: "max"}, {Kind: wall.LEFTBRACKET}, {Kind: wall.IDENTIFIER, Content: "int32"}, {Kind: wall.RIGHTBRACKET}, {Kind: wall.LEFTPAREN}, {Kind: wall.IDENTIFIER, Content: "a"}, {Kind: wall.RIGHTPAREN}})
+	got, err := pr.ParseExprAndEof()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &wall.ParsedCallExpr{
+			Callee: &wall.ParsedTypeArgsExpr{
+				Object: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "max"}},
+				Left:   wall.Token{Kind: wall.LEFTBRACKET},
+				Args:   []wall.ParsedType{&wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "int32"}}},
+				Right:  wall.Token{Kind: wall.RIGHTBRACKET},
+				Index: &wall.ParsedIndexExpr{
+					Object: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "max"}},
+					Left:   wall.Token{Kind: wall.LEFTBRACKET},
+					Index:  &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "int32"}},
+					Right:  wall.Token{Kind: wall.RIGHTBRACKET},
+				},
+			},
+			Args: []wall.ParsedExpr{&wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "a"}}},
+		}, got)
+	}
+	pr = wall.NewParser([]wall.Token{{Kind: wall.IDENTIFIER, Content: "fs"}, {Kind: wall.LEFTBRACKET}, {Kind: wall.STAR}, {Kind: wall.IDENTIFIER, Content: "p"}, {Kind: wall.RIGHTBRACKET}, {Kind: wall.LEFTPAREN}, {Kind: wall.RIGHTPAREN}})
+	got, err = pr.ParseExprAndEof()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &wall.ParsedIndexExpr{
+			Object: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "fs"}},
+			Left:   wall.Token{Kind: wall.LEFTBRACKET},
+			Index:  &wall.ParsedUnaryExpr{Operator: wall.Token{Kind: wall.STAR}, Operand: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "p"}}},
+			Right:  wall.Token{Kind: wall.RIGHTBRACKET},
+		}, got.(*wall.ParsedCallExpr).Callee.(*wall.ParsedTypeArgsExpr).Index)
+	}
+	pr = wall.NewParser([]wall.Token{{Kind: wall.IDENTIFIER, Content: "Box"}, {Kind: wall.LEFTBRACKET}, {Kind: wall.IDENTIFIER, Content: "int32"}, {Kind: wall.RIGHTBRACKET}, {Kind: wall.LEFTBRACE}, {Kind: wall.RIGHTBRACE}})
+	got, err = pr.ParseExprAndEof()
+	if assert.NoError(t, err) {
+		assert.IsType(t, &wall.ParsedGenericType{}, got.(*wall.ParsedStructInitExpr).Name)
+	}
+	pr = wall.NewParser([]wall.Token{{Kind: wall.IDENTIFIER, Content: "a"}, {Kind: wall.LEFTBRACKET}, {Kind: wall.IDENTIFIER, Content: "i"}, {Kind: wall.RIGHTBRACKET}})
+	got, err = pr.ParseExprAndEof()
+	if assert.NoError(t, err) {
+		assert.IsType(t, &wall.ParsedIndexExpr{}, got)
+	}
+}
+
+func TestParseTraitDef(t *testing.T) {
+	pr := wall.NewParser([]wall.Token{{Kind: wall.TRAIT}, {Kind: wall.IDENTIFIER, Content: "Shape"}, {Kind: wall.LEFTBRACE}, {Kind: wall.NEWLINE}, {Kind: wall.FUN}, {Kind: wall.IDENTIFIER, Content: "area"}, {Kind: wall.LEFTPAREN}, {Kind: wall.RIGHTPAREN}, {Kind: wall.IDENTIFIER, Content: "int32"}, {Kind: wall.NEWLINE}, {Kind: wall.FUN}, {Kind: wall.IDENTIFIER, Content: "scale"}, {Kind: wall.LEFTPAREN}, {Kind: wall.IDENTIFIER, Content: "by"}, {Kind: wall.IDENTIFIER, Content: "Self"}, {Kind: wall.RIGHTPAREN}, {Kind: wall.NEWLINE}, {Kind: wall.RIGHTBRACE}})
+	got, err := pr.ParseDefAndEof()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &wall.ParsedTraitDef{
+			Trait: wall.Token{Kind: wall.TRAIT},
+			Name:  wall.Token{Kind: wall.IDENTIFIER, Content: "Shape"},
+			Methods: []wall.ParsedTraitMethod{
+				{
+					Fun:        wall.Token{Kind: wall.FUN},
+					Id:         wall.Token{Kind: wall.IDENTIFIER, Content: "area"},
+					Params:     []wall.ParsedFunParam{},
+					ReturnType: &wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "int32"}},
+				},
+				{
+					Fun: wall.Token{Kind: wall.FUN},
+					Id:  wall.Token{Kind: wall.IDENTIFIER, Content: "scale"},
+					Params: []wall.ParsedFunParam{
+						{
+							Id:   wall.Token{Kind: wall.IDENTIFIER, Content: "by"},
+							Type: &wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "Self"}},
+						},
+					},
+				},
+			},
+		}, got)
+	}
+}
+
+func TestParseImplDef(t *testing.T) {
+	pr := wall.NewParser([]wall.Token{{Kind: wall.IMPL}, {Kind: wall.IDENTIFIER, Content: "shapes"}, {Kind: wall.COLONCOLON}, {Kind: wall.IDENTIFIER, Content: "Shape"}, {Kind: wall.FOR}, {Kind: wall.IDENTIFIER, Content: "Square"}, {Kind: wall.LEFTBRACE}, {Kind: wall.NEWLINE}, {Kind: wall.FUN}, {Kind: wall.IDENTIFIER, Content: "area"}, {Kind: wall.LEFTPAREN}, {Kind: wall.RIGHTPAREN}, {Kind: wall.LEFTBRACE}, {Kind: wall.RIGHTBRACE}, {Kind: wall.NEWLINE}, {Kind: wall.RIGHTBRACE}})
+	got, err := pr.ParseDefAndEof()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &wall.ParsedImplDef{
+			Impl: wall.Token{Kind: wall.IMPL},
+			Trait: &wall.ParsedModuleAccessType{
+				Module:     wall.Token{Kind: wall.IDENTIFIER, Content: "shapes"},
+				Coloncolon: wall.Token{Kind: wall.COLONCOLON},
+				Member:     &wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "Shape"}},
+			},
+			For:      wall.Token{Kind: wall.FOR},
+			Typename: wall.Token{Kind: wall.IDENTIFIER, Content: "Square"},
+			Methods: []*wall.ParsedFunDef{
+				{
+					Fun:      wall.Token{Kind: wall.FUN},
+					Typename: &wall.Token{Kind: wall.IDENTIFIER, Content: "Square"},
+					Id:       wall.Token{Kind: wall.IDENTIFIER, Content: "area"},
+					Params:   []wall.ParsedFunParam{},
+					Body:     &wall.ParsedBlock{Left: wall.Token{Kind: wall.LEFTBRACE}, Stmts: []wall.ParsedStmt{}, Right: wall.Token{Kind: wall.RIGHTBRACE}},
+				},
+			},
+		}, got)
+	}
+	pr = wall.NewParser([]wall.Token{{Kind: wall.IMPL}, {Kind: wall.IDENTIFIER, Content: "Shape"}, {Kind: wall.FOR}, {Kind: wall.IDENTIFIER, Content: "Square"}, {Kind: wall.LEFTBRACE}, {Kind: wall.FUN}, {Kind: wall.IDENTIFIER, Content: "Square"}, {Kind: wall.DOT}, {Kind: wall.IDENTIFIER, Content: "area"}, {Kind: wall.LEFTPAREN}, {Kind: wall.RIGHTPAREN}, {Kind: wall.LEFTBRACE}, {Kind: wall.RIGHTBRACE}, {Kind: wall.RIGHTBRACE}})
+	_, err = pr.ParseDefAndEof()
+	assert.Error(t, err)
+}
+
+func TestParseOptionalType(t *testing.T) {
+	pr := wall.NewParser([]wall.Token{{Kind: wall.NULL}, {Kind: wall.AS}, {Kind: wall.QUESTION}, {Kind: wall.STAR}, {Kind: wall.IDENTIFIER, Content: "char"}})
+	got, err := pr.ParseExprAndEof()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &wall.ParsedAsExpr{
+			Value: &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.NULL}},
+			As:    wall.Token{Kind: wall.AS},
+			Type: &wall.ParsedOptionalType{
+				Question: wall.Token{Kind: wall.QUESTION},
+				Elem:     &wall.ParsedPointerType{Star: wall.Token{Kind: wall.STAR}, To: &wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "char"}}},
+			},
+		}, got)
+	}
+}
+
+func TestParseResultType(t *testing.T) {
+	pr := wall.NewParser([]wall.Token{{Kind: wall.IDENTIFIER, Content: "x"}, {Kind: wall.AS}, {Kind: wall.STAR}, {Kind: wall.IDENTIFIER, Content: "char"}, {Kind: wall.BANG}, {Kind: wall.IDENTIFIER, Content: "Error"}})
+	got, err := pr.ParseExprAndEof()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &wall.ParsedResultType{
+			Value: &wall.ParsedPointerType{Star: wall.Token{Kind: wall.STAR}, To: &wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "char"}}},
+			Bang:  wall.Token{Kind: wall.BANG},
+			Error: &wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "Error"}},
+		}, got.(*wall.ParsedAsExpr).Type)
+	}
+}
+
+func TestParsePropagateExpr(t *testing.T) {
+	pr := wall.NewParser([]wall.Token{{Kind: wall.IDENTIFIER, Content: "f"}, {Kind: wall.LEFTPAREN}, {Kind: wall.RIGHTPAREN}, {Kind: wall.QUESTION}, {Kind: wall.PLUS}, {Kind: wall.INTEGER, Content: "1"}})
+	got, err := pr.ParseExprAndEof()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &wall.ParsedBinaryExpr{
+			Left: &wall.ParsedPropagateExpr{
+				Value: &wall.ParsedCallExpr{
+					Callee: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "f"}},
+					Args:   []wall.ParsedExpr{},
+				},
+				Question: wall.Token{Kind: wall.QUESTION},
+			},
+			Op:    wall.Token{Kind: wall.PLUS},
+			Right: &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "1"}},
+		}, got)
+	}
+}
+
+func TestParseFunExpr(t *testing.T) {
+	pr := wall.NewParser([]wall.Token{{Kind: wall.FUN}, {Kind: wall.LEFTPAREN}, {Kind: wall.IDENTIFIER, Content: "x"}, {Kind: wall.IDENTIFIER, Content: "int32"}, {Kind: wall.RIGHTPAREN}, {Kind: wall.IDENTIFIER, Content: "int32"}, {Kind: wall.LEFTBRACE}, {Kind: wall.RETURN}, {Kind: wall.IDENTIFIER, Content: "x"}, {Kind: wall.NEWLINE}, {Kind: wall.RIGHTBRACE}})
+	got, err := pr.ParseExprAndEof()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &wall.ParsedFunExpr{
+			Fun: wall.Token{Kind: wall.FUN},
+			Params: []wall.ParsedFunParam{
+				{
+					Id:   wall.Token{Kind: wall.IDENTIFIER, Content: "x"},
+					Type: &wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "int32"}},
+				},
+			},
+			ReturnType: &wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "int32"}},
+			Body: &wall.ParsedBlock{
+				Left: wall.Token{Kind: wall.LEFTBRACE},
+				Stmts: []wall.ParsedStmt{
+					&wall.ParsedReturn{
+						Return: wall.Token{Kind: wall.RETURN},
+						Arg:    &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "x"}},
+					},
+				},
+				Right: wall.Token{Kind: wall.RIGHTBRACE},
+			},
+		}, got)
+	}
+}
+
+func TestParseFunType(t *testing.T) {
+	pr := wall.NewParser([]wall.Token{{Kind: wall.IDENTIFIER, Content: "x"}, {Kind: wall.AS}, {Kind: wall.FUN}, {Kind: wall.LEFTPAREN}, {Kind: wall.IDENTIFIER, Content: "int32"}, {Kind: wall.COMMA}, {Kind: wall.FUN}, {Kind: wall.LEFTPAREN}, {Kind: wall.RIGHTPAREN}, {Kind: wall.RIGHTPAREN}, {Kind: wall.STAR}, {Kind: wall.IDENTIFIER, Content: "char"}})
+	got, err := pr.ParseExprAndEof()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &wall.ParsedFunType{
+			Fun:  wall.Token{Kind: wall.FUN},
+			Left: wall.Token{Kind: wall.LEFTPAREN},
+			Params: []wall.ParsedType{
+				&wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "int32"}},
+				&wall.ParsedFunType{
+					Fun:    wall.Token{Kind: wall.FUN},
+					Left:   wall.Token{Kind: wall.LEFTPAREN},
+					Params: []wall.ParsedType{},
+					Right:  wall.Token{Kind: wall.RIGHTPAREN},
+				},
+			},
+			Right:   wall.Token{Kind: wall.RIGHTPAREN},
+			Returns: &wall.ParsedPointerType{Star: wall.Token{Kind: wall.STAR}, To: &wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "char"}}},
+		}, got.(*wall.ParsedAsExpr).Type)
+	}
+}
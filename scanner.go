@@ -2,7 +2,7 @@ package wall
 
 import (
 	"fmt"
-	"strings"
+	"unicode/utf8"
 
 	"github.com/cznic/mathutil"
 )
@@ -16,6 +16,10 @@ const (
 	INTEGER
 	STRING
 	FLOAT
+	CHARACTER
+	STRINGHEAD
+	STRINGMIDDLE
+	STRINGTAIL
 	PLUS
 	MINUS
 	STAR
@@ -37,6 +41,30 @@ const (
 	GT
 	GTEQ
 	AMP
+	LEFTBRACKET
+	RIGHTBRACKET
+	AMPAMP
+	PIPEPIPE
+	BANG
+	PERCENT
+	PIPE
+	CARET
+	TILDE
+	LTLT
+	GTGT
+	PLUSEQ
+	MINUSEQ
+	STAREQ
+	SLASHEQ
+	PERCENTEQ
+	AMPEQ
+	PIPEEQ
+	CARETEQ
+	LTLTEQ
+	GTGTEQ
+	QUESTION
+	SEMICOLON
+	DOTDOT
 
 	// keywords
 	FUN
@@ -54,6 +82,17 @@ const (
 	CONTINUE
 	TYPEALIAS
 	MUT
+	ENUM
+	MATCH
+	TRAIT
+	IMPL
+	FOR
+	DEFER
+	NULL
+	CONST
+	VAR
+	UNSAFE
+	IN
 )
 
 func (t TokenKind) String() string {
@@ -70,6 +109,14 @@ func (t TokenKind) String() string {
 		return "FLOAT"
 	case STRING:
 		return "STRING"
+	case CHARACTER:
+		return "CHARACTER"
+	case STRINGHEAD:
+		return "STRINGHEAD"
+	case STRINGMIDDLE:
+		return "STRINGMIDDLE"
+	case STRINGTAIL:
+		return "STRINGTAIL"
 	case PLUS:
 		return "+"
 	case MINUS:
@@ -112,6 +159,54 @@ func (t TokenKind) String() string {
 		return ">="
 	case AMP:
 		return "&"
+	case LEFTBRACKET:
+		return "["
+	case RIGHTBRACKET:
+		return "]"
+	case AMPAMP:
+		return "&&"
+	case PIPEPIPE:
+		return "||"
+	case BANG:
+		return "!"
+	case PERCENT:
+		return "%"
+	case PIPE:
+		return "|"
+	case CARET:
+		return "^"
+	case TILDE:
+		return "~"
+	case LTLT:
+		return "<<"
+	case GTGT:
+		return ">>"
+	case PLUSEQ:
+		return "+="
+	case MINUSEQ:
+		return "-="
+	case STAREQ:
+		return "*="
+	case SLASHEQ:
+		return "/="
+	case PERCENTEQ:
+		return "%="
+	case AMPEQ:
+		return "&="
+	case PIPEEQ:
+		return "|="
+	case CARETEQ:
+		return "^="
+	case LTLTEQ:
+		return "<<="
+	case GTGTEQ:
+		return ">>="
+	case QUESTION:
+		return "?"
+	case SEMICOLON:
+		return ";"
+	case DOTDOT:
+		return ".."
 	case FUN:
 		return "FUN"
 	case IMPORT:
@@ -142,6 +237,28 @@ func (t TokenKind) String() string {
 		return "TYPEALIAS"
 	case MUT:
 		return "MUT"
+	case ENUM:
+		return "ENUM"
+	case MATCH:
+		return "MATCH"
+	case TRAIT:
+		return "TRAIT"
+	case IMPL:
+		return "IMPL"
+	case FOR:
+		return "FOR"
+	case DEFER:
+		return "DEFER"
+	case NULL:
+		return "NULL"
+	case CONST:
+		return "CONST"
+	case VAR:
+		return "VAR"
+	case UNSAFE:
+		return "UNSAFE"
+	case IN:
+		return "IN"
 	}
 	panic("unreachable")
 }
@@ -178,10 +295,11 @@ func ScanTokens(filename string, source string) ([]Token, error) {
 }
 
 type Scanner struct {
-	pos    Pos
-	source string
-	start  int
-	end    int
+	pos            Pos
+	source         string
+	start          int
+	end            int
+	interpolations []int
 }
 
 func NewScanner(filename string, source string) Scanner {
@@ -212,16 +330,16 @@ func (s *Scanner) Scan() (Token, error) {
 		t = s.token(NEWLINE)
 	case '+':
 		s.advance()
-		t = s.token(PLUS)
+		t = s.tokenOrAssign(PLUS, PLUSEQ)
 	case '-':
 		s.advance()
-		t = s.token(MINUS)
+		t = s.tokenOrAssign(MINUS, MINUSEQ)
 	case '*':
 		s.advance()
-		t = s.token(STAR)
+		t = s.tokenOrAssign(STAR, STAREQ)
 	case '/':
 		s.advance()
-		t = s.token(SLASH)
+		t = s.tokenOrAssign(SLASH, SLASHEQ)
 	case '(':
 		s.advance()
 		t = s.token(LEFTPAREN)
@@ -230,10 +348,26 @@ func (s *Scanner) Scan() (Token, error) {
 		t = s.token(RIGHTPAREN)
 	case '{':
 		s.advance()
+		if n := len(s.interpolations); n > 0 {
+			s.interpolations[n-1]++
+		}
 		t = s.token(LEFTBRACE)
 	case '}':
 		s.advance()
+		if n := len(s.interpolations); n > 0 {
+			if s.interpolations[n-1] == 0 {
+				s.interpolations = s.interpolations[:n-1]
+				return s.string(STRINGMIDDLE, STRINGTAIL)
+			}
+			s.interpolations[n-1]--
+		}
 		t = s.token(RIGHTBRACE)
+	case '[':
+		s.advance()
+		t = s.token(LEFTBRACKET)
+	case ']':
+		s.advance()
+		t = s.token(RIGHTBRACKET)
 	case '=':
 		s.advance()
 		if s.next() == '=' {
@@ -248,13 +382,16 @@ func (s *Scanner) Scan() (Token, error) {
 			s.advance()
 			t = s.token(BANGEQ)
 		} else {
-			return s.token(EOF), NewError(s.pos, "unexpected character: %c", c)
+			t = s.token(BANG)
 		}
 	case '<':
 		s.advance()
 		if s.next() == '=' {
 			s.advance()
 			t = s.token(LTEQ)
+		} else if s.next() == '<' {
+			s.advance()
+			t = s.tokenOrAssign(LTLT, LTLTEQ)
 		} else {
 			t = s.token(LT)
 		}
@@ -263,6 +400,9 @@ func (s *Scanner) Scan() (Token, error) {
 		if s.next() == '=' {
 			s.advance()
 			t = s.token(GTEQ)
+		} else if s.next() == '>' {
+			s.advance()
+			t = s.tokenOrAssign(GTGT, GTGTEQ)
 		} else {
 			t = s.token(GT)
 		}
@@ -282,13 +422,52 @@ func (s *Scanner) Scan() (Token, error) {
 		}
 	case '.':
 		s.advance()
-		t = s.token(DOT)
+		if s.next() == '.' {
+			s.advance()
+			t = s.token(DOTDOT)
+		} else {
+			t = s.token(DOT)
+		}
+	case ';':
+		s.advance()
+		t = s.token(SEMICOLON)
 	case '"':
 		s.advance()
-		return s.string()
+		return s.string(STRINGHEAD, STRING)
+	case '`':
+		s.advance()
+		return s.rawString()
+	case '\'':
+		s.advance()
+		return s.char()
 	case '&':
 		s.advance()
-		t = s.token(AMP)
+		if s.next() == '&' {
+			s.advance()
+			t = s.token(AMPAMP)
+		} else {
+			t = s.tokenOrAssign(AMP, AMPEQ)
+		}
+	case '|':
+		s.advance()
+		if s.next() == '|' {
+			s.advance()
+			t = s.token(PIPEPIPE)
+		} else {
+			t = s.tokenOrAssign(PIPE, PIPEEQ)
+		}
+	case '%':
+		s.advance()
+		t = s.tokenOrAssign(PERCENT, PERCENTEQ)
+	case '^':
+		s.advance()
+		t = s.tokenOrAssign(CARET, CARETEQ)
+	case '~':
+		s.advance()
+		t = s.token(TILDE)
+	case '?':
+		s.advance()
+		t = s.token(QUESTION)
 	default:
 		if isId(c) {
 			return s.id(), nil
@@ -349,57 +528,207 @@ func (s *Scanner) id() Token {
 		t.Kind = TYPEALIAS
 	case "mut":
 		t.Kind = MUT
+	case "enum":
+		t.Kind = ENUM
+	case "match":
+		t.Kind = MATCH
+	case "trait":
+		t.Kind = TRAIT
+	case "impl":
+		t.Kind = IMPL
+	case "for":
+		t.Kind = FOR
+	case "defer":
+		t.Kind = DEFER
+	case "null":
+		t.Kind = NULL
+	case "const":
+		t.Kind = CONST
+	case "var":
+		t.Kind = VAR
+	case "unsafe":
+		t.Kind = UNSAFE
+	case "in":
+		t.Kind = IN
 	}
 	return t
 }
 
 func (s *Scanner) num() Token {
-	for isNum(s.next()) {
+	if s.next() == '0' && isBasePrefix(s.peek(1)) {
 		s.advance()
+		s.advance()
+		s.suffix()
+		return s.token(INTEGER)
+	}
+	kind := INTEGER
+	s.digits()
+	if s.next() == '.' && s.peek(1) != '.' {
+		kind = FLOAT
+		s.advance()
+		s.digits()
 	}
-	if s.next() == '.' {
+	if (s.next() == 'e' || s.next() == 'E') && (isNum(s.peek(1)) || ((s.peek(1) == '+' || s.peek(1) == '-') && isNum(s.peek(2)))) {
+		kind = FLOAT
 		s.advance()
-		for isNum(s.next()) {
+		if !isNum(s.next()) {
 			s.advance()
 		}
-		return s.token(FLOAT)
+		s.digits()
+	}
+	s.suffix()
+	return s.token(kind)
+}
+
+func (s *Scanner) digits() {
+	for isNum(s.next()) || s.next() == '_' {
+		s.advance()
 	}
-	return s.token(INTEGER)
 }
 
-func escapeChar(c byte) bool {
-	return c == 'a' || c == 'b' || c == 'f' || c == 'n' || c == 'r' || c == 't' || c == 'v' || c == '\\' || c == '"'
+func (s *Scanner) suffix() {
+	for isId(s.next()) || isNum(s.next()) {
+		s.advance()
+	}
 }
 
-func (s *Scanner) string() (Token, error) {
-	for s.next() != '"' && s.next() != 0 {
-		if s.next() == '\\' {
-			s.advance()
-			if !escapeChar(s.next()) {
-				return Token{}, NewError(s.pos, "invalid escape character: %c", s.next())
+func isBasePrefix(c byte) bool {
+	return c == 'x' || c == 'X' || c == 'b' || c == 'B' || c == 'o' || c == 'O'
+}
+
+func (s *Scanner) escape() ([]byte, error) {
+	switch escape := s.advance(); escape {
+	case 'a':
+		return []byte{'\a'}, nil
+	case 'b':
+		return []byte{'\b'}, nil
+	case 'f':
+		return []byte{'\f'}, nil
+	case 'n':
+		return []byte{'\n'}, nil
+	case 'r':
+		return []byte{'\r'}, nil
+	case 't':
+		return []byte{'\t'}, nil
+	case 'v':
+		return []byte{'\v'}, nil
+	case '0':
+		return []byte{0}, nil
+	case '\\', '\'', '"', '{', '}':
+		return []byte{escape}, nil
+	case 'x':
+		hi, lo := hexDigit(s.advance()), hexDigit(s.advance())
+		if hi < 0 || lo < 0 {
+			return nil, NewError(s.pos, "invalid hex escape")
+		}
+		return []byte{byte(hi<<4 | lo)}, nil
+	case 'u':
+		if s.advance() != '{' {
+			return nil, NewError(s.pos, "invalid unicode escape: expected {")
+		}
+		r, digits := rune(0), 0
+		for s.next() != '}' {
+			digit := hexDigit(s.advance())
+			if digit < 0 || digits == 6 {
+				return nil, NewError(s.pos, "invalid unicode escape")
 			}
-			s.advance()
-			continue
+			r = r<<4 | rune(digit)
+			digits++
+		}
+		s.advance()
+		if digits == 0 || !utf8.ValidRune(r) {
+			return nil, NewError(s.pos, "invalid unicode escape")
+		}
+		return utf8.AppendRune(nil, r), nil
+	default:
+		return nil, NewError(s.pos, "invalid escape character: %c", escape)
+	}
+}
+
+func (s *Scanner) char() (Token, error) {
+	var c byte
+	switch s.next() {
+	case '\'', '\n', 0:
+		return Token{}, NewError(s.pos, "empty character literal")
+	case '\\':
+		s.advance()
+		bytes, err := s.escape()
+		if err != nil {
+			return Token{}, err
+		}
+		if len(bytes) != 1 {
+			return Token{}, NewError(s.pos, "a character literal must be a single byte")
 		}
-		if s.advance() == '\n' {
+		c = bytes[0]
+	default:
+		c = s.advance()
+	}
+	if s.next() != '\'' {
+		return Token{}, NewError(s.pos, "a character literal is not terminated")
+	}
+	s.advance()
+	t := s.token(CHARACTER)
+	t.Content = string([]byte{c})
+	return t, nil
+}
+
+func hexDigit(c byte) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0')
+	case c >= 'a' && c <= 'f':
+		return int(c-'a') + 10
+	case c >= 'A' && c <= 'F':
+		return int(c-'A') + 10
+	}
+	return -1
+}
+
+// string scans a string literal up to the closing quote, or up to the start
+// of an interpolation, in which case the scanner keeps track of the braces in
+// the interpolated expression and resumes the literal at its closing brace.
+func (s *Scanner) string(interpolation TokenKind, end TokenKind) (Token, error) {
+	var content []byte
+	for s.next() != '"' && s.next() != '{' {
+		switch s.next() {
+		case 0:
+			return Token{}, NewError(s.pos, "a string literal is not terminated")
+		case '\\':
+			s.advance()
+			bytes, err := s.escape()
+			if err != nil {
+				return Token{}, err
+			}
+			content = append(content, bytes...)
+		case '\n':
 			s.pos.Line++
+			fallthrough
+		default:
+			content = append(content, s.advance())
 		}
 	}
-	if s.next() == 0 {
-		return Token{}, NewError(s.pos, "a string literal is not terminated")
+	kind := end
+	if s.advance() == '{' {
+		kind = interpolation
+		s.interpolations = append(s.interpolations, 0)
+	}
+	t := s.token(kind)
+	t.Content = string(content)
+	return t, nil
+}
+
+func (s *Scanner) rawString() (Token, error) {
+	for s.next() != '`' {
+		switch s.advance() {
+		case 0:
+			return Token{}, NewError(s.pos, "a raw string literal is not terminated")
+		case '\n':
+			s.pos.Line++
+		}
 	}
 	s.advance()
 	t := s.token(STRING)
 	t.Content = t.Content[1 : len(t.Content)-1]
-	t.Content = strings.ReplaceAll(t.Content, "\\a", "\a")
-	t.Content = strings.ReplaceAll(t.Content, "\\b", "\b")
-	t.Content = strings.ReplaceAll(t.Content, "\\f", "\f")
-	t.Content = strings.ReplaceAll(t.Content, "\\n", "\n")
-	t.Content = strings.ReplaceAll(t.Content, "\\r", "\r")
-	t.Content = strings.ReplaceAll(t.Content, "\\t", "\t")
-	t.Content = strings.ReplaceAll(t.Content, "\\v", "\v")
-	t.Content = strings.ReplaceAll(t.Content, "\\\"", "\"")
-	t.Content = strings.ReplaceAll(t.Content, "\\\\", "\\")
 	return t, nil
 }
 
@@ -445,6 +774,14 @@ func (s *Scanner) advance() byte {
 	return c
 }
 
+func (s *Scanner) tokenOrAssign(t TokenKind, assign TokenKind) Token {
+	if s.next() == '=' {
+		s.advance()
+		return s.token(assign)
+	}
+	return s.token(t)
+}
+
 func (s *Scanner) token(t TokenKind) Token {
 	end := mathutil.Clamp(s.end, 0, len(s.source))
 	start := mathutil.Clamp(s.start, 0, len(s.source)-1)
@@ -21,6 +21,12 @@ var scanTokensTests = []scanTokensTest{
 	{"abc", []wall.TokenKind{wall.IDENTIFIER, wall.EOF}},
 	{"123", []wall.TokenKind{wall.INTEGER, wall.EOF}},
 	{"\"abc\"", []wall.TokenKind{wall.STRING, wall.EOF}},
+	{"'a'", []wall.TokenKind{wall.CHARACTER, wall.EOF}},
+	{"`abc`", []wall.TokenKind{wall.STRING, wall.EOF}},
+	{`"a{x}b"`, []wall.TokenKind{wall.STRINGHEAD, wall.IDENTIFIER, wall.STRINGTAIL, wall.EOF}},
+	{`"{x}{y}"`, []wall.TokenKind{wall.STRINGHEAD, wall.IDENTIFIER, wall.STRINGMIDDLE, wall.IDENTIFIER, wall.STRINGTAIL, wall.EOF}},
+	{`"{P{x: 1}.x}"`, []wall.TokenKind{wall.STRINGHEAD, wall.IDENTIFIER, wall.LEFTBRACE, wall.IDENTIFIER, wall.COLON, wall.INTEGER, wall.RIGHTBRACE, wall.DOT, wall.IDENTIFIER, wall.STRINGTAIL, wall.EOF}},
+	{`"{"{x}"}"`, []wall.TokenKind{wall.STRINGHEAD, wall.STRINGHEAD, wall.IDENTIFIER, wall.STRINGTAIL, wall.STRINGTAIL, wall.EOF}},
 	{"123*123", []wall.TokenKind{wall.INTEGER, wall.STAR, wall.INTEGER, wall.EOF}},
 	{"-1", []wall.TokenKind{wall.MINUS, wall.INTEGER, wall.EOF}},
 	{"0.0", []wall.TokenKind{wall.FLOAT, wall.EOF}},
@@ -30,6 +36,7 @@ var scanTokensTests = []scanTokensTest{
 	{"/", []wall.TokenKind{wall.SLASH, wall.EOF}},
 	{"()", []wall.TokenKind{wall.LEFTPAREN, wall.RIGHTPAREN, wall.EOF}},
 	{"{}", []wall.TokenKind{wall.LEFTBRACE, wall.RIGHTBRACE, wall.EOF}},
+	{"[]", []wall.TokenKind{wall.LEFTBRACKET, wall.RIGHTBRACKET, wall.EOF}},
 	{",", []wall.TokenKind{wall.COMMA, wall.EOF}},
 	{":", []wall.TokenKind{wall.COLON, wall.EOF}},
 	{"::", []wall.TokenKind{wall.COLONCOLON, wall.EOF}},
@@ -43,6 +50,31 @@ var scanTokensTests = []scanTokensTest{
 	{">", []wall.TokenKind{wall.GT, wall.EOF}},
 	{">=", []wall.TokenKind{wall.GTEQ, wall.EOF}},
 	{"&", []wall.TokenKind{wall.AMP, wall.EOF}},
+	{"&&", []wall.TokenKind{wall.AMPAMP, wall.EOF}},
+	{"||", []wall.TokenKind{wall.PIPEPIPE, wall.EOF}},
+	{"!", []wall.TokenKind{wall.BANG, wall.EOF}},
+	{"!a", []wall.TokenKind{wall.BANG, wall.IDENTIFIER, wall.EOF}},
+	{"%", []wall.TokenKind{wall.PERCENT, wall.EOF}},
+	{"|", []wall.TokenKind{wall.PIPE, wall.EOF}},
+	{"^", []wall.TokenKind{wall.CARET, wall.EOF}},
+	{"~", []wall.TokenKind{wall.TILDE, wall.EOF}},
+	{"<<", []wall.TokenKind{wall.LTLT, wall.EOF}},
+	{">>", []wall.TokenKind{wall.GTGT, wall.EOF}},
+	{"a&b", []wall.TokenKind{wall.IDENTIFIER, wall.AMP, wall.IDENTIFIER, wall.EOF}},
+	{"+=", []wall.TokenKind{wall.PLUSEQ, wall.EOF}},
+	{"-=", []wall.TokenKind{wall.MINUSEQ, wall.EOF}},
+	{"*=", []wall.TokenKind{wall.STAREQ, wall.EOF}},
+	{"/=", []wall.TokenKind{wall.SLASHEQ, wall.EOF}},
+	{"%=", []wall.TokenKind{wall.PERCENTEQ, wall.EOF}},
+	{"&=", []wall.TokenKind{wall.AMPEQ, wall.EOF}},
+	{"|=", []wall.TokenKind{wall.PIPEEQ, wall.EOF}},
+	{"^=", []wall.TokenKind{wall.CARETEQ, wall.EOF}},
+	{"<<=", []wall.TokenKind{wall.LTLTEQ, wall.EOF}},
+	{">>=", []wall.TokenKind{wall.GTGTEQ, wall.EOF}},
+	{"?", []wall.TokenKind{wall.QUESTION, wall.EOF}},
+	{";", []wall.TokenKind{wall.SEMICOLON, wall.EOF}},
+	{"0..n", []wall.TokenKind{wall.INTEGER, wall.DOTDOT, wall.IDENTIFIER, wall.EOF}},
+	{"for x in xs", []wall.TokenKind{wall.FOR, wall.IDENTIFIER, wall.IN, wall.IDENTIFIER, wall.EOF}},
 	{"fun", []wall.TokenKind{wall.FUN, wall.EOF}},
 	{"import", []wall.TokenKind{wall.IMPORT, wall.EOF}},
 	{"struct", []wall.TokenKind{wall.STRUCT, wall.EOF}},
@@ -58,6 +90,15 @@ var scanTokensTests = []scanTokensTest{
 	{"continue", []wall.TokenKind{wall.CONTINUE, wall.EOF}},
 	{"typealias", []wall.TokenKind{wall.TYPEALIAS, wall.EOF}},
 	{"mut", []wall.TokenKind{wall.MUT, wall.EOF}},
+	{"enum", []wall.TokenKind{wall.ENUM, wall.EOF}},
+	{"match", []wall.TokenKind{wall.MATCH, wall.EOF}},
+	{"trait", []wall.TokenKind{wall.TRAIT, wall.EOF}},
+	{"impl", []wall.TokenKind{wall.IMPL, wall.EOF}},
+	{"for", []wall.TokenKind{wall.FOR, wall.EOF}},
+	{"defer", []wall.TokenKind{wall.DEFER, wall.EOF}},
+	{"null", []wall.TokenKind{wall.NULL, wall.EOF}},
+	{"const", []wall.TokenKind{wall.CONST, wall.EOF}},
+	{"var", []wall.TokenKind{wall.VAR, wall.EOF}},
 }
 
 func TestScanTokens(t *testing.T) {
@@ -83,6 +124,17 @@ var scannerScanTests = []scannerScanTest{
 	{"123", wall.INTEGER, "123"},
 	{"123*123", wall.INTEGER, "123"},
 	{"0.0", wall.FLOAT, "0.0"},
+	{"0xFF", wall.INTEGER, "0xFF"},
+	{"0b1010", wall.INTEGER, "0b1010"},
+	{"0o17", wall.INTEGER, "0o17"},
+	{"1_000_000", wall.INTEGER, "1_000_000"},
+	{"10u8", wall.INTEGER, "10u8"},
+	{"0xFFi64", wall.INTEGER, "0xFFi64"},
+	{"1e-9", wall.FLOAT, "1e-9"},
+	{"1E+9", wall.FLOAT, "1E+9"},
+	{"2.5f32", wall.FLOAT, "2.5f32"},
+	{"1e3+1", wall.FLOAT, "1e3"},
+	{"1e", wall.INTEGER, "1e"},
 	{"a", wall.IDENTIFIER, "a"},
 	{"\"a\"", wall.STRING, "a"},
 	{"\"\\a\"", wall.STRING, "\a"},
@@ -95,6 +147,21 @@ var scannerScanTests = []scannerScanTest{
 	{"\"\\v\"", wall.STRING, "\v"},
 	{`"\\"`, wall.STRING, "\\"},
 	{`"\""`, wall.STRING, "\""},
+	{`"\0"`, wall.STRING, "\x00"},
+	{`"\x41\xff"`, wall.STRING, "A\xff"},
+	{`"\u{e9}\u{1F600}"`, wall.STRING, "\u00e9\U0001F600"},
+	{`"\{\}"`, wall.STRING, "{}"},
+	{"\"a\nb\"", wall.STRING, "a\nb"},
+	{"`a\\n\"{b}\"\nc`", wall.STRING, "a\\n\"{b}\"\nc"},
+	{`"a{x}b"`, wall.STRINGHEAD, "a"},
+	{"'a'", wall.CHARACTER, "a"},
+	{`'"'`, wall.CHARACTER, "\""},
+	{`'\n'`, wall.CHARACTER, "\n"},
+	{`'\''`, wall.CHARACTER, "'"},
+	{`'\\'`, wall.CHARACTER, "\\"},
+	{`'\0'`, wall.CHARACTER, "\x00"},
+	{`'\x41'`, wall.CHARACTER, "A"},
+	{`'\xff'`, wall.CHARACTER, "\xff"},
 }
 
 func TestScanner_Scan(t *testing.T) {
@@ -107,3 +174,18 @@ func TestScanner_Scan(t *testing.T) {
 		assert.Equal(t, test.content, tok.Content)
 	}
 }
+
+func TestScanInvalidStrings(t *testing.T) {
+	for _, source := range []string{`"abc`, "`abc", `"\q"`, `"\x4g"`, `"\u41"`, `"\u{}"`, `"\u{110000}"`, `"\u{1234567}"`} {
+		_, err := wall.ScanTokens("<test>", source)
+		assert.Error(t, err, source)
+	}
+}
+
+func TestScanInvalidCharacters(t *testing.T) {
+	for _, source := range []string{"''", "'ab'", "'a", `'\q'`, `'\x4'`} {
+		sc := wall.NewScanner("<test>", source)
+		_, err := sc.Scan()
+		assert.Error(t, err, source)
+	}
+}
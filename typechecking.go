@@ -2,7 +2,11 @@ package wall
 
 import (
 	"fmt"
+	"math"
+	"math/big"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -23,6 +27,9 @@ func CheckCompilationUnit(f *ParsedFile) (*CheckedFile, error) {
 	if err := CheckBlocks(f, checkedUnit); err != nil {
 		return nil, err
 	}
+	if err := CheckClosureEnvs(checkedUnit); err != nil {
+		return nil, err
+	}
 	return checkedUnit, nil
 }
 
@@ -73,6 +80,12 @@ func checkTypeSignatures(p *ParsedFile, c *CheckedFile, checkedFiles map[*Parsed
 				return err
 			}
 		case *ParsedStructDef:
+			if def.TypeParams != nil {
+				if err := defineGeneric(&def.Name, def.TypeParams, &GenericDef{Struct: def}, c.GlobalScope); err != nil {
+					return err
+				}
+				break
+			}
 			chechedStructDef := &CheckedStructDef{
 				Name:   def.Name,
 				Fields: make([]CheckedStructField, 0, len(def.Fields)),
@@ -90,11 +103,40 @@ func checkTypeSignatures(p *ParsedFile, c *CheckedFile, checkedFiles map[*Parsed
 				return err
 			}
 			c.Typealiases = append(c.Typealiases, checked)
+		case *ParsedEnumDef:
+			checkedEnumDef := &CheckedEnumDef{
+				Name:     def.Name,
+				Variants: make([]CheckedEnumVariant, 0, len(def.Variants)),
+			}
+			if err := c.GlobalScope.DefineType(&checkedEnumDef.Name, NewEnumType()); err != nil {
+				return err
+			}
+			c.Enums = append(c.Enums, checkedEnumDef)
+		case *ParsedTraitDef:
+			if err := defineTrait(def, c.GlobalScope); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
 
+func defineTrait(def *ParsedTraitDef, s *Scope) error {
+	declared := make(map[string]struct{}, len(def.Methods))
+	for _, m := range def.Methods {
+		if _, exists := declared[m.Id.Content]; exists {
+			return NewError(m.Id.Pos, "method %s is already declared in trait %s", m.Id.Content, def.Name.Content)
+		}
+		declared[m.Id.Content] = struct{}{}
+	}
+	return s.DefineTrait(&def.Name, &Trait{
+		Name:    &def.Name,
+		Methods: def.Methods,
+		Scope:   s,
+		Impls:   make(map[TypeId]*Token),
+	})
+}
+
 func isChecked(p *ParsedFile, checkedFiles map[*ParsedFile]struct{}) bool {
 	if _, checked := checkedFiles[p]; checked {
 		return true
@@ -117,6 +159,19 @@ func checkFunctionSignatures(p *ParsedFile, c *CheckedFile, checkedFiles map[*Pa
 	if isChecked(p, checkedFiles) {
 		return nil
 	}
+	// Constants are defined first so that array lengths in signatures can
+	// refer to them regardless of the order of definitions.
+	for _, def := range p.Defs {
+		if def, isConst := def.(*ParsedConstDef); isConst {
+			if err := c.GlobalScope.DefineConst(&def.Name, &Const{
+				Name:  &def.Name,
+				Def:   def,
+				Scope: c.GlobalScope,
+			}); err != nil {
+				return err
+			}
+		}
+	}
 	for _, def := range p.Defs {
 		switch def := def.(type) {
 		case *ParsedImport:
@@ -124,49 +179,22 @@ func checkFunctionSignatures(p *ParsedFile, c *CheckedFile, checkedFiles map[*Pa
 				return err
 			}
 		case *ParsedFunDef:
-			checkedParams := make([]CheckedFunParam, 0, len(def.Params))
-			paramTypes := make([]TypeId, 0, len(def.Params))
-			for i, param := range def.Params {
-				paramType, err := checkType(param.Type, c.GlobalScope)
-				if err != nil {
-					return err
-				}
-				paramTypes = append(paramTypes, paramType)
-				checkedParams = append(checkedParams, CheckedFunParam{
-					Name: &def.Params[i].Id,
-					Type: paramType,
-				})
-			}
-			returnType := UNIT_TYPE_ID
-			if def.ReturnType != nil {
-				var err error
-				returnType, err = checkType(def.ReturnType, c.GlobalScope)
-				if err != nil {
+			if def.TypeParams != nil {
+				if err := defineGenericFunDef(def, c.GlobalScope); err != nil {
 					return err
 				}
+				break
 			}
 			if def.Typename != nil {
-				typename := c.GlobalScope.findType(def.Typename.Content)
-				if typename == nil {
-					return NewError(def.Typename.Pos, "type is not declared: %s", typename.Token.Content)
-				}
-				checkedMethod := &CheckedMethodDef{
-					Typename:   typename.Token,
-					Name:       &def.Id,
-					Params:     checkedParams,
-					ReturnType: returnType,
-					Body:       &CheckedBlock{},
-				}
-				if err := c.GlobalScope.DefineMethod(checkedMethod.Typename, checkedMethod.Name, &MethodType{
-					This:    typename.TypeId,
-					Params:  paramTypes,
-					Returns: returnType,
-				}); err != nil {
+				if err := defineMethod(def, c); err != nil {
 					return err
 				}
-				c.Methods = append(c.Methods, checkedMethod)
 				break
 			}
+			checkedParams, paramTypes, returnType, err := checkFunSignature(def.Params, def.ReturnType, c.GlobalScope)
+			if err != nil {
+				return err
+			}
 			if def.Id.Content == "main" && c == mainFile {
 				if err := validateMain(def.pos(), paramTypes, returnType, c); err != nil {
 					return err
@@ -186,25 +214,18 @@ func checkFunctionSignatures(p *ParsedFile, c *CheckedFile, checkedFiles map[*Pa
 			}
 			c.Funs = append(c.Funs, checkedFunDef)
 		case *ParsedExternFunDef:
-			checkedParams := make([]CheckedFunParam, 0, len(def.Params))
-			paramTypes := make([]TypeId, 0, len(def.Params))
-			for i, param := range def.Params {
-				paramType, err := checkType(param.Type, c.GlobalScope)
-				if err != nil {
-					return err
-				}
-				paramTypes = append(paramTypes, paramType)
-				checkedParams = append(checkedParams, CheckedFunParam{
-					Name: &def.Params[i].Id,
-					Type: paramType,
-				})
+			checkedParams, paramTypes, returnType, err := checkFunSignature(def.Params, def.ReturnType, c.GlobalScope)
+			if err != nil {
+				return err
 			}
-			returnType := UNIT_TYPE_ID
-			if def.ReturnType != nil {
-				var err error
-				returnType, err = checkType(def.ReturnType, c.GlobalScope)
-				if err != nil {
-					return err
+			for i, paramType := range paramTypes {
+				if funType, isFun := (*c.Types)[paramType].(*FunctionType); isFun {
+					paramTypes[i] = c.TypeId(&FunctionType{
+						Params:  funType.Params,
+						Returns: funType.Returns,
+						Extern:  true,
+					})
+					checkedParams[i].Type = paramTypes[i]
 				}
 			}
 			checkedFunDef := &CheckedExternFunDef{
@@ -219,7 +240,109 @@ func checkFunctionSignatures(p *ParsedFile, c *CheckedFile, checkedFiles map[*Pa
 				return err
 			}
 			c.ExternFuns = append(c.ExternFuns, checkedFunDef)
+		case *ParsedImplDef:
+			for _, m := range def.Methods {
+				if err := defineMethod(m, c); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func defineMethod(def *ParsedFunDef, c *CheckedFile) error {
+	checkedParams, paramTypes, returnType, err := checkFunSignature(def.Params, def.ReturnType, c.GlobalScope)
+	if err != nil {
+		return err
+	}
+	typename := c.GlobalScope.findType(def.Typename.Content)
+	if typename == nil {
+		return NewError(def.Typename.Pos, "type is not declared: %s", def.Typename.Content)
+	}
+	checkedMethod := &CheckedMethodDef{
+		Typename:   typename.Token,
+		Name:       &def.Id,
+		Params:     checkedParams,
+		ReturnType: returnType,
+		Body:       &CheckedBlock{},
+	}
+	if err := c.GlobalScope.DefineMethod(checkedMethod.Typename, checkedMethod.Name, &MethodType{
+		This:    typename.TypeId,
+		Params:  paramTypes,
+		Returns: returnType,
+	}); err != nil {
+		return err
+	}
+	c.Methods = append(c.Methods, checkedMethod)
+	return nil
+}
+
+func checkFunSignature(params []ParsedFunParam, returns ParsedType, s *Scope) ([]CheckedFunParam, []TypeId, TypeId, error) {
+	checkedParams := make([]CheckedFunParam, 0, len(params))
+	paramTypes := make([]TypeId, 0, len(params))
+	for i, param := range params {
+		paramType, err := checkType(param.Type, s)
+		if err != nil {
+			return nil, nil, NOT_FOUND, err
+		}
+		paramTypes = append(paramTypes, paramType)
+		checkedParams = append(checkedParams, CheckedFunParam{
+			Name: &params[i].Id,
+			Type: paramType,
+		})
+	}
+	returnType := UNIT_TYPE_ID
+	if returns != nil {
+		var err error
+		returnType, err = checkType(returns, s)
+		if err != nil {
+			return nil, nil, NOT_FOUND, err
+		}
+	}
+	return checkedParams, paramTypes, returnType, nil
+}
+
+func defineGenericFunDef(def *ParsedFunDef, s *Scope) error {
+	if def.Typename == nil {
+		return defineGeneric(&def.Id, def.TypeParams, &GenericDef{Fun: def}, s)
+	}
+	g := s.findGeneric(def.Typename.Content)
+	if g == nil || g.Struct == nil {
+		return NewError(def.Typename.Pos, "generic type is not declared: %s", def.Typename.Content)
+	}
+	if len(def.TypeParams) != len(g.TypeParams) {
+		return NewError(def.Typename.Pos, "expected %d type parameters for %s, but got %d", len(g.TypeParams), def.Typename.Content, len(def.TypeParams))
+	}
+	if err := checkTypeParams(def.TypeParams); err != nil {
+		return err
+	}
+	for _, m := range g.Methods {
+		if m.Id.Content == def.Id.Content {
+			return NewError(def.Id.Pos, "method %s for type %s is already declared", def.Id.Content, def.Typename.Content)
+		}
+	}
+	g.Methods = append(g.Methods, def)
+	return nil
+}
+
+func defineGeneric(name *Token, typeParams []Token, g *GenericDef, s *Scope) error {
+	if err := checkTypeParams(typeParams); err != nil {
+		return err
+	}
+	g.Name = name
+	g.TypeParams = typeParams
+	g.Scope = s
+	return s.DefineGeneric(name, g)
+}
+
+func checkTypeParams(typeParams []Token) error {
+	declared := make(map[string]struct{}, len(typeParams))
+	for _, param := range typeParams {
+		if _, exists := declared[param.Content]; exists {
+			return NewError(param.Pos, "type parameter is redeclared: %s", param.Content)
 		}
+		declared[param.Content] = struct{}{}
 	}
 	return nil
 }
@@ -270,509 +393,2816 @@ func checkTypeContents(p *ParsedFile, c *CheckedFile, checkedFiles map[*ParsedFi
 					c.GlobalScope.Types[string(t.Name.Content)].TypeId = checkedT
 				}
 			}
+		case *ParsedEnumDef:
+			for _, e := range c.Enums {
+				if e.Name.Content == def.Name.Content {
+					if err := checkEnumContents(def, e, c.GlobalScope); err != nil {
+						return err
+					}
+				}
+			}
+		case *ParsedImplDef:
+			if err := checkImpl(def, c.GlobalScope); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
 
-func checkStructContents(def *ParsedStructDef, c *CheckedStructDef, s *Scope) error {
-	fields := make(map[string]TypeId)
-	for _, parsedField := range def.Fields {
-		if _, exists := fields[string(parsedField.Name.Content)]; exists {
-			return NewError(parsedField.Name.Pos, "field is redeclared: %s", parsedField.Name.Content)
-		}
-		checkedType, err := checkType(parsedField.Type, s)
+func checkImpl(def *ParsedImplDef, s *Scope) error {
+	trait, err := findTrait(def.Trait, s)
+	if err != nil {
+		return err
+	}
+	typename := s.findType(def.Typename.Content)
+	if typename == nil {
+		return NewError(def.Typename.Pos, "type is not declared: %s", def.Typename.Content)
+	}
+	if _, exists := trait.Impls[typename.TypeId]; exists {
+		return NewError(def.Typename.Pos, "trait %s is already implemented for type %s", trait.Name.Content, def.Typename.Content)
+	}
+	selfScope := newTypeParamScope(trait.Scope, []Token{{Kind: IDENTIFIER, Content: SELF_TYPE}}, []TypeId{typename.TypeId})
+	for _, m := range trait.Methods {
+		_, paramTypes, returnType, err := checkFunSignature(m.Params, m.ReturnType, selfScope)
 		if err != nil {
 			return err
 		}
-		fields[string(parsedField.Name.Content)] = checkedType
-		c.Fields = append(c.Fields, CheckedStructField{
-			Name: parsedField.Name,
-			Type: checkedType,
+		var impl *ParsedFunDef
+		for _, f := range def.Methods {
+			if f.Id.Content == m.Id.Content {
+				impl = f
+			}
+		}
+		if impl == nil {
+			return NewError(def.Typename.Pos, "type %s doesn't implement method %s of trait %s", def.Typename.Content, m.Id.Content, trait.Name.Content)
+		}
+		expected := s.File.TypeId(&MethodType{
+			This:    typename.TypeId,
+			Params:  paramTypes,
+			Returns: returnType,
 		})
+		method := s.findMethod(typename.Token.Content, impl.Id.Content, make(map[string]struct{}))
+		if method.TypeId != expected {
+			return NewError(impl.Id.Pos, "method %s has type %s, but trait %s expects %s", impl.Id.Content, s.TypeToString(method.TypeId), trait.Name.Content, s.TypeToString(expected))
+		}
 	}
-	structType := (*s.File.Types)[s.findType(string(def.Name.Content)).TypeId].(*StructType)
-	structType.Fields = fields
+	for _, f := range def.Methods {
+		if !trait.hasMethod(f.Id.Content) {
+			return NewError(f.Id.Pos, "method %s is not a member of trait %s", f.Id.Content, trait.Name.Content)
+		}
+	}
+	trait.Impls[typename.TypeId] = &def.Typename
 	return nil
 }
 
-func CheckBlocks(p *ParsedFile, c *CheckedFile) error {
-	return checkBlocks(p, c, make(map[*ParsedFile]struct{}))
+func findTrait(p ParsedType, s *Scope) (*Trait, error) {
+	switch p := p.(type) {
+	case *ParsedIdType:
+		if trait := s.findTrait(p.Content); trait != nil {
+			return trait, nil
+		}
+		return nil, NewError(p.pos(), "undeclared trait: %s", p.Content)
+	case *ParsedModuleAccessType:
+		importId := s.findImport(p.Module.Content)
+		if importId == IMPORT_NOT_FOUND {
+			return nil, NewError(p.Module.Pos, "unresolved import: %s", p.Module.Content)
+		}
+		return findTrait(p.Member, s.File.Imports[importId].File.GlobalScope)
+	}
+	return nil, NewError(p.pos(), "expected a trait")
 }
 
-func checkBlocks(p *ParsedFile, c *CheckedFile, checkedFiles map[*ParsedFile]struct{}) error {
-	if isChecked(p, checkedFiles) {
-		return nil
+func checkEnumContents(def *ParsedEnumDef, c *CheckedEnumDef, s *Scope) error {
+	if len(def.Variants) == 0 {
+		return NewError(def.Name.Pos, "enum %s must have at least one variant", def.Name.Content)
 	}
-	for _, def := range p.Defs {
-		switch def := def.(type) {
-		case *ParsedImport:
-			if err := handleImport(def, c, checkedFiles, checkBlocks); err != nil {
+	variants := make(map[string]int64)
+	payloads := make(map[string][]TypeId)
+	values := make(map[int64]string)
+	var value int64
+	for _, parsedVariant := range def.Variants {
+		if _, exists := variants[parsedVariant.Name.Content]; exists {
+			return NewError(parsedVariant.Name.Pos, "variant is redeclared: %s", parsedVariant.Name.Content)
+		}
+		payload := make([]TypeId, 0, len(parsedVariant.Payload))
+		for _, parsedType := range parsedVariant.Payload {
+			typ, err := checkType(parsedType, s)
+			if err != nil {
 				return err
 			}
-		case *ParsedFunDef:
-			for _, f := range c.Funs {
-				if f.Name.Content == def.Id.Content {
-					if err := checkFunBlock(def, f, c.GlobalScope); err != nil {
-						return err
-					}
-				}
-			}
-			for _, m := range c.Methods {
-				if m.Name.Content == def.Id.Content {
-					if err := checkMethodBlock(def, m, c.GlobalScope, c.GlobalScope.findType(m.Typename.Content).TypeId); err != nil {
-						return err
-					}
-				}
+			payload = append(payload, typ)
+		}
+		if len(payload) > 0 {
+			payloads[parsedVariant.Name.Content] = payload
+		}
+		if parsedVariant.Value != nil {
+			var err error
+			value, err = checkEnumDiscriminant(parsedVariant.Value)
+			if err != nil {
+				return err
 			}
 		}
+		if value < math.MinInt32 || value > math.MaxInt32 {
+			return NewError(parsedVariant.Name.Pos, "discriminant of %s is out of range: %d", parsedVariant.Name.Content, value)
+		}
+		if other, exists := values[value]; exists {
+			return NewError(parsedVariant.Name.Pos, "discriminant value %d is already used by %s", value, other)
+		}
+		variants[parsedVariant.Name.Content] = value
+		values[value] = parsedVariant.Name.Content
+		c.Variants = append(c.Variants, CheckedEnumVariant{
+			Name:    parsedVariant.Name,
+			Payload: payload,
+			Value:   value,
+		})
+		value++
 	}
-	return nil
+	enumTypeId := s.findType(def.Name.Content).TypeId
+	enumType := (*s.File.Types)[enumTypeId].(*EnumType)
+	enumType.Variants = variants
+	enumType.Payloads = payloads
+	return defineEnumNameMethod(c, enumTypeId, s)
 }
 
-func checkMethodBlock(p *ParsedFunDef, c *CheckedMethodDef, s *Scope, thisType TypeId) error {
-	s = NewScope(s)
-	s.MethodType = thisType
-	for _, param := range c.Params {
-		if err := s.DefineVar(param.Name, param.Type, false); err != nil {
-			return err
+var numericSuffixes = map[string]TypeId{
+	"i":   INT_TYPE_ID,
+	"i8":  INT8_TYPE_ID,
+	"i16": INT16_TYPE_ID,
+	"i32": INT32_TYPE_ID,
+	"i64": INT64_TYPE_ID,
+	"u":   UINT_TYPE_ID,
+	"u8":  UINT8_TYPE_ID,
+	"u16": UINT16_TYPE_ID,
+	"u32": UINT32_TYPE_ID,
+	"u64": UINT64_TYPE_ID,
+	"f32": FLOAT32_TYPE_ID,
+	"f64": FLOAT64_TYPE_ID,
+}
+
+func parseNumericLiteral(t Token) (string, TypeId, error) {
+	number, suffix := splitNumericSuffix(t.Content)
+	typ := NOT_FOUND
+	if suffix != "" {
+		var ok bool
+		if typ, ok = numericSuffixes[suffix]; !ok {
+			return "", NOT_FOUND, NewError(t.Pos, "invalid suffix %s on a numeric literal: %s", suffix, t.Content)
 		}
 	}
-	returns := UNIT_TYPE_ID
-	if p.ReturnType != nil {
-		var err error
-		returns, err = checkType(p.ReturnType, s)
-		if err != nil {
-			return err
+	prefixed := len(number) > 2 && number[0] == '0' && isBasePrefix(number[1])
+	if !prefixed && !validUnderscores(number) {
+		return "", NOT_FOUND, NewError(t.Pos, "invalid placement of _ in a numeric literal: %s", t.Content)
+	}
+	if t.Kind == FLOAT {
+		if typ != NOT_FOUND && isInteger(typ) {
+			return "", NOT_FOUND, NewError(t.Pos, "invalid suffix %s on a float literal: %s", suffix, t.Content)
 		}
+		number = strings.ReplaceAll(number, "_", "")
+		if _, err := strconv.ParseFloat(number, 64); err != nil {
+			return "", NOT_FOUND, NewError(t.Pos, "invalid float literal: %s", t.Content)
+		}
+		return number, typ, nil
 	}
-	block, err := checkBlock(p.Body, s, &MustReturn{
-		Type: returns,
-	})
-	if err != nil {
-		return err
+	value, ok := new(big.Int), false
+	if prefixed {
+		_, ok = value.SetString(number, 0)
+	} else {
+		_, ok = value.SetString(strings.ReplaceAll(number, "_", ""), 10)
 	}
-	c.Body = block
-	return nil
+	if !ok {
+		return "", NOT_FOUND, NewError(t.Pos, "invalid integer literal: %s", t.Content)
+	}
+	return value.String(), typ, nil
 }
 
-func checkFunBlock(p *ParsedFunDef, c *CheckedFunDef, s *Scope) error {
-	s = NewScope(s)
-	for _, param := range c.Params {
-		if err := s.DefineVar(param.Name, param.Type, false); err != nil {
-			return err
+func splitNumericSuffix(content string) (string, string) {
+	start, hex := 0, false
+	if len(content) > 2 && content[0] == '0' && isBasePrefix(content[1]) {
+		start, hex = 2, content[1] == 'x' || content[1] == 'X'
+	}
+	for i := start; i < len(content); i++ {
+		if c := content[i]; c == 'i' || c == 'u' || (c == 'f' && !hex) {
+			return content[:i], content[i:]
 		}
 	}
-	returns := UNIT_TYPE_ID
-	if p.ReturnType != nil {
-		var err error
-		returns, err = checkType(p.ReturnType, s)
-		if err != nil {
-			return err
+	return content, ""
+}
+
+func validUnderscores(number string) bool {
+	for i := 0; i < len(number); i++ {
+		if number[i] == '_' && (i == 0 || i == len(number)-1 || !isNum(number[i-1]) || !isNum(number[i+1])) {
+			return false
 		}
 	}
-	block, err := checkBlock(p.Body, s, &MustReturn{
-		Type: returns,
-	})
+	return true
+}
+
+func checkEnumDiscriminant(p ParsedExpr) (int64, error) {
+	negative := false
+	if unary, isUnary := p.(*ParsedUnaryExpr); isUnary && unary.Operator.Kind == MINUS {
+		negative = true
+		p = unary.Operand
+	}
+	literal, isLiteral := p.(*ParsedLiteralExpr)
+	if !isLiteral || literal.Kind != INTEGER {
+		return 0, NewError(p.pos(), "a discriminant must be an integer literal")
+	}
+	content, _, err := parseNumericLiteral(literal.Token)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	c.Body = block
-	return nil
+	value, err := strconv.ParseInt(content, 10, 64)
+	if err != nil {
+		return 0, NewError(p.pos(), "invalid discriminant: %s", literal.Content)
+	}
+	if negative {
+		value = -value
+	}
+	return value, nil
 }
 
-func checkBlock(p *ParsedBlock, s *Scope, controlFlow ControlFlow) (*CheckedBlock, error) {
-	s = NewScope(s)
-	checkedBlock := &CheckedBlock{
-		Stmts: make([]CheckedStmt, 0, len(p.Stmts)),
+func defineEnumNameMethod(c *CheckedEnumDef, enumType TypeId, s *Scope) error {
+	name := &Token{Kind: IDENTIFIER, Content: "name", Pos: c.Name.Pos}
+	returns := s.File.TypeId(&PointerType{Type: CHAR_TYPE_ID})
+	if err := s.DefineMethod(&c.Name, name, &MethodType{
+		This:    enumType,
+		Params:  []TypeId{},
+		Returns: returns,
+	}); err != nil {
+		return err
 	}
-	if len(p.Stmts) == 0 {
-		switch controlFlow := controlFlow.(type) {
-		case *MustReturn:
-			if controlFlow.Type != UNIT_TYPE_ID {
-				return nil, NewError(p.pos(), "%s is returned from empty block, but %s is expected", s.TypeToString(UNIT_TYPE_ID), s.TypeToString(controlFlow.Type))
-			}
-			return checkedBlock, nil
-		}
+	match := &CheckedMatch{
+		Value: &CheckedIdExpr{
+			Id:   &Token{Kind: IDENTIFIER, Content: "_this"},
+			Type: enumType,
+		},
+		Arms: make([]CheckedMatchArm, 0, len(c.Variants)),
 	}
-	_, mustReturn := controlFlow.(*MustReturn)
-	_, loop := controlFlow.(*MayReturnFromLoop)
-	for i, stmt := range p.Stmts {
-		var cf ControlFlow
-		if mustReturn && i+1 >= len(p.Stmts) && controlFlow.typeId() != UNIT_TYPE_ID {
-			cf = &MustReturn{Type: controlFlow.typeId()}
-		} else if loop {
-			cf = &MayReturnFromLoop{Type: controlFlow.typeId()}
-		} else {
-			cf = &MayReturn{Type: controlFlow.typeId()}
+	for _, variant := range c.Variants {
+		match.Arms = append(match.Arms, CheckedMatchArm{
+			Variant:  variant.Name,
+			Bindings: []*Token{},
+			Body: &CheckedBlock{
+				Stmts: []CheckedStmt{&CheckedReturn{
+					Value: &CheckedLiteralExpr{
+						Literal: Token{Kind: STRING, Content: variant.Name.Content},
+						Type:    returns,
+					},
+				}},
+			},
+		})
+	}
+	body := &CheckedBlock{
+		Stmts: []CheckedStmt{match},
+	}
+	s.File.Methods = append(s.File.Methods, &CheckedMethodDef{
+		Typename:   &c.Name,
+		Name:       name,
+		Params:     []CheckedFunParam{},
+		ReturnType: returns,
+		Body:       body,
+	})
+	return nil
+}
+
+func checkStructContents(def *ParsedStructDef, c *CheckedStructDef, s *Scope) error {
+	fields := make(map[string]TypeId)
+	for _, parsedField := range def.Fields {
+		if _, exists := fields[string(parsedField.Name.Content)]; exists {
+			return NewError(parsedField.Name.Pos, "field is redeclared: %s", parsedField.Name.Content)
 		}
-		checkedStmt, err := CheckStmt(stmt, s, cf)
+		checkedType, err := checkType(parsedField.Type, s)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		checkedBlock.Stmts = append(checkedBlock.Stmts, checkedStmt)
+		fields[string(parsedField.Name.Content)] = checkedType
+		c.Fields = append(c.Fields, CheckedStructField{
+			Name: parsedField.Name,
+			Type: checkedType,
+		})
 	}
-	return checkedBlock, nil
+	structType := (*s.File.Types)[s.findType(c.Name.Content).TypeId].(*StructType)
+	structType.Fields = fields
+	return nil
 }
 
-func CheckStmt(stmt ParsedStmt, scope *Scope, controlFlow ControlFlow) (CheckedStmt, error) {
-	switch stmt := stmt.(type) {
-	case *ParsedReturn, *ParsedBlock, *ParsedIf:
-		{
+func findConst(p ParsedExpr, s *Scope) *Const {
+	switch p := p.(type) {
+	case *ParsedIdExpr:
+		if s.findVar(p.Content) != nil {
+			return nil
 		}
-	default:
+		return s.findConst(p.Content)
+	case *ParsedModuleAccessExpr:
+		importId := s.findImport(p.Module.Content)
+		if importId == IMPORT_NOT_FOUND {
+			return nil
+		}
+		return findConst(p.Member, s.File.Imports[importId].File.GlobalScope)
+	}
+	return nil
+}
+
+func checkConstExpr(pos Pos, c *Const, s *Scope) (CheckedExpr, error) {
+	if err := c.evaluate(); err != nil {
+		return nil, err
+	}
+	switch value := c.Value.(type) {
+	case bool:
+		kind := FALSE
+		if value {
+			kind = TRUE
+		}
+		return &CheckedLiteralExpr{
+			Literal: Token{Kind: kind, Content: strconv.FormatBool(value), Pos: pos},
+			Type:    BOOL_TYPE_ID,
+		}, nil
+	case string:
+		return &CheckedLiteralExpr{
+			Literal: Token{Kind: STRING, Content: value, Pos: pos},
+			Type:    c.Type,
+		}, nil
+	case byte:
+		return &CheckedLiteralExpr{
+			Literal: Token{Kind: CHARACTER, Content: string([]byte{value}), Pos: pos},
+			Type:    CHAR_TYPE_ID,
+		}, nil
+	}
+	var literal *CheckedLiteralExpr
+	negative := false
+	switch value := c.Value.(type) {
+	case *big.Int:
+		negative = value.Sign() < 0
+		literal = &CheckedLiteralExpr{
+			Literal: Token{Kind: INTEGER, Content: new(big.Int).Abs(value).String(), Pos: pos},
+			Type:    INT32_TYPE_ID,
+		}
+	case float64:
+		negative = value < 0
+		content := strconv.FormatFloat(math.Abs(value), 'g', -1, 64)
+		if !strings.ContainsAny(content, ".e") {
+			content += ".0"
+		}
+		literal = &CheckedLiteralExpr{
+			Literal: Token{Kind: FLOAT, Content: content, Pos: pos},
+			Type:    FLOAT64_TYPE_ID,
+		}
+	}
+	var expr CheckedExpr = literal
+	if negative {
+		expr = &CheckedUnaryExpr{
+			Pos:      pos,
+			Operator: CHECKED_NEGATE,
+			Operand:  literal,
+			Type:     literal.Type,
+		}
+	}
+	if c.Untyped {
+		return coerce(expr, c.Type, s)
+	}
+	return &CheckedAsExpr{
+		Value: expr,
+		Type:  c.Type,
+	}, nil
+}
+
+func (c *Const) evaluate() error {
+	if c.Value != nil {
+		return nil
+	}
+	if c.Checking {
+		return NewError(c.Name.Pos, "constant %s depends on itself", c.Name.Content)
+	}
+	c.Checking = true
+	defer func() {
+		c.Checking = false
+	}()
+	expr, err := CheckExpr(c.Def.Value, c.Scope)
+	if err != nil {
+		return err
+	}
+	value, err := foldConstant(expr, c.Def.Value.pos(), c.Scope)
+	if err != nil {
+		return err
+	}
+	c.Value = value
+	c.Type = expr.TypeId()
+	c.Untyped = isUntypedConstant(expr)
+	return nil
+}
+
+func foldConstant(expr CheckedExpr, pos Pos, s *Scope) (interface{}, error) {
+	switch expr := expr.(type) {
+	case *CheckedLiteralExpr:
+		switch expr.Literal.Kind {
+		case INTEGER:
+			value, err := constantValue(expr)
+			if err != nil {
+				return nil, err
+			}
+			// An integer literal has no range of its own until a conversion or
+			// a typed operand gives it one.
+			if isInteger(expr.Type) {
+				return value, nil
+			}
+			return convertConstantValue(value, expr.Type, pos, s)
+		case FLOAT:
+			value, err := strconv.ParseFloat(expr.Literal.Content, 64)
+			if err != nil {
+				return nil, NewError(expr.Literal.Pos, "invalid float literal: %s", expr.Literal.Content)
+			}
+			return convertConstantValue(value, expr.Type, pos, s)
+		case CHARACTER:
+			return expr.Literal.Content[0], nil
+		case TRUE, FALSE:
+			return expr.Literal.Kind == TRUE, nil
+		case STRING:
+			return expr.Literal.Content, nil
+		}
+	case *CheckedGroupedExpr:
+		return foldConstant(expr.Inner, pos, s)
+	case *CheckedUnaryExpr:
+		operand, err := foldConstant(expr.Operand, pos, s)
+		if err != nil {
+			return nil, err
+		}
+		switch operand := operand.(type) {
+		case *big.Int:
+			switch expr.Operator {
+			case CHECKED_UNARY_PLUS:
+				return operand, nil
+			case CHECKED_NEGATE:
+				return checkConstantRange(new(big.Int).Neg(operand), expr, pos, s)
+			case CHECKED_BITNOT:
+				if min, max := integerRange(expr.Type); min.Sign() == 0 && !isUntypedConstant(expr) {
+					return new(big.Int).Sub(max, operand), nil
+				}
+				return new(big.Int).Not(operand), nil
+			}
+		case float64:
+			switch expr.Operator {
+			case CHECKED_UNARY_PLUS:
+				return operand, nil
+			case CHECKED_NEGATE:
+				return -operand, nil
+			}
+		case bool:
+			if expr.Operator == CHECKED_NOT {
+				return !operand, nil
+			}
+		}
+	case *CheckedBinaryExpr:
+		left, err := foldConstant(expr.Left, pos, s)
+		if err != nil {
+			return nil, err
+		}
+		right, err := foldConstant(expr.Right, pos, s)
+		if err != nil {
+			return nil, err
+		}
+		return foldBinaryConstant(expr, left, right, pos, s)
+	case *CheckedAsExpr:
+		value, err := foldConstant(expr.Value, pos, s)
+		if err != nil {
+			return nil, err
+		}
+		return convertConstantValue(value, expr.Type, pos, s)
+	}
+	return nil, NewError(pos, "expression is not constant")
+}
+
+func foldBinaryConstant(expr *CheckedBinaryExpr, left interface{}, right interface{}, pos Pos, s *Scope) (interface{}, error) {
+	switch left := left.(type) {
+	case *big.Int:
+		right := right.(*big.Int)
+		switch expr.Op {
+		case CHECKED_EQUALS:
+			return left.Cmp(right) == 0, nil
+		case CHECKED_NOTEQUALS:
+			return left.Cmp(right) != 0, nil
+		case CHECKED_LESSTHAN:
+			return left.Cmp(right) < 0, nil
+		case CHECKED_LESSOREQUAL:
+			return left.Cmp(right) <= 0, nil
+		case CHECKED_GREATERTHAN:
+			return left.Cmp(right) > 0, nil
+		case CHECKED_GREATEROREQUAL:
+			return left.Cmp(right) >= 0, nil
+		}
+		value := new(big.Int)
+		switch expr.Op {
+		case CHECKED_ADD:
+			value.Add(left, right)
+		case CHECKED_SUBTRACT:
+			value.Sub(left, right)
+		case CHECKED_MULTIPLY:
+			value.Mul(left, right)
+		case CHECKED_DIVIDE, CHECKED_REMAINDER:
+			if right.Sign() == 0 {
+				return nil, NewError(pos, "division by zero")
+			}
+			if expr.Op == CHECKED_DIVIDE {
+				value.Quo(left, right)
+			} else {
+				value.Rem(left, right)
+			}
+		case CHECKED_BITAND:
+			value.And(left, right)
+		case CHECKED_BITOR:
+			value.Or(left, right)
+		case CHECKED_BITXOR:
+			value.Xor(left, right)
+		case CHECKED_SHIFTLEFT, CHECKED_SHIFTRIGHT:
+			if right.Sign() < 0 || right.Cmp(big.NewInt(64)) >= 0 {
+				return nil, NewError(pos, "invalid shift count: %s", right)
+			}
+			if expr.Op == CHECKED_SHIFTLEFT {
+				value.Lsh(left, uint(right.Uint64()))
+			} else {
+				value.Rsh(left, uint(right.Uint64()))
+			}
+		default:
+			return nil, NewError(pos, "expression is not constant")
+		}
+		return checkConstantRange(value, expr, pos, s)
+	case float64:
+		right := right.(float64)
+		switch expr.Op {
+		case CHECKED_EQUALS:
+			return left == right, nil
+		case CHECKED_NOTEQUALS:
+			return left != right, nil
+		case CHECKED_LESSTHAN:
+			return left < right, nil
+		case CHECKED_LESSOREQUAL:
+			return left <= right, nil
+		case CHECKED_GREATERTHAN:
+			return left > right, nil
+		case CHECKED_GREATEROREQUAL:
+			return left >= right, nil
+		case CHECKED_ADD:
+			return convertConstantValue(left+right, expr.Type, pos, s)
+		case CHECKED_SUBTRACT:
+			return convertConstantValue(left-right, expr.Type, pos, s)
+		case CHECKED_MULTIPLY:
+			return convertConstantValue(left*right, expr.Type, pos, s)
+		case CHECKED_DIVIDE:
+			if right == 0 {
+				return nil, NewError(pos, "division by zero")
+			}
+			return convertConstantValue(left/right, expr.Type, pos, s)
+		}
+	case byte:
+		// char is signed in C, so compare as int8.
+		a, b := int8(left), int8(right.(byte))
+		switch expr.Op {
+		case CHECKED_EQUALS:
+			return a == b, nil
+		case CHECKED_NOTEQUALS:
+			return a != b, nil
+		case CHECKED_LESSTHAN:
+			return a < b, nil
+		case CHECKED_LESSOREQUAL:
+			return a <= b, nil
+		case CHECKED_GREATERTHAN:
+			return a > b, nil
+		case CHECKED_GREATEROREQUAL:
+			return a >= b, nil
+		}
+	case bool:
+		right := right.(bool)
+		switch expr.Op {
+		case CHECKED_EQUALS:
+			return left == right, nil
+		case CHECKED_NOTEQUALS:
+			return left != right, nil
+		case CHECKED_AND:
+			return left && right, nil
+		case CHECKED_OR:
+			return left || right, nil
+		}
+	}
+	return nil, NewError(pos, "expression is not constant")
+}
+
+func checkConstantRange(value *big.Int, expr CheckedExpr, pos Pos, s *Scope) (*big.Int, error) {
+	if isUntypedConstant(expr) {
+		return value, nil
+	}
+	min, max := integerRange(expr.TypeId())
+	if value.Cmp(min) < 0 || value.Cmp(max) > 0 {
+		return nil, NewError(pos, "constant %s overflows %s", value, s.TypeToString(expr.TypeId()))
+	}
+	return value, nil
+}
+
+func convertConstantValue(value interface{}, to TypeId, pos Pos, s *Scope) (interface{}, error) {
+	switch {
+	case isInteger(to):
+		var integer *big.Int
+		switch value := value.(type) {
+		case *big.Int:
+			integer = value
+		case float64:
+			integer, _ = big.NewFloat(value).Int(nil)
+		case bool:
+			integer = big.NewInt(0)
+			if value {
+				integer = big.NewInt(1)
+			}
+		case byte:
+			// char is signed in C, so '\xff' converts to -1.
+			integer = big.NewInt(int64(int8(value)))
+		}
+		if integer != nil {
+			min, max := integerRange(to)
+			if integer.Cmp(min) < 0 || integer.Cmp(max) > 0 {
+				return nil, NewError(pos, "constant %s overflows %s", integer, s.TypeToString(to))
+			}
+			return integer, nil
+		}
+	case to == FLOAT32_TYPE_ID || to == FLOAT64_TYPE_ID:
+		var float float64
+		switch value := value.(type) {
+		case *big.Int:
+			float, _ = new(big.Float).SetInt(value).Float64()
+		case float64:
+			float = value
+		default:
+			return nil, NewError(pos, "expression is not constant")
+		}
+		if to == FLOAT32_TYPE_ID {
+			float = float64(float32(float))
+		}
+		if math.IsInf(float, 0) || math.IsNaN(float) {
+			return nil, NewError(pos, "constant overflows %s", s.TypeToString(to))
+		}
+		return float, nil
+	case to == BOOL_TYPE_ID:
+		if value, isBool := value.(bool); isBool {
+			return value, nil
+		}
+	case to == CHAR_TYPE_ID:
+		switch value := value.(type) {
+		case byte:
+			return value, nil
+		case *big.Int:
+			if value.Cmp(big.NewInt(math.MinInt8)) < 0 || value.Cmp(big.NewInt(math.MaxUint8)) > 0 {
+				return nil, NewError(pos, "constant %s overflows %s", value, s.TypeToString(to))
+			}
+			return byte(value.Int64()), nil
+		}
+	}
+	return nil, NewError(pos, "expression is not constant")
+}
+
+func CheckBlocks(p *ParsedFile, c *CheckedFile) error {
+	if err := checkVarDefs(p, c, make(map[*ParsedFile]struct{})); err != nil {
+		return err
+	}
+	return checkBlocks(p, c, make(map[*ParsedFile]struct{}))
+}
+
+func checkVarDefs(p *ParsedFile, c *CheckedFile, checkedFiles map[*ParsedFile]struct{}) error {
+	if isChecked(p, checkedFiles) {
+		return nil
+	}
+	for _, def := range p.Defs {
+		if def, isImport := def.(*ParsedImport); isImport {
+			if err := handleImport(def, c, checkedFiles, checkVarDefs); err != nil {
+				return err
+			}
+		}
+	}
+	for _, def := range p.Defs {
+		if def, isVar := def.(*ParsedVarDef); isVar {
+			if err := checkVarDef(def, c); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkVarDef(p *ParsedVarDef, c *CheckedFile) error {
+	s := c.GlobalScope
+	val, err := CheckExpr(p.Value, s)
+	if err != nil {
+		return err
+	}
+	if val.TypeId() == UNIT_TYPE_ID {
+		return NewError(p.pos(), "can't declare a variable of type %s", s.TypeToString(UNIT_TYPE_ID))
+	}
+	if isNull(val, s) {
+		return NewError(p.pos(), "can't infer a type of null (use null as ?T)")
+	}
+	if isResultVariant(val, s) {
+		return NewError(p.pos(), "can't infer a type of %s (use it as T!E)", s.TypeToString(val.TypeId()))
+	}
+	if err := checkConstantsInExpr(val, s); err != nil {
+		return err
+	}
+	if err := checkInterpolationsInExpr(val); err != nil {
+		return err
+	}
+	_, err = foldConstant(val, p.Value.pos(), s)
+	checked := &CheckedVarDef{
+		Mut:    p.Mut,
+		Name:   &p.Name,
+		Value:  val,
+		Static: err == nil,
+	}
+	if err := s.DefineVar(checked.Name, val.TypeId(), p.Mut != nil); err != nil {
+		return err
+	}
+	c.Globals = append(c.Globals, checked)
+	return nil
+}
+
+func checkBlocks(p *ParsedFile, c *CheckedFile, checkedFiles map[*ParsedFile]struct{}) error {
+	if isChecked(p, checkedFiles) {
+		return nil
+	}
+	for _, def := range p.Defs {
+		switch def := def.(type) {
+		case *ParsedImport:
+			if err := handleImport(def, c, checkedFiles, checkBlocks); err != nil {
+				return err
+			}
+		case *ParsedFunDef:
+			if def.TypeParams != nil {
+				break
+			}
+			for _, f := range c.Funs {
+				if def.Typename == nil && f.Name.Content == def.Id.Content {
+					if err := checkFunBlock(def, f, c.GlobalScope); err != nil {
+						return err
+					}
+				}
+			}
+			if def.Typename != nil {
+				if err := checkMethodBlocks(def, c); err != nil {
+					return err
+				}
+			}
+		case *ParsedImplDef:
+			for _, m := range def.Methods {
+				if err := checkMethodBlocks(m, c); err != nil {
+					return err
+				}
+			}
+		case *ParsedConstDef:
+			if err := c.GlobalScope.Consts[def.Name.Content].evaluate(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkMethodBlocks(def *ParsedFunDef, c *CheckedFile) error {
+	for _, m := range c.Methods {
+		if m.Typename.Content == def.Typename.Content && m.Name.Content == def.Id.Content {
+			if err := checkMethodBlock(def, m, c.GlobalScope, c.GlobalScope.findType(m.Typename.Content).TypeId); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkMethodBlock(p *ParsedFunDef, c *CheckedMethodDef, s *Scope, thisType TypeId) error {
+	s = NewScope(s)
+	s.MethodType = thisType
+	for _, param := range c.Params {
+		if err := s.DefineVar(param.Name, param.Type, false); err != nil {
+			return err
+		}
+	}
+	returns := UNIT_TYPE_ID
+	if p.ReturnType != nil {
+		var err error
+		returns, err = checkType(p.ReturnType, s)
+		if err != nil {
+			return err
+		}
+	}
+	block, err := checkBlock(p.Body, s, &MustReturn{
+		Type: returns,
+	})
+	if err != nil {
+		return err
+	}
+	c.Body = block
+	return nil
+}
+
+func checkFunBlock(p *ParsedFunDef, c *CheckedFunDef, s *Scope) error {
+	s = NewScope(s)
+	for _, param := range c.Params {
+		if err := s.DefineVar(param.Name, param.Type, false); err != nil {
+			return err
+		}
+	}
+	returns := UNIT_TYPE_ID
+	if p.ReturnType != nil {
+		var err error
+		returns, err = checkType(p.ReturnType, s)
+		if err != nil {
+			return err
+		}
+	}
+	block, err := checkBlock(p.Body, s, &MustReturn{
+		Type: returns,
+	})
+	if err != nil {
+		return err
+	}
+	c.Body = block
+	return nil
+}
+
+func checkBlock(p *ParsedBlock, s *Scope, controlFlow ControlFlow) (*CheckedBlock, error) {
+	s = NewScope(s)
+	s.ControlFlow = controlFlow
+	checkedBlock := &CheckedBlock{
+		Stmts: make([]CheckedStmt, 0, len(p.Stmts)),
+	}
+	if len(p.Stmts) == 0 {
+		switch controlFlow := controlFlow.(type) {
+		case *MustReturn:
+			if controlFlow.Type != UNIT_TYPE_ID {
+				return nil, NewError(p.pos(), "%s is returned from empty block, but %s is expected", s.TypeToString(UNIT_TYPE_ID), s.TypeToString(controlFlow.Type))
+			}
+			return checkedBlock, nil
+		}
+	}
+	_, mustReturn := controlFlow.(*MustReturn)
+	_, loop := controlFlow.(*MayReturnFromLoop)
+	for i, stmt := range p.Stmts {
+		var cf ControlFlow
+		if mustReturn && i+1 >= len(p.Stmts) && controlFlow.typeId() != UNIT_TYPE_ID {
+			cf = &MustReturn{Type: controlFlow.typeId()}
+		} else if loop {
+			cf = &MayReturnFromLoop{Type: controlFlow.typeId()}
+		} else {
+			cf = &MayReturn{Type: controlFlow.typeId()}
+		}
+		checkedStmt, err := CheckStmt(stmt, s, cf)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkConstants(checkedStmt, s); err != nil {
+			return nil, err
+		}
+		if err := checkInterpolations(checkedStmt); err != nil {
+			return nil, err
+		}
+		checkedBlock.Stmts = append(checkedBlock.Stmts, checkedStmt)
+	}
+	return checkedBlock, nil
+}
+
+func CheckStmt(stmt ParsedStmt, scope *Scope, controlFlow ControlFlow) (CheckedStmt, error) {
+	switch stmt := stmt.(type) {
+	case *ParsedReturn, *ParsedBlock, *ParsedIf, *ParsedMatch, *ParsedUnsafe:
+		{
+		}
+	default:
 		if _, mustReturn := controlFlow.(*MustReturn); mustReturn {
 			return nil, NewError(stmt.pos(), "expected return statement")
 		}
 	}
-	switch stmt := stmt.(type) {
-	case *ParsedVar:
-		return checkVar(stmt, scope, controlFlow)
-	case *ParsedExprStmt:
-		return checkExprStmt(stmt, scope, controlFlow)
-	case *ParsedBlock:
-		return checkBlock(stmt, scope, controlFlow)
-	case *ParsedReturn:
-		return checkReturn(stmt, scope, controlFlow)
-	case *ParsedIf:
-		return checkIf(stmt, scope, controlFlow)
-	case *ParsedWhile:
-		return checkWhile(stmt, scope, controlFlow)
-	case *ParsedBreak:
-		return checkBreak(stmt, scope, controlFlow)
-	case *ParsedContinue:
-		return checkContinue(stmt, scope, controlFlow)
+	switch stmt := stmt.(type) {
+	case *ParsedVar:
+		return checkVar(stmt, scope, controlFlow)
+	case *ParsedExprStmt:
+		return checkExprStmt(stmt, scope, controlFlow)
+	case *ParsedBlock:
+		return checkBlock(stmt, scope, controlFlow)
+	case *ParsedReturn:
+		return checkReturn(stmt, scope, controlFlow)
+	case *ParsedIf:
+		return checkIf(stmt, scope, controlFlow)
+	case *ParsedWhile:
+		return checkWhile(stmt, scope, controlFlow)
+	case *ParsedFor:
+		return checkFor(stmt, scope, controlFlow)
+	case *ParsedForIn:
+		return checkForIn(stmt, scope, controlFlow)
+	case *ParsedBreak:
+		return checkBreak(stmt, scope, controlFlow)
+	case *ParsedContinue:
+		return checkContinue(stmt, scope, controlFlow)
+	case *ParsedMatch:
+		return checkMatch(stmt, scope, controlFlow)
+	case *ParsedDefer:
+		return checkDefer(stmt, scope, controlFlow)
+	case *ParsedUnsafe:
+		return checkUnsafe(stmt, scope, controlFlow)
+	}
+	panic("unimplemented")
+}
+
+func checkDefer(p *ParsedDefer, s *Scope, controlFlow ControlFlow) (*CheckedDefer, error) {
+	switch p.Stmt.(type) {
+	case *ParsedVar, *ParsedDefer:
+		return nil, NewError(p.Stmt.pos(), "can't defer this statement")
+	}
+	if s.isIfExpr() {
+		return nil, NewError(p.pos(), "can't defer in an if expression")
+	}
+	s = NewScope(s)
+	s.Deferred = true
+	stmt, err := CheckStmt(p.Stmt, s, &MayReturn{
+		Type: controlFlow.typeId(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &CheckedDefer{
+		Stmt: stmt,
+	}, nil
+}
+
+func checkUnsafe(p *ParsedUnsafe, s *Scope, controlFlow ControlFlow) (*CheckedBlock, error) {
+	s = NewScope(s)
+	s.Unsafe = true
+	return checkBlock(p.Body, s, controlFlow)
+}
+
+func checkMatch(p *ParsedMatch, s *Scope, controlFlow ControlFlow) (*CheckedMatch, error) {
+	value, err := CheckExpr(p.Value, s)
+	if err != nil {
+		return nil, err
+	}
+	enumType, isEnumType := (*s.File.Types)[value.TypeId()].(*EnumType)
+	if resultType, isResult := (*s.File.Types)[value.TypeId()].(*ResultType); isResult {
+		enumType, isEnumType = resultVariants(resultType), true
+	}
+	if !isEnumType {
+		return nil, NewError(p.Value.pos(), "can't match on %s: expected enum or result type", s.TypeToString(value.TypeId()))
+	}
+	checkedMatch := &CheckedMatch{
+		Value: value,
+		Arms:  make([]CheckedMatchArm, 0, len(p.Arms)),
+	}
+	matched := make(map[string]struct{})
+	for i := range p.Arms {
+		arm := &p.Arms[i]
+		if _, exists := enumType.Variants[arm.Variant.Content]; !exists {
+			return nil, NewError(arm.Variant.Pos, "unknown variant of %s: %s", s.TypeToString(value.TypeId()), arm.Variant.Content)
+		}
+		if _, exists := matched[arm.Variant.Content]; exists {
+			return nil, NewError(arm.Variant.Pos, "variant %s is already matched", arm.Variant.Content)
+		}
+		matched[arm.Variant.Content] = struct{}{}
+		payload := enumType.Payloads[arm.Variant.Content]
+		armScope := NewScope(s)
+		bindings := make([]*Token, 0, len(arm.Bindings))
+		if arm.Bindings != nil {
+			if len(arm.Bindings) != len(payload) {
+				return nil, NewError(arm.Variant.Pos, "expected %d bindings for variant %s, but got %d", len(payload), arm.Variant.Content, len(arm.Bindings))
+			}
+			for j := range arm.Bindings {
+				binding := &arm.Bindings[j]
+				if binding.Content != "_" {
+					if err := armScope.DefineVar(binding, payload[j], false); err != nil {
+						return nil, err
+					}
+				}
+				bindings = append(bindings, binding)
+			}
+		}
+		body, err := checkBlock(arm.Body, armScope, controlFlow)
+		if err != nil {
+			return nil, err
+		}
+		checkedMatch.Arms = append(checkedMatch.Arms, CheckedMatchArm{
+			Variant:  arm.Variant,
+			Bindings: bindings,
+			Body:     body,
+		})
+	}
+	if p.ElseBody != nil {
+		if len(matched) == len(enumType.Variants) {
+			return nil, NewError(p.ElseBody.pos(), "else block is unreachable: all variants are matched")
+		}
+		checkedMatch.ElseBody, err = checkBlock(p.ElseBody, s, controlFlow)
+		if err != nil {
+			return nil, err
+		}
+		return checkedMatch, nil
+	}
+	missing := make([]string, 0)
+	for variant := range enumType.Variants {
+		if _, exists := matched[variant]; !exists {
+			missing = append(missing, variant)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Slice(missing, func(i, j int) bool {
+			return enumType.Variants[missing[i]] < enumType.Variants[missing[j]]
+		})
+		return nil, NewError(p.pos(), "match is not exhaustive: missing %s (add an arm or else block)", strings.Join(missing, ", "))
+	}
+	return checkedMatch, nil
+}
+
+func resultVariants(t *ResultType) *EnumType {
+	ok := []TypeId{t.Value}
+	if t.Value == UNIT_TYPE_ID {
+		ok = []TypeId{}
+	}
+	return &EnumType{
+		Variants: map[string]int64{RESULT_OK: 0, RESULT_ERR: 1},
+		Payloads: map[string][]TypeId{RESULT_OK: ok, RESULT_ERR: {t.Error}},
+	}
+}
+
+func checkContinue(p *ParsedContinue, s *Scope, controlFlow ControlFlow) (*CheckedContinue, error) {
+	if _, mayReturnFromLoop := controlFlow.(*MayReturnFromLoop); mayReturnFromLoop {
+		return &CheckedContinue{
+			Continue: p.Continue,
+		}, nil
+	}
+	return nil, NewError(p.pos(), "can't use continue not in a loop")
+}
+
+func checkBreak(p *ParsedBreak, s *Scope, controlFlow ControlFlow) (*CheckedBreak, error) {
+	if _, mayReturnFromLoop := controlFlow.(*MayReturnFromLoop); mayReturnFromLoop {
+		return &CheckedBreak{
+			Break: p.Break,
+		}, nil
+	}
+	return nil, NewError(p.pos(), "can't use break not in a loop")
+}
+
+func checkWhile(p *ParsedWhile, s *Scope, controlFlow ControlFlow) (*CheckedWhile, error) {
+	if _, mustReturn := controlFlow.(*MustReturn); mustReturn {
+		return nil, NewError(p.pos(), "expected return statement")
+	}
+	cond, err := CheckExpr(p.Condition, s)
+	if err != nil {
+		return nil, err
+	}
+	if cond.TypeId() != BOOL_TYPE_ID {
+		return nil, NewError(p.Condition.pos(), "a condition must be a boolean expression, but it's %s", s.TypeToString(cond.TypeId()))
+	}
+	body, err := checkBlock(p.Body, s, &MayReturnFromLoop{
+		Type: controlFlow.typeId(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &CheckedWhile{
+		Cond: cond,
+		Body: body,
+	}, nil
+}
+
+func checkFor(p *ParsedFor, s *Scope, controlFlow ControlFlow) (*CheckedFor, error) {
+	s = NewScope(s)
+	var init CheckedStmt
+	if p.Init != nil {
+		switch p.Init.(type) {
+		case *ParsedVar, *ParsedExprStmt:
+		default:
+			return nil, NewError(p.Init.pos(), "a for loop initializer must be a variable declaration or an expression")
+		}
+		var err error
+		init, err = CheckStmt(p.Init, s, &MayReturn{Type: controlFlow.typeId()})
+		if err != nil {
+			return nil, err
+		}
+	}
+	var cond CheckedExpr
+	if p.Cond != nil {
+		var err error
+		cond, err = CheckExpr(p.Cond, s)
+		if err != nil {
+			return nil, err
+		}
+		if cond.TypeId() != BOOL_TYPE_ID {
+			return nil, NewError(p.Cond.pos(), "a condition must be a boolean expression, but it's %s", s.TypeToString(cond.TypeId()))
+		}
+	}
+	var post CheckedExpr
+	if p.Post != nil {
+		exprStmt, isExpr := p.Post.(*ParsedExprStmt)
+		if !isExpr {
+			return nil, NewError(p.Post.pos(), "a for loop post statement must be an expression")
+		}
+		var err error
+		post, err = CheckExpr(exprStmt.Expr, s)
+		if err != nil {
+			return nil, err
+		}
+	}
+	body, err := checkBlock(p.Body, s, &MayReturnFromLoop{
+		Type: controlFlow.typeId(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &CheckedFor{
+		Init: init,
+		Cond: cond,
+		Post: post,
+		Body: body,
+	}, nil
+}
+
+// checkForIn lowers a range or an array/slice loop into a C-style loop over
+// a counter, evaluating the upper bound or the iterated value only once.
+func checkForIn(p *ParsedForIn, s *Scope, controlFlow ControlFlow) (*CheckedBlock, error) {
+	value, err := CheckExpr(p.Iterable, s)
+	if err != nil {
+		return nil, err
+	}
+	if p.DotDot != nil {
+		end, err := CheckExpr(p.End, s)
+		if err != nil {
+			return nil, err
+		}
+		value, end, err = coerceOperands(Token{Kind: LT, Pos: p.DotDot.Pos}, value, end, s)
+		if err != nil {
+			return nil, err
+		}
+		if !isInteger(value.TypeId()) || value.TypeId() != end.TypeId() {
+			return nil, NewError(p.DotDot.Pos, "range bounds must be integers of the same type, but they're %s and %s", s.TypeToString(value.TypeId()), s.TypeToString(end.TypeId()))
+		}
+		body, err := checkForInBody(p, value.TypeId(), s, controlFlow)
+		if err != nil {
+			return nil, err
+		}
+		counter := &CheckedIdExpr{Id: &p.Name, Type: value.TypeId()}
+		endName := &Token{Kind: IDENTIFIER, Content: "_end"}
+		return &CheckedBlock{
+			Stmts: []CheckedStmt{
+				&CheckedVar{Name: endName, Value: end},
+				&CheckedFor{
+					Init: &CheckedVar{Name: &p.Name, Value: value},
+					Cond: &CheckedBinaryExpr{Left: counter, Op: CHECKED_LESSTHAN, Right: &CheckedIdExpr{Id: endName, Type: end.TypeId()}, Type: BOOL_TYPE_ID},
+					Post: increment(counter),
+					Body: body,
+				},
+			},
+		}, nil
+	}
+	var elem TypeId
+	switch t := (*s.File.Types)[value.TypeId()].(type) {
+	case *ArrayType:
+		elem = t.Elem
+	case *SliceType:
+		elem = t.Elem
+	default:
+		return nil, NewError(p.Iterable.pos(), "can't iterate over %s (an array, a slice or a range is expected)", s.TypeToString(value.TypeId()))
+	}
+	body, err := checkForInBody(p, elem, s, controlFlow)
+	if err != nil {
+		return nil, err
+	}
+	iter := &CheckedIdExpr{Id: &Token{Kind: IDENTIFIER, Content: "_iter"}, Type: value.TypeId()}
+	index := &CheckedIdExpr{Id: &Token{Kind: IDENTIFIER, Content: "_i"}, Type: UINT_TYPE_ID}
+	return &CheckedBlock{
+		Stmts: []CheckedStmt{
+			&CheckedVar{Name: iter.Id, Value: value},
+			&CheckedFor{
+				Init: &CheckedVar{Name: index.Id, Value: &CheckedLiteralExpr{Literal: Token{Kind: INTEGER, Content: "0"}, Type: UINT_TYPE_ID}},
+				Cond: &CheckedBinaryExpr{Left: index, Op: CHECKED_LESSTHAN, Right: &CheckedLenExpr{Object: iter, Type: UINT_TYPE_ID}, Type: BOOL_TYPE_ID},
+				Post: increment(index),
+				Body: &CheckedBlock{
+					Stmts: []CheckedStmt{
+						&CheckedVar{Name: &p.Name, Value: &CheckedIndexExpr{Object: iter, Index: index, Type: elem}},
+						body,
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+func checkForInBody(p *ParsedForIn, typeId TypeId, s *Scope, controlFlow ControlFlow) (*CheckedBlock, error) {
+	s = NewScope(s)
+	if err := s.DefineVar(&p.Name, typeId, false); err != nil {
+		return nil, err
+	}
+	return checkBlock(p.Body, s, &MayReturnFromLoop{
+		Type: controlFlow.typeId(),
+	})
+}
+
+func increment(counter *CheckedIdExpr) *CheckedBinaryExpr {
+	return &CheckedBinaryExpr{
+		Left:  counter,
+		Op:    CHECKED_ADD_ASSIGN,
+		Right: &CheckedLiteralExpr{Literal: Token{Kind: INTEGER, Content: "1"}, Type: counter.Type},
+		Type:  counter.Type,
+	}
+}
+
+func checkIf(p *ParsedIf, s *Scope, controlFlow ControlFlow) (*CheckedIf, error) {
+	if _, mustReturn := controlFlow.(*MustReturn); mustReturn {
+		if p.ElseBody == nil && p.ElseIf == nil {
+			return nil, NewError(p.pos(), "if statement without else block may not return (add else block with return statement)")
+		}
+	}
+	cond, bodyScope, err := checkIfCondition(p, s)
+	if err != nil {
+		return nil, err
+	}
+	body, err := checkBlock(p.Body, bodyScope, controlFlow)
+	if err != nil {
+		return nil, err
+	}
+	var elseBody *CheckedBlock
+	if p.ElseIf != nil {
+		elseIf, err := checkIf(p.ElseIf, s, controlFlow)
+		if err != nil {
+			return nil, err
+		}
+		elseBody = &CheckedBlock{
+			Stmts: []CheckedStmt{elseIf},
+		}
+	}
+	if p.ElseBody != nil {
+		elseBody, err = checkBlock(p.ElseBody, s, controlFlow)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &CheckedIf{
+		Binding:  p.Binding,
+		Cond:     cond,
+		Body:     body,
+		ElseBody: elseBody,
+	}, nil
+}
+
+func checkIfCondition(p *ParsedIf, s *Scope) (CheckedExpr, *Scope, error) {
+	cond, err := CheckExpr(p.Condition, s)
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.Binding == nil {
+		return cond, s, nil
+	}
+	var elem TypeId
+	switch t := (*s.File.Types)[cond.TypeId()].(type) {
+	case *OptionalType:
+		elem = t.Elem
+	case *ResultType:
+		elem = t.Value
+	default:
+		return nil, nil, NewError(p.Condition.pos(), "can't unwrap %s (an optional or a result type is expected)", s.TypeToString(cond.TypeId()))
+	}
+	if elem == UNIT_TYPE_ID {
+		return nil, nil, NewError(p.Condition.pos(), "can't bind a value of type %s", s.TypeToString(UNIT_TYPE_ID))
+	}
+	bodyScope := NewScope(s)
+	if err := bodyScope.DefineVar(p.Binding, elem, false); err != nil {
+		return nil, nil, err
+	}
+	return cond, bodyScope, nil
+}
+
+func checkIfExpr(p *ParsedIfExpr, s *Scope) (*CheckedIfExpr, error) {
+	if p.If.ElseBody == nil && p.If.ElseIf == nil {
+		return nil, NewError(p.pos(), "if expression without else block has no value")
+	}
+	cond, bodyScope, err := checkIfCondition(p.If, s)
+	if err != nil {
+		return nil, err
+	}
+	body, err := checkIfExprBranch(p.If.Body, bodyScope)
+	if err != nil {
+		return nil, err
+	}
+	var elseBody *CheckedBlock
+	if p.If.ElseIf != nil {
+		elseIf, err := checkIfExpr(&ParsedIfExpr{If: p.If.ElseIf}, s)
+		if err != nil {
+			return nil, err
+		}
+		elseBody = &CheckedBlock{
+			Stmts: []CheckedStmt{&CheckedExprStmt{Expr: elseIf}},
+		}
+	} else {
+		elseBody, err = checkIfExprBranch(p.If.ElseBody, s)
+		if err != nil {
+			return nil, err
+		}
+	}
+	then := body.Stmts[len(body.Stmts)-1].(*CheckedExprStmt)
+	otherwise := elseBody.Stmts[len(elseBody.Stmts)-1].(*CheckedExprStmt)
+	if isUntypedConstant(then.Expr) && (!isUntypedConstant(otherwise.Expr) || otherwise.Expr.TypeId() == FLOAT64_TYPE_ID) {
+		then.Expr, err = coerce(then.Expr, otherwise.Expr.TypeId(), s)
+	} else {
+		otherwise.Expr, err = coerce(otherwise.Expr, then.Expr.TypeId(), s)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if then.Expr.TypeId() != otherwise.Expr.TypeId() {
+		if then.Expr, err = coerce(then.Expr, otherwise.Expr.TypeId(), s); err != nil {
+			return nil, err
+		}
+	}
+	if then.Expr.TypeId() != otherwise.Expr.TypeId() {
+		return nil, NewError(p.pos(), "if expression branches have different types: %s and %s", s.TypeToString(then.Expr.TypeId()), s.TypeToString(otherwise.Expr.TypeId()))
+	}
+	if then.Expr.TypeId() == UNIT_TYPE_ID {
+		return nil, NewError(p.pos(), "if expression branches have no value")
+	}
+	return &CheckedIfExpr{
+		Binding:  p.If.Binding,
+		Cond:     cond,
+		Body:     body,
+		ElseBody: elseBody,
+		Type:     then.Expr.TypeId(),
+	}, nil
+}
+
+func coerceIfExpr(expr *CheckedIfExpr, to TypeId, s *Scope) (CheckedExpr, error) {
+	then := expr.Body.Stmts[len(expr.Body.Stmts)-1].(*CheckedExprStmt)
+	otherwise := expr.ElseBody.Stmts[len(expr.ElseBody.Stmts)-1].(*CheckedExprStmt)
+	thenValue, err := coerce(then.Expr, to, s)
+	if err != nil {
+		return nil, err
+	}
+	otherwiseValue, err := coerce(otherwise.Expr, to, s)
+	if err != nil {
+		return nil, err
+	}
+	if thenValue.TypeId() != to || otherwiseValue.TypeId() != to {
+		return expr, nil
+	}
+	then.Expr, otherwise.Expr, expr.Type = thenValue, otherwiseValue, to
+	return expr, nil
+}
+
+func checkIfExprBranch(p *ParsedBlock, s *Scope) (*CheckedBlock, error) {
+	if len(p.Stmts) == 0 {
+		return nil, NewError(p.Right.Pos, "if expression branch must end with a value")
+	}
+	if _, isExpr := p.Stmts[len(p.Stmts)-1].(*ParsedExprStmt); !isExpr {
+		return nil, NewError(p.Stmts[len(p.Stmts)-1].pos(), "if expression branch must end with a value")
+	}
+	s = NewScope(s)
+	s.IfExpr = true
+	return checkBlock(p, s, &MayReturn{Type: UNIT_TYPE_ID})
+}
+
+func checkReturn(p *ParsedReturn, s *Scope, controlFlow ControlFlow) (*CheckedReturn, error) {
+	if s.isDeferred() {
+		return nil, NewError(p.pos(), "can't return from a deferred statement")
+	}
+	if s.isIfExpr() {
+		return nil, NewError(p.pos(), "can't return from an if expression")
+	}
+	if p.Arg == nil {
+		if resultType, isResult := (*s.File.Types)[controlFlow.typeId()].(*ResultType); isResult && resultType.Value == UNIT_TYPE_ID {
+			return &CheckedReturn{
+				Value: &CheckedResultExpr{
+					Type: controlFlow.typeId(),
+				},
+			}, nil
+		}
+		if controlFlow.typeId() != UNIT_TYPE_ID {
+			return nil, NewError(p.pos(), "expected return with an argument of type %s", s.TypeToString(controlFlow.typeId()))
+		}
+		return &CheckedReturn{
+			Value: nil,
+		}, nil
+	}
+	arg, err := CheckExpr(p.Arg, s)
+	if err != nil {
+		return nil, err
+	}
+	arg, err = coerce(arg, controlFlow.typeId(), s)
+	if err != nil {
+		return nil, err
+	}
+	if controlFlow.typeId() != arg.TypeId() {
+		return nil, NewError(p.Arg.pos(), "expected %s, but got %s", s.TypeToString(controlFlow.typeId()), s.TypeToString(arg.TypeId()))
+	}
+	return &CheckedReturn{
+		Value: arg,
+	}, nil
+}
+
+func checkExprStmt(p *ParsedExprStmt, s *Scope, controlFlow ControlFlow) (*CheckedExprStmt, error) {
+	expr, err := checkExpr(p.Expr, s)
+	if err != nil {
+		return nil, err
+	}
+	if isResultVariant(expr, s) {
+		return nil, NewError(p.pos(), "%s is not used", s.TypeToString(expr.TypeId()))
+	}
+	return &CheckedExprStmt{
+		Expr: expr,
+	}, nil
+}
+
+func checkVar(p *ParsedVar, s *Scope, controlFlow ControlFlow) (*CheckedVar, error) {
+	val, err := CheckExpr(p.Value, s)
+	if err != nil {
+		return nil, err
+	}
+	if val.TypeId() == UNIT_TYPE_ID {
+		return nil, NewError(p.pos(), "can't declare a variable of type %s", s.TypeToString(UNIT_TYPE_ID))
+	}
+	if isNull(val, s) {
+		return nil, NewError(p.pos(), "can't infer a type of null (use null as ?T)")
+	}
+	if isResultVariant(val, s) {
+		return nil, NewError(p.pos(), "can't infer a type of %s (use it as T!E)", s.TypeToString(val.TypeId()))
+	}
+	checked := &CheckedVar{
+		Mut:   p.Mut,
+		Name:  &p.Id,
+		Value: val,
+	}
+	if err := s.DefineVar(checked.Name, val.TypeId(), p.Mut != nil); err != nil {
+		return nil, err
+	}
+	return checked, nil
+}
+
+func CheckExpr(p ParsedExpr, s *Scope) (CheckedExpr, error) {
+	checked, err := checkExpr(p, s)
+	if err != nil {
+		return nil, err
+	}
+	if isFunctionName(checked, s) {
+		return newFunctionThunk(checked, s), nil
+	}
+	return checked, nil
+}
+
+func checkExpr(p ParsedExpr, s *Scope) (CheckedExpr, error) {
+	switch p := p.(type) {
+	case *ParsedUnaryExpr:
+		return checkUnaryExpr(p, s)
+	case *ParsedBinaryExpr:
+		return checkBinaryExpr(p, s)
+	case *ParsedGroupedExpr:
+		return checkGroupedExpr(p, s)
+	case *ParsedLiteralExpr:
+		return checkLiteralExpr(p, s)
+	case *ParsedInterpolatedStringExpr:
+		return checkInterpolatedStringExpr(p, s)
+	case *ParsedIdExpr:
+		if c := findConst(p, s); c != nil {
+			return checkConstExpr(p.Pos, c, s)
+		}
+		return checkIdExpr(p, s)
+	case *ParsedCallExpr:
+		if isResultConstructor(p, s) {
+			return checkResultConstructor(p, s)
+		}
+		return checkCallExpr(p, s)
+	case *ParsedStructInitExpr:
+		return checkStructInitExpr(p, s)
+	case *ParsedObjectAccessExpr:
+		return checkObjectAccessValue(p, s)
+	case *ParsedModuleAccessExpr:
+		return checkModuleAccessExpr(p, s)
+	case *ParsedAsExpr:
+		return checkAsExpr(p, s)
+	case *ParsedArrayLiteralExpr:
+		return checkArrayLiteralExpr(p, s)
+	case *ParsedIfExpr:
+		return checkIfExpr(p, s)
+	case *ParsedIndexExpr:
+		return checkIndexExpr(p, s)
+	case *ParsedSliceExpr:
+		return checkSliceExpr(p, s)
+	case *ParsedTypeArgsExpr:
+		return checkTypeArgsExpr(p, s)
+	case *ParsedFunExpr:
+		return checkFunExpr(p, s)
+	case *ParsedPropagateExpr:
+		return checkPropagateExpr(p, s)
+	}
+	panic("unreachable")
+}
+
+func checkPropagateExpr(p *ParsedPropagateExpr, s *Scope) (*CheckedPropagateExpr, error) {
+	value, err := CheckExpr(p.Value, s)
+	if err != nil {
+		return nil, err
+	}
+	resultType, isResult := (*s.File.Types)[value.TypeId()].(*ResultType)
+	if !isResult {
+		return nil, NewError(p.Question.Pos, "can't use ? operator on %s (a result type is expected)", s.TypeToString(value.TypeId()))
+	}
+	if s.isDeferred() {
+		return nil, NewError(p.Question.Pos, "can't return from a deferred statement")
+	}
+	if s.isIfExpr() {
+		return nil, NewError(p.Question.Pos, "can't return from an if expression")
+	}
+	controlFlow := s.findControlFlow()
+	if controlFlow == nil {
+		return nil, NewError(p.Question.Pos, "can't use ? operator outside of a function")
+	}
+	returnType, isResult := (*s.File.Types)[controlFlow.typeId()].(*ResultType)
+	if !isResult || returnType.Error != resultType.Error {
+		return nil, NewError(p.Question.Pos, "can't propagate an error of %s from a function returning %s", s.TypeToString(value.TypeId()), s.TypeToString(controlFlow.typeId()))
+	}
+	return &CheckedPropagateExpr{
+		Value:   value,
+		Returns: controlFlow.typeId(),
+		Type:    resultType.Value,
+	}, nil
+}
+
+func isResultConstructor(p *ParsedCallExpr, s *Scope) bool {
+	callee, isId := p.Callee.(*ParsedIdExpr)
+	return isId && (callee.Content == RESULT_OK || callee.Content == RESULT_ERR) && s.findName(callee.Content) == nil
+}
+
+func checkResultConstructor(p *ParsedCallExpr, s *Scope) (*CheckedResultExpr, error) {
+	variant := p.Callee.(*ParsedIdExpr).Content
+	isError := variant == RESULT_ERR
+	if len(p.Args) > 1 || (isError && len(p.Args) == 0) {
+		return nil, NewError(p.pos(), "expected 1 arg for %s, but got %d", variant, len(p.Args))
+	}
+	if len(p.Args) == 0 {
+		return &CheckedResultExpr{
+			Type: s.File.TypeId(&ResultVariantType{Value: UNIT_TYPE_ID}),
+		}, nil
+	}
+	value, err := CheckExpr(p.Args[0], s)
+	if err != nil {
+		return nil, err
+	}
+	if value.TypeId() == UNIT_TYPE_ID {
+		return nil, NewError(p.Args[0].pos(), "can't use a value of type %s in %s", s.TypeToString(UNIT_TYPE_ID), variant)
+	}
+	return &CheckedResultExpr{
+		Value: value,
+		Error: isError,
+		Type:  s.File.TypeId(&ResultVariantType{Value: value.TypeId(), Error: isError}),
+	}, nil
+}
+
+func isResultVariant(expr CheckedExpr, s *Scope) bool {
+	_, isVariant := (*s.File.Types)[expr.TypeId()].(*ResultVariantType)
+	return isVariant
+}
+
+func checkFunExpr(p *ParsedFunExpr, s *Scope) (*CheckedFunExpr, error) {
+	params, paramTypes, returnType, err := checkFunSignature(p.Params, p.ReturnType, s)
+	if err != nil {
+		return nil, err
+	}
+	closure := &CheckedFunExpr{
+		Params:     params,
+		ReturnType: returnType,
+		Captures:   make([]CheckedFunParam, 0),
+		Type: s.File.TypeId(&FunctionType{
+			Params:  paramTypes,
+			Returns: returnType,
+		}),
+	}
+	fs := NewScope(s)
+	fs.Closure = closure
+	fs.MethodType = 0
+	for _, param := range params {
+		if err := fs.DefineVar(param.Name, param.Type, false); err != nil {
+			return nil, err
+		}
+	}
+	closure.Body, err = checkBlock(p.Body, fs, &MustReturn{
+		Type: returnType,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defineClosure(closure, s)
+	closure.Name.Pos = p.Fun.Pos
+	return closure, nil
+}
+
+func defineClosure(closure *CheckedFunExpr, s *Scope) {
+	closure.Name = &Token{
+		Pos:     Pos{Filename: s.File.Filename},
+		Kind:    IDENTIFIER,
+		Content: fmt.Sprintf("closure%d", len(s.File.Closures)),
+	}
+	s.File.Closures = append(s.File.Closures, closure)
+}
+
+func isFunctionName(expr CheckedExpr, s *Scope) bool {
+	switch expr := expr.(type) {
+	case *CheckedIdExpr:
+		if _, isFun := (*s.File.Types)[expr.Type].(*FunctionType); !isFun {
+			return false
+		}
+		v := s.findVar(expr.Id.Content)
+		return v == nil || v.Token != expr.Id
+	case *CheckedModuleAccessExpr:
+		importId := s.findImport(expr.Module.Content)
+		if importId == IMPORT_NOT_FOUND {
+			return false
+		}
+		return isFunctionName(expr.Member, s.File.Imports[importId].File.GlobalScope)
+	case *CheckedEnumVariantExpr:
+		return expr.Type != expr.Enum
+	}
+	return false
+}
+
+func newFunctionThunk(fun CheckedExpr, s *Scope) *CheckedFunExpr {
+	funType := (*s.File.Types)[fun.TypeId()].(*FunctionType)
+	params := make([]CheckedFunParam, 0, len(funType.Params))
+	args := make([]CheckedExpr, 0, len(funType.Params))
+	for i, paramType := range funType.Params {
+		param := CheckedFunParam{
+			Name: &Token{Kind: IDENTIFIER, Content: fmt.Sprintf("_%d", i)},
+			Type: paramType,
+		}
+		params = append(params, param)
+		args = append(args, &CheckedIdExpr{
+			Id:   param.Name,
+			Type: paramType,
+		})
+	}
+	call := &CheckedCallExpr{
+		Callee: fun,
+		Args:   args,
+		Type:   funType.Returns,
+	}
+	var stmt CheckedStmt = &CheckedReturn{
+		Value: call,
+	}
+	if funType.Returns == UNIT_TYPE_ID {
+		stmt = &CheckedExprStmt{
+			Expr: call,
+		}
+	}
+	thunk := &CheckedFunExpr{
+		Params:     params,
+		ReturnType: funType.Returns,
+		Body: &CheckedBlock{
+			Stmts: []CheckedStmt{stmt},
+		},
+		Captures: make([]CheckedFunParam, 0),
+		Type:     fun.TypeId(),
+	}
+	defineClosure(thunk, s)
+	return thunk
+}
+
+func checkArrayLiteralExpr(p *ParsedArrayLiteralExpr, s *Scope) (*CheckedArrayLiteralExpr, error) {
+	elems := make([]CheckedExpr, 0, len(p.Elems))
+	for _, elem := range p.Elems {
+		checkedElem, err := CheckExpr(elem, s)
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, checkedElem)
+	}
+	var arrayType *ArrayType
+	if p.Type != nil {
+		typ, err := checkType(p.Type, s)
+		if err != nil {
+			return nil, err
+		}
+		arrayType = (*s.File.Types)[typ].(*ArrayType)
+		if len(elems) > arrayType.Len {
+			return nil, NewError(p.pos(), "too many elements in the array literal: %d (expected at most %d)", len(elems), arrayType.Len)
+		}
+	} else {
+		if len(elems) == 0 {
+			return nil, NewError(p.pos(), "can't infer the type of an empty array literal")
+		}
+		arrayType = &ArrayType{
+			Elem: elems[0].TypeId(),
+			Len:  len(elems),
+		}
+		for _, elem := range elems {
+			if !isUntypedConstant(elem) {
+				arrayType.Elem = elem.TypeId()
+				break
+			}
+		}
+	}
+	if arrayType.Elem == UNIT_TYPE_ID {
+		return nil, NewError(p.pos(), "can't declare an array of %s", s.TypeToString(UNIT_TYPE_ID))
+	}
+	for i, elem := range elems {
+		elem, err := coerce(elem, arrayType.Elem, s)
+		if err != nil {
+			return nil, err
+		}
+		elems[i] = elem
+		if elem.TypeId() != arrayType.Elem {
+			return nil, NewError(p.Elems[i].pos(), "expected %s, but got %s", s.TypeToString(arrayType.Elem), s.TypeToString(elem.TypeId()))
+		}
+	}
+	return &CheckedArrayLiteralExpr{
+		Elems: elems,
+		Type:  s.File.TypeId(arrayType),
+		Pos:   p.pos(),
+	}, nil
+}
+
+func checkIndexExpr(p *ParsedIndexExpr, s *Scope) (*CheckedIndexExpr, error) {
+	object, err := CheckExpr(p.Object, s)
+	if err != nil {
+		return nil, err
+	}
+	index, err := CheckExpr(p.Index, s)
+	if err != nil {
+		return nil, err
+	}
+	if !isInteger(index.TypeId()) {
+		return nil, NewError(p.Index.pos(), "an index must be an integer, but it's %s", s.TypeToString(index.TypeId()))
+	}
+	if sliceType, isSlice := (*s.File.Types)[object.TypeId()].(*SliceType); isSlice {
+		return &CheckedIndexExpr{
+			Object: object,
+			Index:  index,
+			Type:   sliceType.Elem,
+		}, nil
+	}
+	if pointerType, isPointer := (*s.File.Types)[object.TypeId()].(*PointerType); isPointer {
+		if !s.isUnsafe() {
+			return nil, NewError(p.Left.Pos, "can't index %s outside of an unsafe block", s.TypeToString(object.TypeId()))
+		}
+		return &CheckedIndexExpr{
+			Object: object,
+			Index:  index,
+			Type:   pointerType.Type,
+		}, nil
+	}
+	arrayType, isArray := (*s.File.Types)[object.TypeId()].(*ArrayType)
+	if !isArray {
+		return nil, NewError(p.Left.Pos, "can't index %s (an array or a slice type is expected)", s.TypeToString(object.TypeId()))
+	}
+	if literal, isLiteral := index.(*CheckedLiteralExpr); isLiteral && literal.Literal.Kind == INTEGER {
+		if i, err := strconv.ParseUint(literal.Literal.Content, 10, 64); err != nil || i >= uint64(arrayType.Len) {
+			return nil, NewError(p.Index.pos(), "index %s is out of range for %s", literal.Literal.Content, s.TypeToString(object.TypeId()))
+		}
+	}
+	return &CheckedIndexExpr{
+		Object: object,
+		Index:  index,
+		Type:   arrayType.Elem,
+	}, nil
+}
+
+func checkSliceExpr(p *ParsedSliceExpr, s *Scope) (*CheckedSliceExpr, error) {
+	object, err := CheckExpr(p.Object, s)
+	if err != nil {
+		return nil, err
+	}
+	var low, high CheckedExpr
+	if p.Low != nil {
+		low, err = CheckExpr(p.Low, s)
+		if err != nil {
+			return nil, err
+		}
+		if !isInteger(low.TypeId()) {
+			return nil, NewError(p.Low.pos(), "a slice bound must be an integer, but it's %s", s.TypeToString(low.TypeId()))
+		}
+	}
+	if p.High != nil {
+		high, err = CheckExpr(p.High, s)
+		if err != nil {
+			return nil, err
+		}
+		if !isInteger(high.TypeId()) {
+			return nil, NewError(p.High.pos(), "a slice bound must be an integer, but it's %s", s.TypeToString(high.TypeId()))
+		}
+	}
+	var elem TypeId
+	switch t := (*s.File.Types)[object.TypeId()].(type) {
+	case *SliceType:
+		elem = t.Elem
+	case *ArrayType:
+		if isTemporaryValue(object, s) {
+			return nil, NewError(p.Left.Pos, "can't slice a temporary value: %s", s.TypeToString(object.TypeId()))
+		}
+		elem = t.Elem
+	case *PointerType:
+		if !s.isUnsafe() {
+			return nil, NewError(p.Left.Pos, "can't slice %s outside of an unsafe block", s.TypeToString(object.TypeId()))
+		}
+		if high == nil {
+			return nil, NewError(p.Right.Pos, "the upper bound is required when slicing a pointer")
+		}
+		elem = t.Type
+	default:
+		return nil, NewError(p.Left.Pos, "can't slice %s (an array, a slice or a pointer type is expected)", s.TypeToString(object.TypeId()))
+	}
+	if elem == UNIT_TYPE_ID {
+		return nil, NewError(p.Left.Pos, "can't slice %s", s.TypeToString(object.TypeId()))
+	}
+	return &CheckedSliceExpr{
+		Object: object,
+		Low:    low,
+		High:   high,
+		Type:   s.File.TypeId(&SliceType{Elem: elem}),
+	}, nil
+}
+
+func checkAsExpr(p *ParsedAsExpr, s *Scope) (CheckedExpr, error) {
+	val, err := CheckExpr(p.Value, s)
+	if err != nil {
+		return nil, err
+	}
+	switch p.Type.(type) {
+	case *ParsedOptionalType, *ParsedResultType:
+		typ, err := checkType(p.Type, s)
+		if err != nil {
+			return nil, err
+		}
+		val, err = coerce(val, typ, s)
+		if err != nil {
+			return nil, err
+		}
+		if val.TypeId() != typ {
+			return nil, NewError(p.pos(), "can't convert %s to %s", s.TypeToString(val.TypeId()), s.TypeToString(typ))
+		}
+		return val, nil
+	}
+	if isEnum(val.TypeId(), s) {
+		if isTaggedUnion(val.TypeId(), s) {
+			return nil, NewError(p.pos(), "can't convert %s: variants with values can't be converted", s.TypeToString(val.TypeId()))
+		}
+		typ, err := checkType(p.Type, s)
+		if err != nil {
+			return nil, err
+		}
+		if !isInteger(typ) && typ != val.TypeId() {
+			return nil, NewError(p.pos(), "expected an integer type, but got %s", s.TypeToString(typ))
+		}
+		return &CheckedAsExpr{
+			Value: val,
+			Type:  typ,
+		}, nil
+	}
+	if !isScalar(val.TypeId(), s) {
+		return nil, NewError(p.pos(), "expected a scalar type value, but got %s", s.TypeToString(val.TypeId()))
+	}
+	typ, err := checkType(p.Type, s)
+	if err != nil {
+		return nil, err
+	}
+	if !isScalar(typ, s) {
+		return nil, NewError(p.pos(), "expected a scalar type, but got %s", s.TypeToString(typ))
+	}
+	if isUntypedConstant(val) && isInteger(typ) && findFloatConstant(val) == nil {
+		if val, err = convertConstant(val, typ, s); err != nil {
+			return nil, err
+		}
+	}
+	return &CheckedAsExpr{
+		Value: val,
+		Type:  typ,
+	}, nil
+}
+
+func checkModuleAccessExpr(p *ParsedModuleAccessExpr, s *Scope) (CheckedExpr, error) {
+	if c := findConst(p, s); c != nil {
+		return checkConstExpr(p.pos(), c, s)
 	}
-	panic("unimplemented")
+	importId := s.findImport(string(p.Module.Content))
+	if importId == IMPORT_NOT_FOUND {
+		if typ := s.findType(p.Module.Content); typ != nil {
+			if _, isEnum := (*s.File.Types)[typ.TypeId].(*EnumType); isEnum {
+				return checkEnumVariantExpr(p, typ.TypeId, s)
+			}
+		}
+		return nil, NewError(p.Module.Pos, "unresolved import: %s", p.Module.Content)
+	}
+	importScope := s.File.Imports[importId].File.GlobalScope
+	member, err := checkExpr(p.Member, importScope)
+	if err != nil {
+		return nil, err
+	}
+	return &CheckedModuleAccessExpr{
+		Module: p.Module,
+		Member: member,
+		Type:   member.TypeId(),
+	}, nil
 }
 
-func checkContinue(p *ParsedContinue, s *Scope, controlFlow ControlFlow) (*CheckedContinue, error) {
-	if _, mayReturnFromLoop := controlFlow.(*MayReturnFromLoop); mayReturnFromLoop {
-		return &CheckedContinue{
-			Continue: p.Continue,
+func checkEnumVariantExpr(p *ParsedModuleAccessExpr, enumType TypeId, s *Scope) (*CheckedEnumVariantExpr, error) {
+	member, isId := p.Member.(*ParsedIdExpr)
+	if !isId {
+		return nil, NewError(p.Member.pos(), "expected a variant of %s", s.TypeToString(enumType))
+	}
+	typ := (*s.File.Types)[enumType].(*EnumType)
+	if _, exists := typ.Variants[member.Content]; !exists {
+		return nil, NewError(member.Pos, "unknown variant of %s: %s", s.TypeToString(enumType), member.Content)
+	}
+	if payload, hasPayload := typ.Payloads[member.Content]; hasPayload {
+		return &CheckedEnumVariantExpr{
+			Variant: member.Token,
+			Enum:    enumType,
+			Type: s.File.TypeId(&FunctionType{
+				Params:  payload,
+				Returns: enumType,
+			}),
 		}, nil
 	}
-	return nil, NewError(p.pos(), "can't use continue not in a loop")
+	return &CheckedEnumVariantExpr{
+		Variant: member.Token,
+		Enum:    enumType,
+		Type:    enumType,
+	}, nil
 }
 
-func checkBreak(p *ParsedBreak, s *Scope, controlFlow ControlFlow) (*CheckedBreak, error) {
-	if _, mayReturnFromLoop := controlFlow.(*MayReturnFromLoop); mayReturnFromLoop {
-		return &CheckedBreak{
-			Break: p.Break,
-		}, nil
+func checkObjectAccessValue(p *ParsedObjectAccessExpr, s *Scope) (CheckedExpr, error) {
+	checked, err := checkObjectAccessExpr(p, s)
+	if err != nil {
+		return nil, err
 	}
-	return nil, NewError(p.pos(), "can't use break not in a loop")
+	if _, isMethod := checked.(*CheckedMethodExpr); isMethod {
+		return nil, NewError(p.Member.Pos, "method %s can't be used as a value", p.Member.Content)
+	}
+	return checked, nil
 }
 
-func checkWhile(p *ParsedWhile, s *Scope, controlFlow ControlFlow) (*CheckedWhile, error) {
-	if _, mustReturn := controlFlow.(*MustReturn); mustReturn {
-		return nil, NewError(p.pos(), "expected return statement")
+func checkObjectAccessExpr(p *ParsedObjectAccessExpr, s *Scope) (CheckedExpr, error) {
+	var typ TypeId
+	var object CheckedExpr
+	if p.Object == nil {
+		typ = s.MethodType
+	} else {
+		var err error
+		object, err = CheckExpr(p.Object, s)
+		if err != nil {
+			return nil, err
+		}
+		typ = object.TypeId()
 	}
-	cond, err := CheckExpr(p.Condition, s)
+	switch (*s.File.Types)[typ].(type) {
+	case *ArrayType, *SliceType:
+		if p.Member.Content != "len" {
+			return nil, NewError(p.Member.Pos, "unknown field: %s", p.Member.Content)
+		}
+		return &CheckedLenExpr{
+			Object: object,
+			Type:   UINT_TYPE_ID,
+		}, nil
+	}
+	_, isEnumType := (*s.File.Types)[typ].(*EnumType)
+	structType, isStructType := (*s.File.Types)[typ].(*StructType)
+	if !isStructType && !isEnumType {
+		return nil, NewError(p.pos(), "can't use . operator: expected struct type, but got %s", s.TypeToString(typ))
+	}
+	method, err := findMethodOfType(typ, p.Member.Content, s)
 	if err != nil {
 		return nil, err
 	}
-	if cond.TypeId() != BOOL_TYPE_ID {
-		return nil, NewError(p.Condition.pos(), "a condition must be a boolean expression, but it's %s", s.TypeToString(cond.TypeId()))
+	if method != nil {
+		return &CheckedMethodExpr{
+			Object: object,
+			Method: method.Id,
+			Type:   method.TypeId,
+		}, nil
 	}
-	body, err := checkBlock(p.Body, s, &MayReturnFromLoop{
-		Type: controlFlow.typeId(),
-	})
+	if isEnumType {
+		return nil, NewError(p.Member.Pos, "unknown method: %s", p.Member.Content)
+	}
+	fieldType, fieldExists := structType.Fields[string(p.Member.Content)]
+	if !fieldExists {
+		return nil, NewError(p.Member.Pos, "unknown field: %s", p.Member.Content)
+	}
+	return &CheckedMemberAccessExpr{
+		Object: object,
+		Member: p.Member,
+		Type:   fieldType,
+	}, nil
+}
+
+func checkStructInitExpr(p *ParsedStructInitExpr, s *Scope) (*CheckedStructInitExpr, error) {
+	checkedFields := make([]CheckedStructInitField, 0, len(p.Fields))
+	notInitialized := make(map[string]struct{}, len(p.Fields))
+	structTypeId, err := checkStructInitType(p, s)
 	if err != nil {
 		return nil, err
 	}
-	return &CheckedWhile{
-		Cond: cond,
-		Body: body,
+	structType, ok := (*s.File.Types)[structTypeId].(*StructType)
+	if !ok {
+		return nil, NewError(p.pos(), "an invalid type in the struct initializer")
+	}
+	for name := range structType.Fields {
+		notInitialized[name] = struct{}{}
+	}
+	for _, field := range p.Fields {
+		if t, ok := structType.Fields[string(field.Name.Content)]; ok {
+			val, err := CheckExpr(field.Value, s)
+			if err != nil {
+				return nil, err
+			}
+			val, err = coerce(val, t, s)
+			if err != nil {
+				return nil, err
+			}
+			if t != val.TypeId() {
+				return nil, NewError(field.Name.Pos, "expected %s, but got %s", s.TypeToString(t), s.TypeToString(val.TypeId()))
+			}
+			delete(notInitialized, string(field.Name.Content))
+			checkedFields = append(checkedFields, CheckedStructInitField{
+				Name:  field.Name,
+				Value: val,
+			})
+		} else {
+			return nil, NewError(field.Name.Pos, "unknown field: %s", field.Name.Content)
+		}
+	}
+	if len(notInitialized) > 0 {
+		keys := make([]string, 0, len(notInitialized))
+		for k := range notInitialized {
+			keys = append(keys, k)
+		}
+		return nil, NewError(p.pos(), "uninitialized fields: %s", keys)
+	}
+	return &CheckedStructInitExpr{
+		Fields: checkedFields,
+		Pos:    p.pos(),
+		Type:   structTypeId,
+	}, nil
+}
+
+func checkStructInitType(p *ParsedStructInitExpr, s *Scope) (TypeId, error) {
+	g := findGeneric(p.Name, s)
+	if g == nil || g.Struct == nil {
+		return checkType(p.Name, s)
+	}
+	params := make([]ParsedType, 0, len(p.Fields))
+	args := make([]TypeId, 0, len(p.Fields))
+	for _, field := range p.Fields {
+		for _, parsedField := range g.Struct.Fields {
+			if parsedField.Name.Content == field.Name.Content {
+				val, err := CheckExpr(field.Value, s)
+				if err != nil {
+					return NOT_FOUND, err
+				}
+				params = append(params, parsedField.Type)
+				args = append(args, val.TypeId())
+			}
+		}
+	}
+	typeArgs, err := inferTypeArgs(g, g.TypeParams, params, args, p.pos())
+	if err != nil {
+		return NOT_FOUND, err
+	}
+	return instantiateStruct(g, typeArgs)
+}
+
+func checkIdExpr(p *ParsedIdExpr, s *Scope) (*CheckedIdExpr, error) {
+	s.captureVar(p.Content)
+	name := s.findName(string(p.Content))
+	if name == nil {
+		if s.findGeneric(p.Content) != nil {
+			return nil, NewError(p.pos(), "can't use generic %s without type arguments", p.Content)
+		}
+		return nil, NewError(p.pos(), "undeclared: %s", p.Content)
+	}
+	return &CheckedIdExpr{
+		Id:   name.Token,
+		Type: name.TypeId,
+		Pos:  p.Pos,
 	}, nil
 }
 
-func checkIf(p *ParsedIf, s *Scope, controlFlow ControlFlow) (*CheckedIf, error) {
-	if _, mustReturn := controlFlow.(*MustReturn); mustReturn {
-		if p.ElseBody == nil {
-			return nil, NewError(p.pos(), "if statement without else block may not return (add else block with return statement)")
+func checkCallExpr(p *ParsedCallExpr, s *Scope) (*CheckedCallExpr, error) {
+	if g := findGeneric(p.Callee, s); g != nil && g.Fun != nil {
+		return checkGenericCallExpr(p, g, s)
+	}
+	var callee CheckedExpr
+	var err error
+	if objectAccess, isObjectAccess := p.Callee.(*ParsedObjectAccessExpr); isObjectAccess {
+		callee, err = checkObjectAccessExpr(objectAccess, s)
+	} else {
+		callee, err = checkExpr(p.Callee, s)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if funType, ok := (*s.File.Types)[callee.TypeId()].(*FunctionType); ok {
+		args := make([]CheckedExpr, 0, len(p.Args))
+		for _, arg := range p.Args {
+			checkedArg, err := CheckExpr(arg, s)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, checkedArg)
+		}
+		argsTypes, err := coerceArgs(args, funType.Params, s)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(funType.Params, argsTypes) {
+			return nil, NewError(p.pos(), "expected args %s, but got %s", s.typesToStrings(funType.Params), s.typesToStrings(argsTypes))
+		}
+		return &CheckedCallExpr{
+			Callee:   callee,
+			Args:     args,
+			Type:     funType.Returns,
+			Indirect: !isFunctionName(callee, s),
+		}, nil
+	}
+	if metType, ok := (*s.File.Types)[callee.TypeId()].(*MethodType); ok {
+		args := make([]CheckedExpr, 0, len(p.Args))
+		for _, arg := range p.Args {
+			checkedArg, err := CheckExpr(arg, s)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, checkedArg)
+		}
+		argsTypes, err := coerceArgs(args, metType.Params, s)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(metType.Params, argsTypes) {
+			return nil, NewError(p.pos(), "expected args %s, but got %s", s.typesToStrings(metType.Params), s.typesToStrings(argsTypes))
+		}
+		return &CheckedCallExpr{
+			Callee: callee,
+			Args:   args,
+			Type:   metType.Returns,
+		}, nil
+	}
+	return nil, NewError(p.pos(), "callee is not a function: %s", s.TypeToString(callee.TypeId()))
+}
+
+func coerceArgs(args []CheckedExpr, params []TypeId, s *Scope) ([]TypeId, error) {
+	argsTypes := make([]TypeId, 0, len(args))
+	for i, arg := range args {
+		if i < len(params) {
+			coerced, err := coerce(arg, params[i], s)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = coerced
+		}
+		argsTypes = append(argsTypes, args[i].TypeId())
+	}
+	return argsTypes, nil
+}
+
+func coerce(expr CheckedExpr, to TypeId, s *Scope) (CheckedExpr, error) {
+	if expr.TypeId() == to {
+		return expr, nil
+	}
+	if isUntypedConstant(expr) && isArithmetic(to) {
+		return convertConstant(expr, to, s)
+	}
+	if ifExpr, isIfExpr := expr.(*CheckedIfExpr); isIfExpr {
+		return coerceIfExpr(ifExpr, to, s)
+	}
+	switch t := (*s.File.Types)[to].(type) {
+	case *ResultType:
+		if result, isResult := expr.(*CheckedResultExpr); isResult && isResultVariant(expr, s) {
+			return coerceResultVariant(result, t, to, s)
+		}
+		value, err := coerce(expr, t.Value, s)
+		if err != nil {
+			return nil, err
+		}
+		if value.TypeId() == t.Value {
+			return &CheckedResultExpr{
+				Value: value,
+				Type:  to,
+			}, nil
+		}
+		errorValue, err := coerce(expr, t.Error, s)
+		if err != nil {
+			return nil, err
+		}
+		if errorValue.TypeId() == t.Error {
+			return &CheckedResultExpr{
+				Value: errorValue,
+				Error: true,
+				Type:  to,
+			}, nil
+		}
+	case *FunctionType:
+		if fun, isFunExpr := expr.(*CheckedFunExpr); isFunExpr && t.Extern && len(fun.Captures) == 0 && sameSignature((*s.File.Types)[fun.Type].(*FunctionType), t) {
+			fun.Extern = true
+			fun.Type = to
+			return fun, nil
+		}
+	case *OptionalType:
+		if null, isLiteral := expr.(*CheckedLiteralExpr); isLiteral && isNull(expr, s) {
+			return &CheckedLiteralExpr{
+				Literal: null.Literal,
+				Type:    to,
+			}, nil
+		}
+		value, err := coerce(expr, t.Elem, s)
+		if err != nil {
+			return nil, err
+		}
+		if value.TypeId() == t.Elem {
+			return &CheckedOptionalExpr{
+				Value: value,
+				Type:  to,
+			}, nil
+		}
+	}
+	return expr, nil
+}
+
+func coerceResultVariant(result *CheckedResultExpr, t *ResultType, to TypeId, s *Scope) (CheckedExpr, error) {
+	target := t.Value
+	if result.Error {
+		target = t.Error
+	}
+	if result.Value == nil {
+		if target != UNIT_TYPE_ID {
+			return result, nil
 		}
+		return &CheckedResultExpr{
+			Type: to,
+		}, nil
 	}
-	cond, err := CheckExpr(p.Condition, s)
-	if err != nil {
-		return nil, err
+	value, err := coerce(result.Value, target, s)
+	if err != nil || value.TypeId() != target {
+		return result, err
 	}
-	body, err := checkBlock(p.Body, s, controlFlow)
-	if err != nil {
-		return nil, err
+	return &CheckedResultExpr{
+		Value: value,
+		Error: result.Error,
+		Type:  to,
+	}, nil
+}
+
+func sameSignature(a *FunctionType, b *FunctionType) bool {
+	return reflect.DeepEqual(a.Params, b.Params) && a.Returns == b.Returns
+}
+
+func isUntypedConstant(expr CheckedExpr) bool {
+	switch expr := expr.(type) {
+	case *CheckedLiteralExpr:
+		return expr.Literal.Kind == INTEGER || expr.Literal.Kind == FLOAT
+	case *CheckedGroupedExpr:
+		return isUntypedConstant(expr.Inner)
+	case *CheckedUnaryExpr:
+		switch expr.Operator {
+		case CHECKED_UNARY_PLUS, CHECKED_NEGATE, CHECKED_BITNOT:
+			return isUntypedConstant(expr.Operand)
+		}
+	case *CheckedBinaryExpr:
+		switch expr.Op {
+		case CHECKED_ADD, CHECKED_SUBTRACT, CHECKED_MULTIPLY, CHECKED_DIVIDE, CHECKED_REMAINDER,
+			CHECKED_BITAND, CHECKED_BITOR, CHECKED_BITXOR, CHECKED_SHIFTLEFT, CHECKED_SHIFTRIGHT:
+			return isUntypedConstant(expr.Left) && isUntypedConstant(expr.Right)
+		}
 	}
-	var elseBody *CheckedBlock
-	if p.ElseBody != nil {
-		elseBody, err = checkBlock(p.ElseBody, s, controlFlow)
+	return false
+}
+
+func convertConstant(expr CheckedExpr, to TypeId, s *Scope) (CheckedExpr, error) {
+	if isInteger(to) {
+		if float := findFloatConstant(expr); float != nil {
+			return nil, NewError(float.Literal.Pos, "constant %s truncated to %s", float.Literal.Content, s.TypeToString(to))
+		}
+		value, err := constantValue(expr)
 		if err != nil {
 			return nil, err
 		}
+		min, max := integerRange(to)
+		if value.Cmp(min) < 0 || value.Cmp(max) > 0 {
+			return nil, NewError(constantPos(expr), "constant %s overflows %s", value, s.TypeToString(to))
+		}
+	} else if !isFloatConstant(expr) {
+		return expr, nil
 	}
-	return &CheckedIf{
-		Cond:     cond,
-		Body:     body,
-		ElseBody: elseBody,
-	}, nil
+	return retypeConstant(expr, to), nil
 }
 
-func checkReturn(p *ParsedReturn, s *Scope, controlFlow ControlFlow) (*CheckedReturn, error) {
-	if p.Arg == nil {
-		if controlFlow.typeId() != UNIT_TYPE_ID {
-			return nil, NewError(p.pos(), "expected return with an argument of type %s", s.TypeToString(controlFlow.typeId()))
+func retypeConstant(expr CheckedExpr, to TypeId) CheckedExpr {
+	switch expr := expr.(type) {
+	case *CheckedLiteralExpr:
+		return &CheckedLiteralExpr{
+			Literal: expr.Literal,
+			Type:    to,
+		}
+	case *CheckedGroupedExpr:
+		return &CheckedGroupedExpr{
+			Left:  expr.Left,
+			Inner: retypeConstant(expr.Inner, to),
+			Right: expr.Right,
+		}
+	case *CheckedUnaryExpr:
+		return &CheckedUnaryExpr{
+			Pos:      expr.Pos,
+			Operator: expr.Operator,
+			Operand:  retypeConstant(expr.Operand, to),
+			Type:     to,
+		}
+	case *CheckedBinaryExpr:
+		right := expr.Right
+		if expr.Op != CHECKED_SHIFTLEFT && expr.Op != CHECKED_SHIFTRIGHT {
+			right = retypeConstant(right, to)
+		}
+		return &CheckedBinaryExpr{
+			Left:  retypeConstant(expr.Left, to),
+			Op:    expr.Op,
+			Right: right,
+			Type:  to,
 		}
-		return &CheckedReturn{
-			Value: nil,
-		}, nil
 	}
-	arg, err := CheckExpr(p.Arg, s)
-	if err != nil {
-		return nil, err
+	panic("unreachable")
+}
+
+func checkConstants(stmt CheckedStmt, s *Scope) error {
+	if deferred, isDefer := stmt.(*CheckedDefer); isDefer {
+		stmt = deferred.Stmt
 	}
-	if controlFlow.typeId() != arg.TypeId() {
-		return nil, NewError(p.Arg.pos(), "expected %s, but got %s", s.TypeToString(controlFlow.typeId()), s.TypeToString(arg.TypeId()))
+	for _, expr := range stmtExprs(stmt) {
+		if err := checkConstantsInExpr(expr, s); err != nil {
+			return err
+		}
 	}
-	return &CheckedReturn{
-		Value: arg,
-	}, nil
+	return nil
 }
 
-func checkExprStmt(p *ParsedExprStmt, s *Scope, controlFlow ControlFlow) (*CheckedExprStmt, error) {
-	expr, err := CheckExpr(p.Expr, s)
-	if err != nil {
-		return nil, err
+func checkConstantsInExpr(expr CheckedExpr, s *Scope) error {
+	var err error
+	checked := make(map[CheckedExpr]struct{})
+	markChecked := func(expr CheckedExpr) {
+		walkExpr(expr, func(expr CheckedExpr) {
+			checked[expr] = struct{}{}
+		})
 	}
-	return &CheckedExprStmt{
-		Expr: expr,
-	}, nil
+	walkExpr(expr, func(expr CheckedExpr) {
+		if _, isChecked := checked[expr]; isChecked || err != nil {
+			return
+		}
+		if as, isAs := expr.(*CheckedAsExpr); isAs && isInteger(as.Type) && isUntypedConstant(as.Value) {
+			markChecked(as.Value)
+			return
+		}
+		if !isUntypedConstant(expr) {
+			return
+		}
+		markChecked(expr)
+		if isInteger(expr.TypeId()) {
+			_, err = convertConstant(expr, expr.TypeId(), s)
+		}
+	})
+	return err
 }
 
-func checkVar(p *ParsedVar, s *Scope, controlFlow ControlFlow) (*CheckedVar, error) {
-	val, err := CheckExpr(p.Value, s)
-	if err != nil {
-		return nil, err
-	}
-	if val.TypeId() == UNIT_TYPE_ID {
-		return nil, NewError(p.pos(), "can't declare a variable of type %s", s.TypeToString(UNIT_TYPE_ID))
+// checkInterpolations rejects interpolated strings that could outlive the
+// statement using them. The formatted string is freed after the statement,
+// so it can only be passed as an argument.
+func checkInterpolations(stmt CheckedStmt) error {
+	if deferred, isDefer := stmt.(*CheckedDefer); isDefer {
+		stmt = deferred.Stmt
 	}
-	checked := &CheckedVar{
-		Mut:   p.Mut,
-		Name:  &p.Id,
-		Value: val,
+	for _, expr := range stmtExprs(stmt) {
+		if err := checkInterpolationsInExpr(expr); err != nil {
+			return err
+		}
 	}
-	if err := s.DefineVar(checked.Name, val.TypeId(), p.Mut != nil); err != nil {
-		return nil, err
+	return nil
+}
+
+func checkInterpolationsInExpr(expr CheckedExpr) error {
+	var err error
+	args := make(map[CheckedExpr]struct{})
+	walkExpr(expr, func(expr CheckedExpr) {
+		switch expr := expr.(type) {
+		case *CheckedCallExpr:
+			for _, arg := range expr.Args {
+				args[arg] = struct{}{}
+			}
+		case *CheckedInterpolatedStringExpr:
+			if _, isArg := args[expr]; !isArg && err == nil {
+				err = NewError(expr.Format.Literal.Pos, "an interpolated string can only be passed as an argument")
+			}
+			for _, arg := range expr.Args {
+				args[arg] = struct{}{}
+			}
+		}
+	})
+	return err
+}
+
+func findFloatConstant(expr CheckedExpr) *CheckedLiteralExpr {
+	var float *CheckedLiteralExpr
+	walkExpr(expr, func(expr CheckedExpr) {
+		if literal, isLiteral := expr.(*CheckedLiteralExpr); isLiteral && literal.Literal.Kind == FLOAT && float == nil {
+			float = literal
+		}
+	})
+	return float
+}
+
+func isFloatConstant(expr CheckedExpr) bool {
+	switch expr := expr.(type) {
+	case *CheckedLiteralExpr:
+		return true
+	case *CheckedGroupedExpr:
+		return isFloatConstant(expr.Inner)
+	case *CheckedUnaryExpr:
+		return expr.Operator != CHECKED_BITNOT && isFloatConstant(expr.Operand)
+	case *CheckedBinaryExpr:
+		switch expr.Op {
+		case CHECKED_ADD, CHECKED_SUBTRACT, CHECKED_MULTIPLY, CHECKED_DIVIDE:
+			return isFloatConstant(expr.Left) && isFloatConstant(expr.Right)
+		}
 	}
-	return checked, nil
+	return false
 }
 
-func CheckExpr(p ParsedExpr, s *Scope) (CheckedExpr, error) {
-	switch p := p.(type) {
-	case *ParsedUnaryExpr:
-		return checkUnaryExpr(p, s)
-	case *ParsedBinaryExpr:
-		return checkBinaryExpr(p, s)
-	case *ParsedGroupedExpr:
-		return checkGroupedExpr(p, s)
-	case *ParsedLiteralExpr:
-		return checkLiteralExpr(p, s)
-	case *ParsedIdExpr:
-		return checkIdExpr(p, s)
-	case *ParsedCallExpr:
-		return checkCallExpr(p, s)
-	case *ParsedStructInitExpr:
-		return checkStructInitExpr(p, s)
-	case *ParsedObjectAccessExpr:
-		return checkObjectAccessExpr(p, s)
-	case *ParsedModuleAccessExpr:
-		return checkModuleAccessExpr(p, s)
-	case *ParsedAsExpr:
-		return checkAsExpr(p, s)
+func constantPos(expr CheckedExpr) Pos {
+	var pos *Pos
+	walkExpr(expr, func(expr CheckedExpr) {
+		if literal, isLiteral := expr.(*CheckedLiteralExpr); isLiteral && pos == nil {
+			pos = &literal.Literal.Pos
+		}
+	})
+	return *pos
+}
+
+func constantValue(expr CheckedExpr) (*big.Int, error) {
+	switch expr := expr.(type) {
+	case *CheckedLiteralExpr:
+		value, ok := new(big.Int).SetString(expr.Literal.Content, 0)
+		if !ok {
+			return nil, NewError(expr.Literal.Pos, "invalid integer literal: %s", expr.Literal.Content)
+		}
+		return value, nil
+	case *CheckedGroupedExpr:
+		return constantValue(expr.Inner)
+	case *CheckedUnaryExpr:
+		operand, err := constantValue(expr.Operand)
+		if err != nil {
+			return nil, err
+		}
+		switch expr.Operator {
+		case CHECKED_NEGATE:
+			return operand.Neg(operand), nil
+		case CHECKED_BITNOT:
+			return operand.Not(operand), nil
+		}
+		return operand, nil
+	case *CheckedBinaryExpr:
+		left, err := constantValue(expr.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := constantValue(expr.Right)
+		if err != nil {
+			return nil, err
+		}
+		switch expr.Op {
+		case CHECKED_ADD:
+			return left.Add(left, right), nil
+		case CHECKED_SUBTRACT:
+			return left.Sub(left, right), nil
+		case CHECKED_MULTIPLY:
+			return left.Mul(left, right), nil
+		case CHECKED_DIVIDE, CHECKED_REMAINDER:
+			if right.Sign() == 0 {
+				return nil, NewError(constantPos(expr.Right), "division by zero")
+			}
+			if expr.Op == CHECKED_DIVIDE {
+				return left.Quo(left, right), nil
+			}
+			return left.Rem(left, right), nil
+		case CHECKED_BITAND:
+			return left.And(left, right), nil
+		case CHECKED_BITOR:
+			return left.Or(left, right), nil
+		case CHECKED_BITXOR:
+			return left.Xor(left, right), nil
+		case CHECKED_SHIFTLEFT, CHECKED_SHIFTRIGHT:
+			if right.Sign() < 0 || right.Cmp(big.NewInt(64)) >= 0 {
+				return nil, NewError(constantPos(expr.Right), "invalid shift count: %s", right)
+			}
+			if expr.Op == CHECKED_SHIFTLEFT {
+				return left.Lsh(left, uint(right.Uint64())), nil
+			}
+			return left.Rsh(left, uint(right.Uint64())), nil
+		}
 	}
 	panic("unreachable")
 }
 
-func checkAsExpr(p *ParsedAsExpr, s *Scope) (*CheckedAsExpr, error) {
-	val, err := CheckExpr(p.Value, s)
+func integerRange(typeId TypeId) (*big.Int, *big.Int) {
+	bits := map[TypeId]uint{
+		INT_TYPE_ID:    64,
+		INT8_TYPE_ID:   8,
+		INT16_TYPE_ID:  16,
+		INT32_TYPE_ID:  32,
+		INT64_TYPE_ID:  64,
+		UINT_TYPE_ID:   64,
+		UINT8_TYPE_ID:  8,
+		UINT16_TYPE_ID: 16,
+		UINT32_TYPE_ID: 32,
+		UINT64_TYPE_ID: 64,
+	}[typeId]
+	one := big.NewInt(1)
+	switch typeId {
+	case INT_TYPE_ID, INT8_TYPE_ID, INT16_TYPE_ID, INT32_TYPE_ID, INT64_TYPE_ID:
+		max := new(big.Int).Lsh(one, bits-1)
+		return new(big.Int).Neg(max), max.Sub(max, one)
+	}
+	max := new(big.Int).Lsh(one, bits)
+	return big.NewInt(0), max.Sub(max, one)
+}
+
+func checkGenericCallExpr(p *ParsedCallExpr, g *GenericDef, s *Scope) (*CheckedCallExpr, error) {
+	args := make([]CheckedExpr, 0, len(p.Args))
+	argsTypes := make([]TypeId, 0, len(p.Args))
+	for _, arg := range p.Args {
+		checkedArg, err := CheckExpr(arg, s)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, checkedArg)
+		argsTypes = append(argsTypes, checkedArg.TypeId())
+	}
+	params := make([]ParsedType, 0, len(g.Fun.Params))
+	for _, param := range g.Fun.Params {
+		params = append(params, param.Type)
+	}
+	typeArgs, err := inferTypeArgs(g, g.TypeParams, params, argsTypes, p.pos())
 	if err != nil {
 		return nil, err
 	}
-	if !isScalar(val.TypeId(), s) {
-		return nil, NewError(p.pos(), "expected a scalar type value, but got %s", s.TypeToString(val.TypeId()))
+	callee, err := instantiateFun(g, typeArgs, p.Callee.pos())
+	if err != nil {
+		return nil, err
 	}
-	typ, err := checkType(p.Type, s)
+	funType := (*s.File.Types)[callee.TypeId()].(*FunctionType)
+	argsTypes, err = coerceArgs(args, funType.Params, s)
 	if err != nil {
 		return nil, err
 	}
-	if !isScalar(typ, s) {
-		return nil, NewError(p.pos(), "expected a scalar type, but got %s", s.TypeToString(typ))
+	if !reflect.DeepEqual(funType.Params, argsTypes) {
+		return nil, NewError(p.pos(), "expected args %s, but got %s", s.typesToStrings(funType.Params), s.typesToStrings(argsTypes))
 	}
-	return &CheckedAsExpr{
-		Value: val,
-		Type:  typ,
+	return &CheckedCallExpr{
+		Callee: callee,
+		Args:   args,
+		Type:   funType.Returns,
 	}, nil
 }
 
-func checkModuleAccessExpr(p *ParsedModuleAccessExpr, s *Scope) (*CheckedModuleAccessExpr, error) {
-	importId := s.findImport(string(p.Module.Content))
-	if importId == IMPORT_NOT_FOUND {
-		return nil, NewError(p.Module.Pos, "unresolved import: %s", p.Module.Content)
+func checkTypeArgsExpr(p *ParsedTypeArgsExpr, s *Scope) (CheckedExpr, error) {
+	g := findGeneric(p.Object, s)
+	if g == nil {
+		if p.Index != nil {
+			return CheckExpr(p.Index, s)
+		}
+		return nil, NewError(p.pos(), "expected a generic function")
 	}
-	importScope := s.File.Imports[importId].File.GlobalScope
-	member, err := CheckExpr(p.Member, importScope)
+	if g.Fun == nil {
+		return nil, NewError(p.pos(), "expected a generic function, but %s is a generic type", g.Name.Content)
+	}
+	typeArgs, err := checkTypeArgs(g, p.Args, p.pos(), s)
 	if err != nil {
 		return nil, err
 	}
-	return &CheckedModuleAccessExpr{
-		Module: p.Module,
-		Member: member,
-		Type:   member.TypeId(),
-	}, nil
+	return instantiateFun(g, typeArgs, p.pos())
 }
 
-func checkObjectAccessExpr(p *ParsedObjectAccessExpr, s *Scope) (CheckedExpr, error) {
-	var typ TypeId
-	var object CheckedExpr
-	if p.Object == nil {
-		typ = s.MethodType
-	} else {
-		var err error
-		object, err = CheckExpr(p.Object, s)
+func checkTypeArgs(g *GenericDef, args []ParsedType, pos Pos, s *Scope) ([]TypeId, error) {
+	if len(args) != len(g.TypeParams) {
+		return nil, NewError(pos, "expected %d type arguments for %s, but got %d", len(g.TypeParams), g.Name.Content, len(args))
+	}
+	typeArgs := make([]TypeId, 0, len(args))
+	for _, arg := range args {
+		typ, err := checkType(arg, s)
 		if err != nil {
 			return nil, err
 		}
-		typ = object.TypeId()
-	}
-	structType, isStructType := (*s.File.Types)[typ].(*StructType)
-	if !isStructType {
-		return nil, NewError(p.pos(), "can't use . operator: expected struct type, but got %s", s.TypeToString(typ))
-	}
-	method := s.findMethod(s.TypeToString(typ), p.Member.Content, make(map[string]struct{}))
-	if method != nil {
-		return &CheckedMethodExpr{
-			Object: object,
-			Method: method.Id,
-			Type:   method.TypeId,
-		}, nil
-	}
-	fieldType, fieldExists := structType.Fields[string(p.Member.Content)]
-	if !fieldExists {
-		return nil, NewError(p.Member.Pos, "unknown field: %s", p.Member.Content)
+		typeArgs = append(typeArgs, typ)
 	}
-	return &CheckedMemberAccessExpr{
-		Object: object,
-		Member: p.Member,
-		Type:   fieldType,
-	}, nil
+	return typeArgs, nil
 }
 
-func checkStructInitExpr(p *ParsedStructInitExpr, s *Scope) (*CheckedStructInitExpr, error) {
-	checkedFields := make([]CheckedStructInitField, 0, len(p.Fields))
-	notInitialized := make(map[string]struct{}, len(p.Fields))
-	structTypeId, err := checkType(p.Name, s)
-	if err != nil {
-		return nil, err
+func findGeneric(p ParsedNode, s *Scope) *GenericDef {
+	switch p := p.(type) {
+	case *ParsedIdExpr:
+		if s.findName(p.Content) != nil {
+			return nil
+		}
+		return s.findGeneric(p.Content)
+	case *ParsedIdType:
+		if s.findType(p.Content) != nil {
+			return nil
+		}
+		return s.findGeneric(p.Content)
+	case *ParsedModuleAccessExpr:
+		importId := s.findImport(p.Module.Content)
+		if importId == IMPORT_NOT_FOUND {
+			return nil
+		}
+		return findGeneric(p.Member, s.File.Imports[importId].File.GlobalScope)
+	case *ParsedModuleAccessType:
+		importId := s.findImport(p.Module.Content)
+		if importId == IMPORT_NOT_FOUND {
+			return nil
+		}
+		return findGeneric(p.Member, s.File.Imports[importId].File.GlobalScope)
 	}
-	structType, ok := (*s.File.Types)[structTypeId].(*StructType)
-	if !ok {
-		return nil, NewError(p.pos(), "an invalid type in the struct initializer")
+	return nil
+}
+
+func inferTypeArgs(g *GenericDef, typeParams []Token, params []ParsedType, args []TypeId, pos Pos) ([]TypeId, error) {
+	bindings := make(map[string]TypeId, len(typeParams))
+	for i := range params {
+		if i < len(args) {
+			unify(params[i], args[i], typeParams, bindings, g.Scope)
+		}
 	}
-	for name := range structType.Fields {
-		notInitialized[name] = struct{}{}
+	typeArgs := make([]TypeId, 0, len(typeParams))
+	for _, param := range typeParams {
+		arg, bound := bindings[param.Content]
+		if !bound {
+			return nil, NewError(pos, "can't infer type parameter %s of %s", param.Content, g.Name.Content)
+		}
+		typeArgs = append(typeArgs, arg)
 	}
-	for _, field := range p.Fields {
-		if t, ok := structType.Fields[string(field.Name.Content)]; ok {
-			val, err := CheckExpr(field.Value, s)
-			if err != nil {
-				return nil, err
-			}
-			if t != val.TypeId() {
-				return nil, NewError(field.Name.Pos, "expected %s, but got %s", s.TypeToString(t), s.TypeToString(val.TypeId()))
+	return typeArgs, nil
+}
+
+func unify(param ParsedType, arg TypeId, typeParams []Token, bindings map[string]TypeId, s *Scope) {
+	switch param := param.(type) {
+	case *ParsedIdType:
+		for _, typeParam := range typeParams {
+			if typeParam.Content == param.Content {
+				if _, bound := bindings[param.Content]; !bound {
+					bindings[param.Content] = arg
+				}
+				return
 			}
-			delete(notInitialized, string(field.Name.Content))
-			checkedFields = append(checkedFields, CheckedStructInitField{
-				Name:  field.Name,
-				Value: val,
-			})
-		} else {
-			return nil, NewError(field.Name.Pos, "unknown field: %s", field.Name.Content)
 		}
-	}
-	if len(notInitialized) > 0 {
-		keys := make([]string, 0, len(notInitialized))
-		for k := range notInitialized {
-			keys = append(keys, k)
+	case *ParsedPointerType:
+		if t, ok := (*s.File.Types)[arg].(*PointerType); ok {
+			unify(param.To, t.Type, typeParams, bindings, s)
+		}
+	case *ParsedOptionalType:
+		if t, ok := (*s.File.Types)[arg].(*OptionalType); ok {
+			unify(param.Elem, t.Elem, typeParams, bindings, s)
+		}
+	case *ParsedResultType:
+		if t, ok := (*s.File.Types)[arg].(*ResultType); ok {
+			unify(param.Value, t.Value, typeParams, bindings, s)
+			unify(param.Error, t.Error, typeParams, bindings, s)
+		}
+	case *ParsedArrayType:
+		if t, ok := (*s.File.Types)[arg].(*ArrayType); ok {
+			unify(param.Elem, t.Elem, typeParams, bindings, s)
+		}
+	case *ParsedSliceType:
+		if t, ok := (*s.File.Types)[arg].(*SliceType); ok {
+			unify(param.Elem, t.Elem, typeParams, bindings, s)
+		}
+	case *ParsedGenericType:
+		if t, ok := (*s.File.Types)[arg].(*StructType); ok && t.Instance != nil && len(t.Instance.TypeArgs) == len(param.Args) {
+			for i := range param.Args {
+				unify(param.Args[i], t.Instance.TypeArgs[i], typeParams, bindings, s)
+			}
+		}
+	case *ParsedFunType:
+		if t, ok := (*s.File.Types)[arg].(*FunctionType); ok && len(t.Params) == len(param.Params) {
+			for i := range param.Params {
+				unify(param.Params[i], t.Params[i], typeParams, bindings, s)
+			}
+			if param.Returns != nil {
+				unify(param.Returns, t.Returns, typeParams, bindings, s)
+			}
 		}
-		return nil, NewError(p.pos(), "uninitialized fields: %s", keys)
 	}
-	return &CheckedStructInitExpr{
-		Fields: checkedFields,
-		Pos:    p.pos(),
-		Type:   structTypeId,
-	}, nil
 }
 
-func checkIdExpr(p *ParsedIdExpr, s *Scope) (*CheckedIdExpr, error) {
-	name := s.findName(string(p.Content))
-	if name == nil {
-		return nil, NewError(p.pos(), "undeclared: %s", p.Content)
+func instantiateStruct(g *GenericDef, typeArgs []TypeId) (TypeId, error) {
+	if inst := g.findInstance(typeArgs); inst != nil {
+		return inst.TypeId, nil
 	}
-	return &CheckedIdExpr{
-		Id:   name.Token,
-		Type: name.TypeId,
-		Pos:  p.Pos,
-	}, nil
+	c := &CheckedStructDef{
+		Name:   Token{Kind: IDENTIFIER, Content: g.instanceName(typeArgs), Pos: g.Name.Pos},
+		Fields: make([]CheckedStructField, 0, len(g.Struct.Fields)),
+	}
+	if err := g.Scope.DefineType(&c.Name, NewStructType()); err != nil {
+		return NOT_FOUND, err
+	}
+	inst := &GenericInstance{
+		Generic:  g,
+		TypeArgs: typeArgs,
+		Name:     &c.Name,
+		TypeId:   g.Scope.findType(c.Name.Content).TypeId,
+	}
+	(*g.Scope.File.Types)[inst.TypeId].(*StructType).Instance = inst
+	g.Instances = append(g.Instances, inst)
+	g.Scope.File.Structs = append(g.Scope.File.Structs, c)
+	if err := checkStructContents(g.Struct, c, newTypeParamScope(g.Scope, g.TypeParams, typeArgs)); err != nil {
+		return NOT_FOUND, err
+	}
+	return inst.TypeId, nil
 }
 
-func checkCallExpr(p *ParsedCallExpr, s *Scope) (*CheckedCallExpr, error) {
-	callee, err := CheckExpr(p.Callee, s)
+func instantiateFun(g *GenericDef, typeArgs []TypeId, pos Pos) (*CheckedIdExpr, error) {
+	if inst := g.findInstance(typeArgs); inst != nil {
+		return &CheckedIdExpr{
+			Id:   inst.Name,
+			Type: inst.TypeId,
+			Pos:  pos,
+		}, nil
+	}
+	s := newTypeParamScope(g.Scope, g.TypeParams, typeArgs)
+	params, paramTypes, returnType, err := checkFunSignature(g.Fun.Params, g.Fun.ReturnType, s)
 	if err != nil {
 		return nil, err
 	}
-	if funType, ok := (*s.File.Types)[callee.TypeId()].(*FunctionType); ok {
-		args := make([]CheckedExpr, 0, len(p.Args))
-		for _, arg := range p.Args {
-			checkedArg, err := CheckExpr(arg, s)
-			if err != nil {
-				return nil, err
-			}
-			args = append(args, checkedArg)
+	c := &CheckedFunDef{
+		Name:       &Token{Kind: IDENTIFIER, Content: g.instanceName(typeArgs), Pos: g.Fun.Id.Pos},
+		Params:     params,
+		ReturnType: returnType,
+		Body:       &CheckedBlock{},
+	}
+	funType := &FunctionType{
+		Params:  paramTypes,
+		Returns: returnType,
+	}
+	if err := g.Scope.DefineFunction(c.Name, funType); err != nil {
+		return nil, err
+	}
+	inst := &GenericInstance{
+		Generic:  g,
+		TypeArgs: typeArgs,
+		Name:     c.Name,
+		TypeId:   g.Scope.File.TypeId(funType),
+	}
+	g.Instances = append(g.Instances, inst)
+	g.Scope.File.Funs = append(g.Scope.File.Funs, c)
+	if err := checkFunBlock(g.Fun, c, s); err != nil {
+		return nil, err
+	}
+	return &CheckedIdExpr{
+		Id:   inst.Name,
+		Type: inst.TypeId,
+		Pos:  pos,
+	}, nil
+}
+
+func findInstanceMethod(inst *GenericInstance, name string) (*MethodName, error) {
+	g := inst.Generic
+	if method := g.Scope.findMethod(inst.Name.Content, name, make(map[string]struct{})); method != nil {
+		return method, nil
+	}
+	for _, def := range g.Methods {
+		if def.Id.Content != name {
+			continue
 		}
-		argsTypes := make([]TypeId, 0, len(p.Args))
-		for _, arg := range args {
-			argsTypes = append(argsTypes, arg.TypeId())
+		s := newTypeParamScope(g.Scope, def.TypeParams, inst.TypeArgs)
+		params, paramTypes, returnType, err := checkFunSignature(def.Params, def.ReturnType, s)
+		if err != nil {
+			return nil, err
 		}
-		if !reflect.DeepEqual(funType.Params, argsTypes) {
-			return nil, NewError(p.pos(), "expected args %s, but got %s", s.typesToStrings(funType.Params), s.typesToStrings(argsTypes))
+		c := &CheckedMethodDef{
+			Typename:   inst.Name,
+			Name:       &Token{Kind: IDENTIFIER, Content: def.Id.Content, Pos: def.Id.Pos},
+			Params:     params,
+			ReturnType: returnType,
+			Body:       &CheckedBlock{},
 		}
-		return &CheckedCallExpr{
-			Callee: callee,
-			Args:   args,
-			Type:   funType.Returns,
-		}, nil
-	}
-	if metType, ok := (*s.File.Types)[callee.TypeId()].(*MethodType); ok {
-		args := make([]CheckedExpr, 0, len(p.Args))
-		for _, arg := range p.Args {
-			checkedArg, err := CheckExpr(arg, s)
-			if err != nil {
-				return nil, err
-			}
-			args = append(args, checkedArg)
+		if err := g.Scope.DefineMethod(c.Typename, c.Name, &MethodType{
+			This:    inst.TypeId,
+			Params:  paramTypes,
+			Returns: returnType,
+		}); err != nil {
+			return nil, err
 		}
-		argsTypes := make([]TypeId, 0, len(p.Args))
-		for _, arg := range args {
-			argsTypes = append(argsTypes, arg.TypeId())
+		g.Scope.File.Methods = append(g.Scope.File.Methods, c)
+		if err := checkMethodBlock(def, c, s, inst.TypeId); err != nil {
+			return nil, err
 		}
-		if !reflect.DeepEqual(metType.Params, argsTypes) {
-			return nil, NewError(p.pos(), "expected args %s, but got %s", s.typesToStrings(metType.Params), s.typesToStrings(argsTypes))
+		return g.Scope.findMethod(inst.Name.Content, name, make(map[string]struct{})), nil
+	}
+	return nil, nil
+}
+
+func newTypeParamScope(parent *Scope, typeParams []Token, typeArgs []TypeId) *Scope {
+	s := NewScope(nil)
+	s.Parent = parent
+	s.File = parent.File
+	for i := range typeParams {
+		s.Types[typeParams[i].Content] = &TypeName{
+			Token:  &typeParams[i],
+			TypeId: typeArgs[i],
 		}
-		return &CheckedCallExpr{
-			Callee: callee,
-			Args:   args,
-			Type:   metType.Returns,
-		}, nil
 	}
-	return nil, NewError(p.pos(), "callee is not a function: %s", s.TypeToString(callee.TypeId()))
+	return s
 }
 
 func (s *Scope) typesToStrings(types []TypeId) (res []string) {
@@ -782,32 +3212,112 @@ func (s *Scope) typesToStrings(types []TypeId) (res []string) {
 	return
 }
 
-func checkLiteralExpr(p *ParsedLiteralExpr, s *Scope) (*CheckedLiteralExpr, error) {
+func checkLiteralExpr(p *ParsedLiteralExpr, s *Scope) (CheckedExpr, error) {
 	switch p.Kind {
-	case INTEGER:
-		return &CheckedLiteralExpr{
+	case INTEGER, FLOAT:
+		content, suffix, err := parseNumericLiteral(p.Token)
+		if err != nil {
+			return nil, err
+		}
+		literal := &CheckedLiteralExpr{
 			Literal: p.Token,
 			Type:    INT32_TYPE_ID,
+		}
+		literal.Literal.Content = content
+		if p.Kind == FLOAT {
+			literal.Type = FLOAT64_TYPE_ID
+		}
+		if suffix == NOT_FOUND {
+			return literal, nil
+		}
+		if isInteger(suffix) {
+			if _, err := convertConstant(literal, suffix, s); err != nil {
+				return nil, err
+			}
+		}
+		return &CheckedAsExpr{
+			Value: literal,
+			Type:  suffix,
 		}, nil
-	case FLOAT:
+	case STRING:
 		return &CheckedLiteralExpr{
 			Literal: p.Token,
-			Type:    FLOAT64_TYPE_ID,
+			Type:    s.File.TypeId(&PointerType{Type: CHAR_TYPE_ID}),
 		}, nil
-	case STRING:
+	case CHARACTER:
 		return &CheckedLiteralExpr{
 			Literal: p.Token,
-			Type:    s.File.TypeId(&PointerType{Type: CHAR_TYPE_ID}),
+			Type:    CHAR_TYPE_ID,
 		}, nil
 	case TRUE, FALSE:
 		return &CheckedLiteralExpr{
 			Literal: p.Token,
 			Type:    BOOL_TYPE_ID,
 		}, nil
+	case NULL:
+		return &CheckedLiteralExpr{
+			Literal: p.Token,
+			Type:    s.File.TypeId(&NullType{}),
+		}, nil
 	}
 	panic("unreachable")
 }
 
+// checkInterpolatedStringExpr checks the values of an interpolated string,
+// building the format string that converts each one with a conversion
+// specifier matching its type.
+func checkInterpolatedStringExpr(p *ParsedInterpolatedStringExpr, s *Scope) (*CheckedInterpolatedStringExpr, error) {
+	constChar := s.File.TypeId(&PointerType{Type: CHAR_TYPE_ID})
+	var format strings.Builder
+	var args []CheckedExpr
+	for i, part := range p.Parts {
+		format.WriteString(strings.ReplaceAll(part.Content, "%", "%%"))
+		if i == len(p.Exprs) {
+			break
+		}
+		value, err := CheckExpr(p.Exprs[i], s)
+		if err != nil {
+			return nil, err
+		}
+		switch typeId := value.TypeId(); {
+		case isInteger(typeId):
+			if min, _ := integerRange(typeId); min.Sign() < 0 {
+				format.WriteString("%lld")
+				value = &CheckedAsExpr{Value: value, Type: INT64_TYPE_ID}
+			} else {
+				format.WriteString("%llu")
+				value = &CheckedAsExpr{Value: value, Type: UINT64_TYPE_ID}
+			}
+		case isArithmetic(typeId):
+			format.WriteString("%g")
+			value = &CheckedAsExpr{Value: value, Type: FLOAT64_TYPE_ID}
+		case typeId == CHAR_TYPE_ID:
+			format.WriteString("%c")
+		case typeId == BOOL_TYPE_ID:
+			format.WriteString("%s")
+			value = &CheckedIfExpr{
+				Cond:     value,
+				Body:     &CheckedBlock{Stmts: []CheckedStmt{&CheckedExprStmt{Expr: &CheckedLiteralExpr{Literal: Token{Kind: STRING, Content: "true"}, Type: constChar}}}},
+				ElseBody: &CheckedBlock{Stmts: []CheckedStmt{&CheckedExprStmt{Expr: &CheckedLiteralExpr{Literal: Token{Kind: STRING, Content: "false"}, Type: constChar}}}},
+				Type:     constChar,
+			}
+		case typeId == constChar:
+			format.WriteString("%s")
+		default:
+			return nil, NewError(p.Exprs[i].pos(), "can't interpolate a value of type %s", s.TypeToString(typeId))
+		}
+		args = append(args, value)
+	}
+	return &CheckedInterpolatedStringExpr{
+		Format: &CheckedLiteralExpr{
+			Literal: Token{Pos: p.Parts[0].Pos, Kind: STRING, Content: format.String()},
+			Type:    constChar,
+		},
+		Args: args,
+		Type: constChar,
+	}, nil
+}
+
 func checkGroupedExpr(p *ParsedGroupedExpr, s *Scope) (*CheckedGroupedExpr, error) {
 	inner, err := CheckExpr(p.Inner, s)
 	if err != nil {
@@ -820,7 +3330,7 @@ func checkGroupedExpr(p *ParsedGroupedExpr, s *Scope) (*CheckedGroupedExpr, erro
 	}, nil
 }
 
-func checkBinaryExpr(p *ParsedBinaryExpr, s *Scope) (*CheckedBinaryExpr, error) {
+func checkBinaryExpr(p *ParsedBinaryExpr, s *Scope) (CheckedExpr, error) {
 	left, err := CheckExpr(p.Left, s)
 	if err != nil {
 		return nil, err
@@ -829,6 +3339,20 @@ func checkBinaryExpr(p *ParsedBinaryExpr, s *Scope) (*CheckedBinaryExpr, error)
 	if err != nil {
 		return nil, err
 	}
+	switch p.Op.Kind {
+	case EQEQ, BANGEQ:
+		if nullCheck := checkNullComparison(p.Op, left, right, s); nullCheck != nil {
+			return nullCheck, nil
+		}
+	}
+	left, right, err = coerceOperands(p.Op, left, right, s)
+	if err != nil {
+		return nil, err
+	}
+	overloaded, err := checkOverloadedBinaryOperator(p.Op, left, right, s)
+	if err != nil || overloaded != nil {
+		return overloaded, err
+	}
 	operator, returnType, err := checkBinaryOperator(p.Op, left, right.TypeId(), s)
 	if err != nil {
 		return nil, err
@@ -841,13 +3365,169 @@ func checkBinaryExpr(p *ParsedBinaryExpr, s *Scope) (*CheckedBinaryExpr, error)
 	}, nil
 }
 
+func coerceOperands(operator Token, left CheckedExpr, right CheckedExpr, s *Scope) (CheckedExpr, CheckedExpr, error) {
+	switch operator.Kind {
+	case LTLT, GTGT, LTLTEQ, GTGTEQ, AMPAMP, PIPEPIPE:
+		return left, right, nil
+	}
+	if _, isPointer := (*s.File.Types)[left.TypeId()].(*PointerType); isPointer && isUntypedConstant(right) {
+		right, err := coerce(right, INT_TYPE_ID, s)
+		return left, right, err
+	}
+	if isUntypedConstant(left) && isUntypedConstant(right) {
+		if left.TypeId() == FLOAT64_TYPE_ID {
+			right, err := coerce(right, left.TypeId(), s)
+			return left, right, err
+		}
+		left, err := coerce(left, right.TypeId(), s)
+		return left, right, err
+	}
+	if isUntypedConstant(left) && operator.Kind != EQ && !isCompoundAssign(operator.Kind) {
+		left, err := coerce(left, right.TypeId(), s)
+		return left, right, err
+	}
+	right, err := coerce(right, left.TypeId(), s)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch operator.Kind {
+	case SLASH, PERCENT, SLASHEQ, PERCENTEQ:
+		if isInteger(right.TypeId()) && isUntypedConstant(right) {
+			value, err := constantValue(right)
+			if err != nil {
+				return nil, nil, err
+			}
+			if value.Sign() == 0 {
+				return nil, nil, NewError(constantPos(right), "division by zero")
+			}
+		}
+	}
+	return left, right, nil
+}
+
+func checkNullComparison(operator Token, left CheckedExpr, right CheckedExpr, s *Scope) CheckedExpr {
+	if isNull(left, s) {
+		left, right = right, left
+	}
+	if _, isOptional := (*s.File.Types)[left.TypeId()].(*OptionalType); !isOptional || !isNull(right, s) {
+		return nil
+	}
+	op := CHECKED_EQUALS
+	if operator.Kind == BANGEQ {
+		op = CHECKED_NOTEQUALS
+	}
+	return &CheckedBinaryExpr{
+		Left: left,
+		Op:   op,
+		Right: &CheckedLiteralExpr{
+			Literal: right.(*CheckedLiteralExpr).Literal,
+			Type:    left.TypeId(),
+		},
+		Type: BOOL_TYPE_ID,
+	}
+}
+
+func isNull(expr CheckedExpr, s *Scope) bool {
+	_, isNull := (*s.File.Types)[expr.TypeId()].(*NullType)
+	return isNull
+}
+
+func checkOverloadedBinaryOperator(operator Token, left CheckedExpr, right CheckedExpr, s *Scope) (CheckedExpr, error) {
+	op := operator.Kind
+	if compound, isCompound := compoundAssignOperators[op]; isCompound {
+		op = compound.Op
+	}
+	method, err := findOperatorMethod(operatorMethods[op], left.TypeId(), s)
+	if method == nil && (op == EQEQ || op == BANGEQ) && err == nil {
+		method, err = findOperatorMethod(operatorMethods[LT], left.TypeId(), s)
+	}
+	if err != nil || method == nil {
+		return nil, err
+	}
+	if left.TypeId() != right.TypeId() {
+		return nil, NewError(operator.Pos, "operator %s is not defined for types %s and %s", operator.Kind, s.TypeToString(left.TypeId()), s.TypeToString(right.TypeId()))
+	}
+	var result CheckedExpr = &CheckedCallExpr{
+		Callee: &CheckedMethodExpr{
+			Object: left,
+			Method: method.Id,
+			Type:   method.TypeId,
+		},
+		Args: []CheckedExpr{right},
+		Type: (*s.File.Types)[method.TypeId].(*MethodType).Returns,
+	}
+	switch op {
+	case EQEQ, BANGEQ, LT, LTEQ, GT, GTEQ:
+		if result.TypeId() == BOOL_TYPE_ID {
+			if op == BANGEQ {
+				result = &CheckedUnaryExpr{
+					Pos:      operator.Pos,
+					Operator: CHECKED_NOT,
+					Operand:  result,
+					Type:     BOOL_TYPE_ID,
+				}
+			}
+			break
+		}
+		zero := &CheckedLiteralExpr{
+			Literal: Token{Kind: INTEGER, Content: "0", Pos: operator.Pos},
+			Type:    INT32_TYPE_ID,
+		}
+		compared, _, err := checkBinaryOperator(Token{Kind: op, Pos: operator.Pos}, result, INT32_TYPE_ID, s)
+		if err != nil {
+			return nil, err
+		}
+		result = &CheckedBinaryExpr{
+			Left:  result,
+			Op:    compared,
+			Right: zero,
+			Type:  BOOL_TYPE_ID,
+		}
+	}
+	if op == operator.Kind {
+		return result, nil
+	}
+	if isTemporaryValue(left, s) {
+		return nil, NewError(operator.Pos, "can't assign to a temporary value: %s", s.TypeToString(left.TypeId()))
+	}
+	if !isMutable(left, s) {
+		return nil, NewError(operator.Pos, "left side of an expression is not mutable")
+	}
+	temp := &CheckedIdExpr{
+		Id:   &Token{Kind: IDENTIFIER, Content: "_lhs", Pos: operator.Pos},
+		Type: s.File.TypeId(&PointerType{Type: left.TypeId()}),
+	}
+	target := &CheckedUnaryExpr{Pos: operator.Pos, Operator: CHECKED_DEREF, Operand: temp, Type: left.TypeId()}
+	result.(*CheckedCallExpr).Callee.(*CheckedMethodExpr).Object = target
+	return &CheckedOverloadedAssignExpr{
+		Left: left,
+		Temp: temp,
+		Value: &CheckedBinaryExpr{
+			Left:  target,
+			Op:    CHECKED_ASSIGN,
+			Right: result,
+			Type:  left.TypeId(),
+		},
+		Type: left.TypeId(),
+	}, nil
+}
+
 func checkBinaryOperator(operator Token, left CheckedExpr, right TypeId, s *Scope) (CheckedBinaryOperator, TypeId, error) {
+	if operator.Kind == LTLT || operator.Kind == GTGT {
+		return checkShiftOperator(operator, left, right, s)
+	}
+	if isCompoundAssign(operator.Kind) {
+		return checkCompoundAssignOperator(operator, left, right, s)
+	}
+	if _, isPointer := (*s.File.Types)[left.TypeId()].(*PointerType); isPointer && (operator.Kind == PLUS || operator.Kind == MINUS) {
+		return checkPointerArithmetic(operator, left, right, s)
+	}
 	if left.TypeId() != right {
 		return INVALID_BINARY_OPERATOR, NOT_FOUND, NewError(operator.Pos, "operator %s is not defined for types %s and %s", operator.Kind, s.TypeToString(left.TypeId()), s.TypeToString(right))
 	}
 	switch operator.Kind {
 	case EQ:
-		if isTemporaryValue(left) {
+		if isTemporaryValue(left, s) {
 			return INVALID_BINARY_OPERATOR, NOT_FOUND, NewError(operator.Pos, "can't assign to a temporary value: %s", s.TypeToString(left.TypeId()))
 		}
 		if isMutable(left, s) {
@@ -884,6 +3564,34 @@ func checkBinaryOperator(operator Token, left CheckedExpr, right TypeId, s *Scop
 		if operator.Kind == BANGEQ {
 			return CHECKED_NOTEQUALS, BOOL_TYPE_ID, nil
 		}
+	case PERCENT:
+		if !traitIsImplemented(REMAINDER_TRAIT, left.TypeId(), s) {
+			return INVALID_BINARY_OPERATOR, NOT_FOUND, NewError(operator.Pos, "operator %% is not defined for types %s and %s (try to implement %s trait)", s.TypeToString(left.TypeId()), s.TypeToString(right), REMAINDER_TRAIT)
+		}
+		return CHECKED_REMAINDER, left.TypeId(), nil
+	case AMP:
+		if !traitIsImplemented(BITAND_TRAIT, left.TypeId(), s) {
+			return INVALID_BINARY_OPERATOR, NOT_FOUND, NewError(operator.Pos, "operator & is not defined for types %s and %s (try to implement %s trait)", s.TypeToString(left.TypeId()), s.TypeToString(right), BITAND_TRAIT)
+		}
+		return CHECKED_BITAND, left.TypeId(), nil
+	case PIPE:
+		if !traitIsImplemented(BITOR_TRAIT, left.TypeId(), s) {
+			return INVALID_BINARY_OPERATOR, NOT_FOUND, NewError(operator.Pos, "operator | is not defined for types %s and %s (try to implement %s trait)", s.TypeToString(left.TypeId()), s.TypeToString(right), BITOR_TRAIT)
+		}
+		return CHECKED_BITOR, left.TypeId(), nil
+	case CARET:
+		if !traitIsImplemented(BITXOR_TRAIT, left.TypeId(), s) {
+			return INVALID_BINARY_OPERATOR, NOT_FOUND, NewError(operator.Pos, "operator ^ is not defined for types %s and %s (try to implement %s trait)", s.TypeToString(left.TypeId()), s.TypeToString(right), BITXOR_TRAIT)
+		}
+		return CHECKED_BITXOR, left.TypeId(), nil
+	case AMPAMP, PIPEPIPE:
+		if left.TypeId() != BOOL_TYPE_ID {
+			return INVALID_BINARY_OPERATOR, NOT_FOUND, NewError(operator.Pos, "operator %s is not defined for types %s and %s (expected %s)", operator.Kind, s.TypeToString(left.TypeId()), s.TypeToString(right), s.TypeToString(BOOL_TYPE_ID))
+		}
+		if operator.Kind == AMPAMP {
+			return CHECKED_AND, BOOL_TYPE_ID, nil
+		}
+		return CHECKED_OR, BOOL_TYPE_ID, nil
 	case LT, LTEQ, GT, GTEQ:
 		if !traitIsImplemented(ORDERING_TRAIT, left.TypeId(), s) {
 			return INVALID_BINARY_OPERATOR, NOT_FOUND, NewError(operator.Pos, "operator %s is not defined for types %s and %s (try to implement %s trait)", operator.Kind, s.TypeToString(left.TypeId()), s.TypeToString(right), ORDERING_TRAIT)
@@ -904,6 +3612,69 @@ func checkBinaryOperator(operator Token, left CheckedExpr, right TypeId, s *Scop
 	panic("unreachable")
 }
 
+func checkShiftOperator(operator Token, left CheckedExpr, right TypeId, s *Scope) (CheckedBinaryOperator, TypeId, error) {
+	if !traitIsImplemented(SHIFT_TRAIT, left.TypeId(), s) {
+		return INVALID_BINARY_OPERATOR, NOT_FOUND, NewError(operator.Pos, "operator %s is not defined for types %s and %s (try to implement %s trait)", operator.Kind, s.TypeToString(left.TypeId()), s.TypeToString(right), SHIFT_TRAIT)
+	}
+	if !isInteger(right) {
+		return INVALID_BINARY_OPERATOR, NOT_FOUND, NewError(operator.Pos, "a shift count must be an integer, but it's %s", s.TypeToString(right))
+	}
+	if operator.Kind == LTLT {
+		return CHECKED_SHIFTLEFT, left.TypeId(), nil
+	}
+	return CHECKED_SHIFTRIGHT, left.TypeId(), nil
+}
+
+func checkPointerArithmetic(operator Token, left CheckedExpr, right TypeId, s *Scope) (CheckedBinaryOperator, TypeId, error) {
+	if !s.isUnsafe() {
+		return INVALID_BINARY_OPERATOR, NOT_FOUND, NewError(operator.Pos, "pointer arithmetic is not allowed outside of an unsafe block")
+	}
+	if operator.Kind == MINUS && left.TypeId() == right {
+		return CHECKED_SUBTRACT, INT_TYPE_ID, nil
+	}
+	if !isInteger(right) {
+		return INVALID_BINARY_OPERATOR, NOT_FOUND, NewError(operator.Pos, "operator %s is not defined for types %s and %s (an integer offset is expected)", operator.Kind, s.TypeToString(left.TypeId()), s.TypeToString(right))
+	}
+	if operator.Kind == PLUS {
+		return CHECKED_ADD, left.TypeId(), nil
+	}
+	return CHECKED_SUBTRACT, left.TypeId(), nil
+}
+
+var compoundAssignOperators = map[TokenKind]struct {
+	Op     TokenKind
+	Assign CheckedBinaryOperator
+}{
+	PLUSEQ:    {PLUS, CHECKED_ADD_ASSIGN},
+	MINUSEQ:   {MINUS, CHECKED_SUBTRACT_ASSIGN},
+	STAREQ:    {STAR, CHECKED_MULTIPLY_ASSIGN},
+	SLASHEQ:   {SLASH, CHECKED_DIVIDE_ASSIGN},
+	PERCENTEQ: {PERCENT, CHECKED_REMAINDER_ASSIGN},
+	AMPEQ:     {AMP, CHECKED_BITAND_ASSIGN},
+	PIPEEQ:    {PIPE, CHECKED_BITOR_ASSIGN},
+	CARETEQ:   {CARET, CHECKED_BITXOR_ASSIGN},
+	LTLTEQ:    {LTLT, CHECKED_SHIFTLEFT_ASSIGN},
+	GTGTEQ:    {GTGT, CHECKED_SHIFTRIGHT_ASSIGN},
+}
+
+func checkCompoundAssignOperator(operator Token, left CheckedExpr, right TypeId, s *Scope) (CheckedBinaryOperator, TypeId, error) {
+	if isTemporaryValue(left, s) {
+		return INVALID_BINARY_OPERATOR, NOT_FOUND, NewError(operator.Pos, "can't assign to a temporary value: %s", s.TypeToString(left.TypeId()))
+	}
+	if !isMutable(left, s) {
+		return INVALID_BINARY_OPERATOR, NOT_FOUND, NewError(operator.Pos, "left side of an expression is not mutable")
+	}
+	compound := compoundAssignOperators[operator.Kind]
+	_, typeId, err := checkBinaryOperator(Token{Kind: compound.Op, Pos: operator.Pos}, left, right, s)
+	if err != nil {
+		return INVALID_BINARY_OPERATOR, NOT_FOUND, err
+	}
+	if typeId != left.TypeId() {
+		return INVALID_BINARY_OPERATOR, NOT_FOUND, NewError(operator.Pos, "operator %s is not defined for types %s and %s", operator.Kind, s.TypeToString(left.TypeId()), s.TypeToString(right))
+	}
+	return compound.Assign, left.TypeId(), nil
+}
+
 func isMutable(left CheckedExpr, s *Scope) bool {
 	switch left := left.(type) {
 	case *CheckedIdExpr:
@@ -917,15 +3688,36 @@ func isMutable(left CheckedExpr, s *Scope) bool {
 		return isMutable(left.Object, s)
 	case *CheckedModuleAccessExpr:
 		return isMutable(left.Member, s.File.Imports[s.findImport(left.Module.Content)].File.GlobalScope)
+	case *CheckedIndexExpr:
+		switch (*s.File.Types)[left.Object.TypeId()].(type) {
+		case *SliceType, *PointerType:
+			return true
+		}
+		return isMutable(left.Object, s)
 	}
 	return false
 }
 
-func checkUnaryExpr(p *ParsedUnaryExpr, s *Scope) (*CheckedUnaryExpr, error) {
+func checkUnaryExpr(p *ParsedUnaryExpr, s *Scope) (CheckedExpr, error) {
 	operand, err := CheckExpr(p.Operand, s)
 	if err != nil {
 		return nil, err
 	}
+	method, err := findOperatorMethod(unaryOperatorMethods[p.Operator.Kind], operand.TypeId(), s)
+	if err != nil {
+		return nil, err
+	}
+	if method != nil {
+		return &CheckedCallExpr{
+			Callee: &CheckedMethodExpr{
+				Object: operand,
+				Method: method.Id,
+				Type:   method.TypeId,
+			},
+			Args: []CheckedExpr{},
+			Type: operand.TypeId(),
+		}, nil
+	}
 	operator, resultType, err := checkUnaryOperator(p.Operator, operand, s)
 	if err != nil {
 		return nil, err
@@ -946,7 +3738,7 @@ func checkUnaryOperator(operator Token, operand CheckedExpr, s *Scope) (CheckedU
 		}
 		return CHECKED_NEGATE, operand.TypeId(), nil
 	case AMP:
-		if isTemporaryValue(operand) {
+		if isTemporaryValue(operand, s) {
 			return INVALID_UNARY_OPERATOR, NOT_FOUND, NewError(operator.Pos, "can't take an address of a temporary value: %s", s.TypeToString(operand.TypeId()))
 		}
 		return CHECKED_ADDRESS, s.File.TypeId(&PointerType{
@@ -956,29 +3748,124 @@ func checkUnaryOperator(operator Token, operand CheckedExpr, s *Scope) (CheckedU
 		if pointerType, isPointer := (*s.File.Types)[operand.TypeId()].(*PointerType); isPointer {
 			return CHECKED_DEREF, pointerType.Type, nil
 		}
+		if _, isOptional := (*s.File.Types)[operand.TypeId()].(*OptionalType); isOptional {
+			return INVALID_UNARY_OPERATOR, NOT_FOUND, NewError(operator.Pos, "can't use * operator on %s (check it for null with if first)", s.TypeToString(operand.TypeId()))
+		}
 		return INVALID_UNARY_OPERATOR, NOT_FOUND, NewError(operator.Pos, "can't use * operator on %s (a pointer type is expected)", s.TypeToString(operand.TypeId()))
+	case TILDE:
+		if !traitIsImplemented(BITNOT_TRAIT, operand.TypeId(), s) {
+			return INVALID_UNARY_OPERATOR, NOT_FOUND, NewError(operator.Pos, "operator ~ is not defined for type %s (try to implement %s trait)", s.TypeToString(operand.TypeId()), BITNOT_TRAIT)
+		}
+		return CHECKED_BITNOT, operand.TypeId(), nil
+	case BANG:
+		if operand.TypeId() != BOOL_TYPE_ID {
+			return INVALID_UNARY_OPERATOR, NOT_FOUND, NewError(operator.Pos, "operator ! is not defined for type %s (expected %s)", s.TypeToString(operand.TypeId()), s.TypeToString(BOOL_TYPE_ID))
+		}
+		return CHECKED_NOT, BOOL_TYPE_ID, nil
 	}
 	panic("unreachable")
 }
 
-func isTemporaryValue(operand CheckedExpr) bool {
+func isTemporaryValue(operand CheckedExpr, s *Scope) bool {
 	switch operand := operand.(type) {
-	case *CheckedUnaryExpr, *CheckedBinaryExpr, *CheckedLiteralExpr, *CheckedCallExpr, *CheckedStructInitExpr:
+	case *CheckedUnaryExpr, *CheckedBinaryExpr, *CheckedLiteralExpr, *CheckedCallExpr, *CheckedIfExpr, *CheckedStructInitExpr, *CheckedArrayLiteralExpr, *CheckedSliceExpr, *CheckedLenExpr, *CheckedEnumVariantExpr, *CheckedFunExpr, *CheckedOptionalExpr, *CheckedResultExpr, *CheckedPropagateExpr, *CheckedOverloadedAssignExpr, *CheckedInterpolatedStringExpr:
 		return true
 	case *CheckedGroupedExpr:
-		return isTemporaryValue(operand.Inner)
+		return isTemporaryValue(operand.Inner, s)
+	case *CheckedIndexExpr:
+		if _, isPointer := (*s.File.Types)[operand.Object.TypeId()].(*PointerType); isPointer {
+			return false
+		}
+		return isTemporaryValue(operand.Object, s)
 	case *CheckedIdExpr, *CheckedMemberAccessExpr:
 		return false
 	}
 	return false
 }
 
+// operatorMethods maps an operator on a user-defined type to the method of a
+// builtin trait it calls: a + b calls a.add(b) and a < b compares
+// a.compare(b) against 0.
+var operatorMethods = map[TokenKind]string{
+	PLUS:    "add",
+	MINUS:   "subtract",
+	STAR:    "multiply",
+	SLASH:   "divide",
+	PERCENT: "remainder",
+	AMP:     "bitAnd",
+	PIPE:    "bitOr",
+	CARET:   "bitXor",
+	LTLT:    "shiftLeft",
+	GTGT:    "shiftRight",
+	EQEQ:    "equals",
+	BANGEQ:  "equals",
+	LT:      "compare",
+	LTEQ:    "compare",
+	GT:      "compare",
+	GTEQ:    "compare",
+}
+
+var unaryOperatorMethods = map[TokenKind]string{
+	MINUS: "negate",
+	TILDE: "bitNot",
+}
+
+func findOperatorMethod(name string, typeId TypeId, s *Scope) (*MethodName, error) {
+	if name == "" || !isUserDefined(typeId, s) {
+		return nil, nil
+	}
+	var expected *MethodType
+	for _, b := range builtinTraits {
+		for _, m := range b.Methods {
+			if m != name {
+				continue
+			}
+			expected = &MethodType{
+				This:    typeId,
+				Params:  make([]TypeId, 0, b.Arity),
+				Returns: typeId,
+			}
+			if b.Arity > 0 {
+				expected.Params = append(expected.Params, typeId)
+			}
+			if b.Returns != SELF_TYPE {
+				expected.Returns = s.findType(b.Returns).TypeId
+			}
+		}
+	}
+	if expected == nil {
+		return nil, nil
+	}
+	method, err := findMethodOfType(typeId, name, s)
+	if err != nil || method == nil || method.TypeId != s.File.TypeId(expected) {
+		return nil, err
+	}
+	return method, nil
+}
+
+func findMethodOfType(typeId TypeId, name string, s *Scope) (*MethodName, error) {
+	if structType, isStruct := (*s.File.Types)[typeId].(*StructType); isStruct && structType.Instance != nil {
+		return findInstanceMethod(structType.Instance, name)
+	}
+	return s.findMethod(s.TypeToString(typeId), name, make(map[string]struct{})), nil
+}
+
+func isUserDefined(typeId TypeId, s *Scope) bool {
+	switch (*s.File.Types)[typeId].(type) {
+	case *StructType, *EnumType:
+		return true
+	}
+	return false
+}
+
 func traitIsImplemented(trait string, typeId TypeId, s *Scope) bool {
 	switch trait {
 	case NEGATE_TRAIT, ADD_TRAIT, SUBTRACT_TRAIT, MULTIPLY_TRAIT, DIVIDE_TRAIT, ORDERING_TRAIT:
-		return isArithmetic(typeId)
+		return isArithmetic(typeId) || (trait == ORDERING_TRAIT && typeId == CHAR_TYPE_ID)
+	case REMAINDER_TRAIT, BITAND_TRAIT, BITOR_TRAIT, BITXOR_TRAIT, BITNOT_TRAIT, SHIFT_TRAIT:
+		return isInteger(typeId)
 	case EQUALS_TRAIT:
-		return isScalar(typeId, s)
+		return isScalar(typeId, s) || (isEnum(typeId, s) && !isTaggedUnion(typeId, s))
 	}
 	return false
 }
@@ -991,6 +3878,20 @@ func isArithmetic(typeId TypeId) bool {
 		UINT64_TYPE_ID || typeId == FLOAT32_TYPE_ID || typeId == FLOAT64_TYPE_ID
 }
 
+func isInteger(typeId TypeId) bool {
+	return isArithmetic(typeId) && typeId != FLOAT32_TYPE_ID && typeId != FLOAT64_TYPE_ID
+}
+
+func isEnum(typeId TypeId, s *Scope) bool {
+	_, isEnum := (*s.File.Types)[typeId].(*EnumType)
+	return isEnum
+}
+
+func isTaggedUnion(typeId TypeId, s *Scope) bool {
+	enumType, isEnum := (*s.File.Types)[typeId].(*EnumType)
+	return isEnum && len(enumType.Payloads) > 0
+}
+
 func isScalar(typeId TypeId, s *Scope) bool {
 	if _, isPointee := (*s.File.Types)[typeId].(*PointerType); isPointee {
 		return true
@@ -1003,9 +3904,68 @@ const ADD_TRAIT = "Add"
 const SUBTRACT_TRAIT = "Subtract"
 const MULTIPLY_TRAIT = "Multiply"
 const DIVIDE_TRAIT = "Divide"
+const REMAINDER_TRAIT = "Remainder"
+const BITAND_TRAIT = "BitAnd"
+const BITOR_TRAIT = "BitOr"
+const BITXOR_TRAIT = "BitXor"
+const BITNOT_TRAIT = "BitNot"
+const SHIFT_TRAIT = "Shift"
 const EQUALS_TRAIT = "Equals"
 const ORDERING_TRAIT = "Ordering"
 
+const SELF_TYPE = "Self"
+
+const RESULT_OK = "Ok"
+const RESULT_ERR = "Err"
+
+var builtinTraits = []struct {
+	Name    string
+	Methods []string
+	Arity   int
+	Returns string
+}{
+	{NEGATE_TRAIT, []string{"negate"}, 0, SELF_TYPE},
+	{ADD_TRAIT, []string{"add"}, 1, SELF_TYPE},
+	{SUBTRACT_TRAIT, []string{"subtract"}, 1, SELF_TYPE},
+	{MULTIPLY_TRAIT, []string{"multiply"}, 1, SELF_TYPE},
+	{DIVIDE_TRAIT, []string{"divide"}, 1, SELF_TYPE},
+	{REMAINDER_TRAIT, []string{"remainder"}, 1, SELF_TYPE},
+	{BITAND_TRAIT, []string{"bitAnd"}, 1, SELF_TYPE},
+	{BITOR_TRAIT, []string{"bitOr"}, 1, SELF_TYPE},
+	{BITXOR_TRAIT, []string{"bitXor"}, 1, SELF_TYPE},
+	{BITNOT_TRAIT, []string{"bitNot"}, 0, SELF_TYPE},
+	{SHIFT_TRAIT, []string{"shiftLeft", "shiftRight"}, 1, SELF_TYPE},
+	{EQUALS_TRAIT, []string{"equals"}, 1, "bool"},
+	{ORDERING_TRAIT, []string{"compare"}, 1, "int32"},
+}
+
+func defineBuiltinTraits(s *Scope) {
+	for _, b := range builtinTraits {
+		methods := make([]ParsedTraitMethod, 0, len(b.Methods))
+		for _, name := range b.Methods {
+			params := make([]ParsedFunParam, 0, b.Arity)
+			if b.Arity > 0 {
+				params = append(params, ParsedFunParam{
+					Id:   Token{Kind: IDENTIFIER, Content: "other"},
+					Type: &ParsedIdType{Token: Token{Kind: IDENTIFIER, Content: SELF_TYPE}},
+				})
+			}
+			methods = append(methods, ParsedTraitMethod{
+				Id:         Token{Kind: IDENTIFIER, Content: name},
+				Params:     params,
+				ReturnType: &ParsedIdType{Token: Token{Kind: IDENTIFIER, Content: b.Returns}},
+			})
+		}
+		name := &Token{Kind: IDENTIFIER, Content: b.Name}
+		s.DefineTrait(name, &Trait{
+			Name:    name,
+			Methods: methods,
+			Scope:   s,
+			Impls:   make(map[TypeId]*Token),
+		})
+	}
+}
+
 type ControlFlow interface {
 	controlFlow()
 	typeId() TypeId
@@ -1068,6 +4028,9 @@ func checkType(t ParsedType, s *Scope) (TypeId, error) {
 		default:
 			typ := s.findType(string(t.Content))
 			if typ == nil {
+				if s.findGeneric(t.Content) != nil {
+					return NOT_FOUND, NewError(t.pos(), "can't use generic %s without type arguments", t.Content)
+				}
 				return NOT_FOUND, NewError(t.pos(), "undeclared: %s", t.Content)
 			}
 			return typ.TypeId, nil
@@ -1080,6 +4043,33 @@ func checkType(t ParsedType, s *Scope) (TypeId, error) {
 		return s.File.TypeId(&PointerType{
 			Type: to,
 		}), nil
+	case *ParsedOptionalType:
+		elem, err := checkType(t.Elem, s)
+		if err != nil {
+			return NOT_FOUND, err
+		}
+		if _, isOptional := (*s.File.Types)[elem].(*OptionalType); isOptional {
+			return NOT_FOUND, NewError(t.pos(), "optional type can't be optional: %s", s.TypeToString(elem))
+		}
+		return s.File.TypeId(&OptionalType{
+			Elem: elem,
+		}), nil
+	case *ParsedResultType:
+		value, err := checkType(t.Value, s)
+		if err != nil {
+			return NOT_FOUND, err
+		}
+		errorType, err := checkType(t.Error, s)
+		if err != nil {
+			return NOT_FOUND, err
+		}
+		if errorType == UNIT_TYPE_ID {
+			return NOT_FOUND, NewError(t.Error.pos(), "error type can't be %s", s.TypeToString(UNIT_TYPE_ID))
+		}
+		return s.File.TypeId(&ResultType{
+			Value: value,
+			Error: errorType,
+		}), nil
 	case *ParsedModuleAccessType:
 		importId := s.findImport(string(t.Module.Content))
 		if importId == IMPORT_NOT_FOUND {
@@ -1091,10 +4081,94 @@ func checkType(t ParsedType, s *Scope) (TypeId, error) {
 			return NOT_FOUND, err
 		}
 		return member, nil
+	case *ParsedArrayType:
+		length, err := checkArrayLen(t.Len, s)
+		if err != nil {
+			return NOT_FOUND, err
+		}
+		elem, err := checkType(t.Elem, s)
+		if err != nil {
+			return NOT_FOUND, err
+		}
+		if elem == UNIT_TYPE_ID {
+			return NOT_FOUND, NewError(t.Elem.pos(), "can't declare an array of %s", s.TypeToString(UNIT_TYPE_ID))
+		}
+		return s.File.TypeId(&ArrayType{
+			Elem: elem,
+			Len:  length,
+		}), nil
+	case *ParsedSliceType:
+		elem, err := checkType(t.Elem, s)
+		if err != nil {
+			return NOT_FOUND, err
+		}
+		if elem == UNIT_TYPE_ID {
+			return NOT_FOUND, NewError(t.Elem.pos(), "can't declare a slice of %s", s.TypeToString(UNIT_TYPE_ID))
+		}
+		return s.File.TypeId(&SliceType{
+			Elem: elem,
+		}), nil
+	case *ParsedGenericType:
+		g := findGeneric(t.Type, s)
+		if g == nil || g.Struct == nil {
+			return NOT_FOUND, NewError(t.pos(), "expected a generic type")
+		}
+		typeArgs, err := checkTypeArgs(g, t.Args, t.pos(), s)
+		if err != nil {
+			return NOT_FOUND, err
+		}
+		return instantiateStruct(g, typeArgs)
+	case *ParsedFunType:
+		params := make([]TypeId, 0, len(t.Params))
+		for _, param := range t.Params {
+			paramType, err := checkType(param, s)
+			if err != nil {
+				return NOT_FOUND, err
+			}
+			params = append(params, paramType)
+		}
+		returns := UNIT_TYPE_ID
+		if t.Returns != nil {
+			var err error
+			returns, err = checkType(t.Returns, s)
+			if err != nil {
+				return NOT_FOUND, err
+			}
+		}
+		return s.File.TypeId(&FunctionType{
+			Params:  params,
+			Returns: returns,
+		}), nil
 	}
 	panic("unreachable")
 }
 
+func checkArrayLen(p ParsedExpr, s *Scope) (int, error) {
+	if c := findConst(p, s); c != nil {
+		if err := c.evaluate(); err != nil {
+			return 0, err
+		}
+		value, isInteger := c.Value.(*big.Int)
+		if !isInteger || value.Sign() <= 0 || value.Cmp(big.NewInt(math.MaxInt32)) > 0 {
+			return 0, NewError(p.pos(), "invalid array length: %s", c.Name.Content)
+		}
+		return int(value.Int64()), nil
+	}
+	literal, isLiteral := p.(*ParsedLiteralExpr)
+	if !isLiteral || literal.Kind != INTEGER {
+		return 0, NewError(p.pos(), "an array length must be an integer literal or constant")
+	}
+	content, _, err := parseNumericLiteral(literal.Token)
+	if err != nil {
+		return 0, err
+	}
+	length, err := strconv.Atoi(content)
+	if err != nil || length <= 0 {
+		return 0, NewError(p.pos(), "invalid array length: %s", literal.Content)
+	}
+	return length, nil
+}
+
 type Name struct {
 	Token *Token
 	TypeId
@@ -1112,16 +4186,50 @@ type MethodName struct {
 	TypeId
 }
 
+type Const struct {
+	Name     *Token
+	Def      *ParsedConstDef
+	Scope    *Scope
+	Value    interface{}
+	Type     TypeId
+	Untyped  bool
+	Checking bool
+}
+
+type Trait struct {
+	Name    *Token
+	Methods []ParsedTraitMethod
+	Scope   *Scope
+	Impls   map[TypeId]*Token
+}
+
+func (t *Trait) hasMethod(name string) bool {
+	for _, m := range t.Methods {
+		if m.Id.Content == name {
+			return true
+		}
+	}
+	return false
+}
+
 type Scope struct {
-	Parent     *Scope
-	Children   []*Scope
-	File       *CheckedFile
-	Types      map[string]*TypeName
-	Funs       map[string]*Name
-	Methods    map[string]*MethodName
-	Vars       map[string]*Name
-	Imports    map[string]ImportId
-	MethodType TypeId
+	Parent      *Scope
+	Children    []*Scope
+	File        *CheckedFile
+	Types       map[string]*TypeName
+	Funs        map[string]*Name
+	Methods     map[string]*MethodName
+	Vars        map[string]*Name
+	Imports     map[string]ImportId
+	Generics    map[string]*GenericDef
+	Traits      map[string]*Trait
+	Consts      map[string]*Const
+	MethodType  TypeId
+	Closure     *CheckedFunExpr
+	Deferred    bool
+	IfExpr      bool
+	Unsafe      bool
+	ControlFlow ControlFlow
 }
 
 func NewScope(parent *Scope) *Scope {
@@ -1133,6 +4241,9 @@ func NewScope(parent *Scope) *Scope {
 		Methods:  make(map[string]*MethodName),
 		Vars:     make(map[string]*Name),
 		Imports:  make(map[string]ImportId),
+		Generics: make(map[string]*GenericDef),
+		Traits:   make(map[string]*Trait),
+		Consts:   make(map[string]*Const),
 	}
 	if parent != nil {
 		s.File = parent.File
@@ -1152,7 +4263,7 @@ func (s *Scope) Import(checkedImport *CheckedImport) error {
 }
 
 func (s *Scope) DefineType(token *Token, typ Type) error {
-	if s.findType(string(token.Content)) != nil {
+	if s.findType(string(token.Content)) != nil || s.findGeneric(token.Content) != nil {
 		return NewError(token.Pos, "type %s is already defined", token.Content)
 	}
 	s.Types[string(token.Content)] = &TypeName{
@@ -1174,7 +4285,7 @@ func (s *Scope) DefineTypealias(token *Token) error {
 }
 
 func (s *Scope) DefineFunction(token *Token, typ *FunctionType) error {
-	if s.findName(string(token.Content)) != nil {
+	if s.findName(string(token.Content)) != nil || s.findGeneric(token.Content) != nil {
 		return NewError(token.Pos, "%s is already declared", token.Content)
 	}
 	s.Funs[string(token.Content)] = &Name{
@@ -1208,6 +4319,30 @@ func (s *Scope) DefineVar(token *Token, typ TypeId, mutable bool) error {
 	return nil
 }
 
+func (s *Scope) DefineConst(token *Token, c *Const) error {
+	if s.findType(token.Content) != nil || s.findName(token.Content) != nil || s.findGeneric(token.Content) != nil {
+		return NewError(token.Pos, "%s is already declared", token.Content)
+	}
+	s.Consts[token.Content] = c
+	return nil
+}
+
+func (s *Scope) DefineGeneric(token *Token, g *GenericDef) error {
+	if s.findType(token.Content) != nil || s.findName(token.Content) != nil || s.findGeneric(token.Content) != nil {
+		return NewError(token.Pos, "%s is already declared", token.Content)
+	}
+	s.Generics[token.Content] = g
+	return nil
+}
+
+func (s *Scope) DefineTrait(token *Token, t *Trait) error {
+	if s.findTrait(token.Content) != nil {
+		return NewError(token.Pos, "trait %s is already declared", token.Content)
+	}
+	s.Traits[token.Content] = t
+	return nil
+}
+
 func (s *Scope) findMethod(typename string, name string, checkedFiles map[string]struct{}) *MethodName {
 	if _, checked := checkedFiles[s.File.Filename]; checked {
 		return nil
@@ -1227,6 +4362,64 @@ func (s *Scope) findMethod(typename string, name string, checkedFiles map[string
 	return nil
 }
 
+func (s *Scope) findControlFlow() ControlFlow {
+	for scope := s; scope != nil; scope = scope.Parent {
+		if scope.ControlFlow != nil {
+			return scope.ControlFlow
+		}
+	}
+	return nil
+}
+
+func (s *Scope) isDeferred() bool {
+	for scope := s; scope != nil && scope.Closure == nil; scope = scope.Parent {
+		if scope.Deferred {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Scope) isIfExpr() bool {
+	for scope := s; scope != nil && scope.Closure == nil; scope = scope.Parent {
+		if scope.IfExpr {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Scope) isUnsafe() bool {
+	for scope := s; scope != nil; scope = scope.Parent {
+		if scope.Unsafe {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Scope) captureVar(name string) {
+	closures := make([]*Scope, 0)
+	for scope := s; scope.Parent != nil; scope = scope.Parent {
+		if v, ok := scope.Vars[name]; ok {
+			for i := len(closures) - 1; i >= 0; i-- {
+				closures[i].Vars[name] = &Name{
+					Token:  v.Token,
+					TypeId: v.TypeId,
+				}
+				closures[i].Closure.Captures = append(closures[i].Closure.Captures, CheckedFunParam{
+					Name: v.Token,
+					Type: v.TypeId,
+				})
+			}
+			return
+		}
+		if scope.Closure != nil {
+			closures = append(closures, scope)
+		}
+	}
+}
+
 func (s *Scope) findName(name string) *Name {
 	if t := s.findFunction(name); t != nil {
 		return t
@@ -1234,6 +4427,9 @@ func (s *Scope) findName(name string) *Name {
 	if t := s.findVar(name); t != nil {
 		return t
 	}
+	if c := s.findConst(name); c != nil {
+		return &Name{Token: c.Name, TypeId: c.Type}
+	}
 	return nil
 }
 
@@ -1257,6 +4453,16 @@ func (s *Scope) findVar(name string) *Name {
 	return nil
 }
 
+func (s *Scope) findConst(name string) *Const {
+	if c, ok := s.Consts[name]; ok {
+		return c
+	}
+	if s.Parent != nil {
+		return s.Parent.findConst(name)
+	}
+	return nil
+}
+
 func (s *Scope) findImport(name string) ImportId {
 	if imp, ok := s.Imports[name]; ok {
 		return imp
@@ -1267,6 +4473,26 @@ func (s *Scope) findImport(name string) ImportId {
 	return IMPORT_NOT_FOUND
 }
 
+func (s *Scope) findGeneric(name string) *GenericDef {
+	if g, ok := s.Generics[name]; ok {
+		return g
+	}
+	if s.Parent != nil {
+		return s.Parent.findGeneric(name)
+	}
+	return nil
+}
+
+func (s *Scope) findTrait(name string) *Trait {
+	if t, ok := s.Traits[name]; ok {
+		return t
+	}
+	if s.Parent != nil {
+		return s.Parent.findTrait(name)
+	}
+	return nil
+}
+
 func (s *Scope) findType(name string) *TypeName {
 	if t, ok := s.Types[name]; ok {
 		return t
@@ -1279,12 +4505,38 @@ func (s *Scope) findType(name string) *TypeName {
 
 func (s *Scope) TypeToString(typeId TypeId) string {
 	switch t := (*s.File.Types)[typeId].(type) {
-	case *BuildinType, *StructType:
+	case *StructType:
+		if t.Instance != nil {
+			return fmt.Sprintf("%s[%s]", t.Instance.Generic.Name.Content, strings.Join(s.typesToStrings(t.Instance.TypeArgs), ", "))
+		}
+		return findIdTypeInFile(typeId, s.File, make(map[*CheckedFile]struct{}))
+	case *BuildinType, *EnumType:
 		res := findIdTypeInFile(typeId, s.File, make(map[*CheckedFile]struct{}))
 		return res
 	case *PointerType:
 		return "*" + s.TypeToString(t.Type)
+	case *OptionalType:
+		return "?" + s.TypeToString(t.Elem)
+	case *NullType:
+		return "null"
+	case *ResultType:
+		return fmt.Sprintf("%s!%s", s.TypeToString(t.Value), s.TypeToString(t.Error))
+	case *ResultVariantType:
+		if t.Error {
+			return fmt.Sprintf("%s(%s)", RESULT_ERR, s.TypeToString(t.Value))
+		}
+		if t.Value == UNIT_TYPE_ID {
+			return RESULT_OK + "()"
+		}
+		return fmt.Sprintf("%s(%s)", RESULT_OK, s.TypeToString(t.Value))
+	case *ArrayType:
+		return fmt.Sprintf("[%d]%s", t.Len, s.TypeToString(t.Elem))
+	case *SliceType:
+		return "[]" + s.TypeToString(t.Elem)
 	case *FunctionType:
+		if t.Extern {
+			return fmt.Sprintf("extern fun (%s) %s", strings.Join(s.typesToStrings(t.Params), ", "), s.TypeToString(t.Returns))
+		}
 		return fmt.Sprintf("fun (%s) %s", strings.Join(s.typesToStrings(t.Params), ", "), s.TypeToString(t.Returns))
 	case *MethodType:
 		return fmt.Sprintf("fun %s._(%s) %s", s.TypeToString(t.This), strings.Join(s.typesToStrings(t.Params), ", "), s.TypeToString(t.Returns))
@@ -1375,7 +4627,10 @@ type CheckedFile struct {
 	Methods     []*CheckedMethodDef
 	ExternFuns  []*CheckedExternFunDef
 	Structs     []*CheckedStructDef
+	Enums       []*CheckedEnumDef
 	Typealiases []*CheckedTypealiasDef
+	Closures    []*CheckedFunExpr
+	Globals     []*CheckedVarDef
 	Types       *[]Type
 	GlobalScope *Scope
 }
@@ -1410,6 +4665,7 @@ func NewCheckedFile(filename string, types *[]Type) *CheckedFile {
 	c.GlobalScope.DefineType(&Token{Content: "float64"}, &BuildinType{TypeId: FLOAT64_TYPE_ID})
 	c.GlobalScope.DefineType(&Token{Content: "char"}, &BuildinType{TypeId: CHAR_TYPE_ID})
 	c.GlobalScope.DefineType(&Token{Content: "bool"}, &BuildinType{TypeId: BOOL_TYPE_ID})
+	defineBuiltinTraits(c.GlobalScope)
 	constChar := c.TypeId(&PointerType{
 		Type: CHAR_TYPE_ID,
 	})
@@ -1471,12 +4727,30 @@ type CheckedStructField struct {
 	Type TypeId
 }
 
+type CheckedEnumDef struct {
+	Name     Token
+	Variants []CheckedEnumVariant
+}
+
+type CheckedEnumVariant struct {
+	Name    Token
+	Payload []TypeId
+	Value   int64
+}
+
 type CheckedExternFunDef struct {
 	Name       *Token
 	Params     []CheckedFunParam
 	ReturnType TypeId
 }
 
+type CheckedVarDef struct {
+	Mut    *Token
+	Name   *Token
+	Value  CheckedExpr
+	Static bool
+}
+
 type CheckedTypealiasDef struct {
 	Name *Token
 	Type TypeId
@@ -1505,9 +4779,18 @@ type CheckedReturn struct {
 }
 
 type CheckedIf struct {
+	Binding  *Token
+	Cond     CheckedExpr
+	Body     *CheckedBlock
+	ElseBody *CheckedBlock
+}
+
+type CheckedIfExpr struct {
+	Binding  *Token
 	Cond     CheckedExpr
 	Body     *CheckedBlock
 	ElseBody *CheckedBlock
+	Type     TypeId
 }
 
 type CheckedWhile struct {
@@ -1515,6 +4798,13 @@ type CheckedWhile struct {
 	Body *CheckedBlock
 }
 
+type CheckedFor struct {
+	Init CheckedStmt
+	Cond CheckedExpr
+	Post CheckedExpr
+	Body *CheckedBlock
+}
+
 type CheckedBreak struct {
 	Break Token
 }
@@ -1523,14 +4813,33 @@ type CheckedContinue struct {
 	Continue Token
 }
 
+type CheckedMatch struct {
+	Value    CheckedExpr
+	Arms     []CheckedMatchArm
+	ElseBody *CheckedBlock
+}
+
+type CheckedDefer struct {
+	Stmt CheckedStmt
+}
+
+type CheckedMatchArm struct {
+	Variant  Token
+	Bindings []*Token
+	Body     *CheckedBlock
+}
+
 func (c *CheckedVar) checkedStmt()      {}
 func (c *CheckedExprStmt) checkedStmt() {}
 func (c *CheckedBlock) checkedStmt()    {}
 func (c *CheckedReturn) checkedStmt()   {}
 func (c *CheckedIf) checkedStmt()       {}
 func (c *CheckedWhile) checkedStmt()    {}
+func (c *CheckedFor) checkedStmt()      {}
 func (c *CheckedBreak) checkedStmt()    {}
 func (c *CheckedContinue) checkedStmt() {}
+func (c *CheckedMatch) checkedStmt()    {}
+func (c *CheckedDefer) checkedStmt()    {}
 
 type CheckedExpr interface {
 	checkedExpr()
@@ -1553,6 +4862,8 @@ const (
 	CHECKED_NEGATE
 	CHECKED_ADDRESS
 	CHECKED_DEREF
+	CHECKED_NOT
+	CHECKED_BITNOT
 )
 
 type CheckedBinaryExpr struct {
@@ -1578,6 +4889,24 @@ const (
 	CHECKED_GREATERTHAN
 	CHECKED_GREATEROREQUAL
 	CHECKED_ASSIGN
+	CHECKED_AND
+	CHECKED_OR
+	CHECKED_REMAINDER
+	CHECKED_BITAND
+	CHECKED_BITOR
+	CHECKED_BITXOR
+	CHECKED_SHIFTLEFT
+	CHECKED_SHIFTRIGHT
+	CHECKED_ADD_ASSIGN
+	CHECKED_SUBTRACT_ASSIGN
+	CHECKED_MULTIPLY_ASSIGN
+	CHECKED_DIVIDE_ASSIGN
+	CHECKED_REMAINDER_ASSIGN
+	CHECKED_BITAND_ASSIGN
+	CHECKED_BITOR_ASSIGN
+	CHECKED_BITXOR_ASSIGN
+	CHECKED_SHIFTLEFT_ASSIGN
+	CHECKED_SHIFTRIGHT_ASSIGN
 )
 
 type CheckedGroupedExpr struct {
@@ -1598,11 +4927,52 @@ type CheckedIdExpr struct {
 }
 
 type CheckedCallExpr struct {
-	Callee CheckedExpr
+	Callee   CheckedExpr
+	Args     []CheckedExpr
+	Type     TypeId
+	Indirect bool
+}
+
+type CheckedOptionalExpr struct {
+	Value CheckedExpr
+	Type  TypeId
+}
+
+type CheckedResultExpr struct {
+	Value CheckedExpr
+	Error bool
+	Type  TypeId
+}
+
+type CheckedPropagateExpr struct {
+	Value   CheckedExpr
+	Returns TypeId
+	Type    TypeId
+}
+
+type CheckedOverloadedAssignExpr struct {
+	Left  CheckedExpr
+	Temp  *CheckedIdExpr
+	Value CheckedExpr
+	Type  TypeId
+}
+
+type CheckedInterpolatedStringExpr struct {
+	Format *CheckedLiteralExpr
 	Args   []CheckedExpr
 	Type   TypeId
 }
 
+type CheckedFunExpr struct {
+	Name       *Token
+	Params     []CheckedFunParam
+	ReturnType TypeId
+	Body       *CheckedBlock
+	Captures   []CheckedFunParam
+	Extern     bool
+	Type       TypeId
+}
+
 type CheckedStructInitExpr struct {
 	Type   TypeId
 	Fields []CheckedStructInitField
@@ -1637,17 +5007,59 @@ type CheckedMethodExpr struct {
 	Type   TypeId
 }
 
-func (c *CheckedUnaryExpr) checkedExpr()        {}
-func (c *CheckedBinaryExpr) checkedExpr()       {}
-func (c *CheckedGroupedExpr) checkedExpr()      {}
-func (c *CheckedLiteralExpr) checkedExpr()      {}
-func (c *CheckedIdExpr) checkedExpr()           {}
-func (c *CheckedCallExpr) checkedExpr()         {}
-func (c *CheckedStructInitExpr) checkedExpr()   {}
-func (c *CheckedMemberAccessExpr) checkedExpr() {}
-func (c *CheckedModuleAccessExpr) checkedExpr() {}
-func (c *CheckedAsExpr) checkedExpr()           {}
-func (c *CheckedMethodExpr) checkedExpr()       {}
+type CheckedArrayLiteralExpr struct {
+	Elems []CheckedExpr
+	Type  TypeId
+	Pos
+}
+
+type CheckedIndexExpr struct {
+	Object CheckedExpr
+	Index  CheckedExpr
+	Type   TypeId
+}
+
+type CheckedEnumVariantExpr struct {
+	Variant Token
+	Enum    TypeId
+	Type    TypeId
+}
+
+type CheckedSliceExpr struct {
+	Object CheckedExpr
+	Low    CheckedExpr
+	High   CheckedExpr
+	Type   TypeId
+}
+
+type CheckedLenExpr struct {
+	Object CheckedExpr
+	Type   TypeId
+}
+
+func (c *CheckedUnaryExpr) checkedExpr()              {}
+func (c *CheckedBinaryExpr) checkedExpr()             {}
+func (c *CheckedGroupedExpr) checkedExpr()            {}
+func (c *CheckedLiteralExpr) checkedExpr()            {}
+func (c *CheckedIdExpr) checkedExpr()                 {}
+func (c *CheckedCallExpr) checkedExpr()               {}
+func (c *CheckedFunExpr) checkedExpr()                {}
+func (c *CheckedOptionalExpr) checkedExpr()           {}
+func (c *CheckedResultExpr) checkedExpr()             {}
+func (c *CheckedPropagateExpr) checkedExpr()          {}
+func (c *CheckedStructInitExpr) checkedExpr()         {}
+func (c *CheckedMemberAccessExpr) checkedExpr()       {}
+func (c *CheckedModuleAccessExpr) checkedExpr()       {}
+func (c *CheckedAsExpr) checkedExpr()                 {}
+func (c *CheckedMethodExpr) checkedExpr()             {}
+func (c *CheckedArrayLiteralExpr) checkedExpr()       {}
+func (c *CheckedIfExpr) checkedExpr()                 {}
+func (c *CheckedIndexExpr) checkedExpr()              {}
+func (c *CheckedEnumVariantExpr) checkedExpr()        {}
+func (c *CheckedSliceExpr) checkedExpr()              {}
+func (c *CheckedLenExpr) checkedExpr()                {}
+func (c *CheckedOverloadedAssignExpr) checkedExpr()   {}
+func (c *CheckedInterpolatedStringExpr) checkedExpr() {}
 
 func (c *CheckedUnaryExpr) TypeId() TypeId {
 	return c.Type
@@ -1664,6 +5076,21 @@ func (c *CheckedLiteralExpr) TypeId() TypeId {
 func (c *CheckedIdExpr) TypeId() TypeId {
 	return c.Type
 }
+func (c *CheckedResultExpr) TypeId() TypeId {
+	return c.Type
+}
+func (c *CheckedPropagateExpr) TypeId() TypeId {
+	return c.Type
+}
+func (c *CheckedInterpolatedStringExpr) TypeId() TypeId {
+	return c.Type
+}
+func (c *CheckedOptionalExpr) TypeId() TypeId {
+	return c.Type
+}
+func (c *CheckedFunExpr) TypeId() TypeId {
+	return c.Type
+}
 func (c *CheckedCallExpr) TypeId() TypeId {
 	return c.Type
 }
@@ -1682,6 +5109,27 @@ func (c *CheckedAsExpr) TypeId() TypeId {
 func (c *CheckedMethodExpr) TypeId() TypeId {
 	return c.Type
 }
+func (c *CheckedArrayLiteralExpr) TypeId() TypeId {
+	return c.Type
+}
+func (c *CheckedIfExpr) TypeId() TypeId {
+	return c.Type
+}
+func (c *CheckedIndexExpr) TypeId() TypeId {
+	return c.Type
+}
+func (c *CheckedEnumVariantExpr) TypeId() TypeId {
+	return c.Type
+}
+func (c *CheckedSliceExpr) TypeId() TypeId {
+	return c.Type
+}
+func (c *CheckedLenExpr) TypeId() TypeId {
+	return c.Type
+}
+func (c *CheckedOverloadedAssignExpr) TypeId() TypeId {
+	return c.Type
+}
 
 type TypeId int
 
@@ -1718,9 +5166,60 @@ type PointerType struct {
 	Type TypeId
 }
 
+type OptionalType struct {
+	Elem TypeId
+}
+
+type NullType struct{}
+
+type ResultVariantType struct {
+	Value TypeId
+	Error bool
+}
+
+type ResultType struct {
+	Value TypeId
+	Error TypeId
+}
+
 type StructType struct {
 	Fields   map[string]TypeId
 	StructId int
+	Instance *GenericInstance
+}
+
+type GenericDef struct {
+	Name       *Token
+	TypeParams []Token
+	Struct     *ParsedStructDef
+	Fun        *ParsedFunDef
+	Methods    []*ParsedFunDef
+	Scope      *Scope
+	Instances  []*GenericInstance
+}
+
+type GenericInstance struct {
+	Generic  *GenericDef
+	TypeArgs []TypeId
+	Name     *Token
+	TypeId   TypeId
+}
+
+func (g *GenericDef) findInstance(typeArgs []TypeId) *GenericInstance {
+	for _, inst := range g.Instances {
+		if reflect.DeepEqual(inst.TypeArgs, typeArgs) {
+			return inst
+		}
+	}
+	return nil
+}
+
+func (g *GenericDef) instanceName(typeArgs []TypeId) string {
+	ids := make([]string, 0, len(typeArgs))
+	for _, arg := range typeArgs {
+		ids = append(ids, strconv.Itoa(int(arg)))
+	}
+	return g.Name.Content + "__" + strings.Join(ids, "_")
 }
 
 var structTypesCreated int = 0
@@ -1733,9 +5232,36 @@ func NewStructType() *StructType {
 	}
 }
 
+type EnumType struct {
+	Variants map[string]int64
+	Payloads map[string][]TypeId
+	EnumId   int
+}
+
+var enumTypesCreated int = 0
+
+func NewEnumType() *EnumType {
+	enumTypesCreated++
+	return &EnumType{
+		Variants: make(map[string]int64),
+		Payloads: make(map[string][]TypeId),
+		EnumId:   enumTypesCreated - 1,
+	}
+}
+
+type ArrayType struct {
+	Elem TypeId
+	Len  int
+}
+
+type SliceType struct {
+	Elem TypeId
+}
+
 type FunctionType struct {
 	Params  []TypeId
 	Returns TypeId
+	Extern  bool
 }
 
 type MethodType struct {
@@ -1744,8 +5270,15 @@ type MethodType struct {
 	Returns TypeId
 }
 
-func (b *BuildinType) typ()  {}
-func (p *PointerType) typ()  {}
-func (s *StructType) typ()   {}
-func (f *FunctionType) typ() {}
-func (m *MethodType) typ()   {}
+func (b *BuildinType) typ()       {}
+func (p *PointerType) typ()       {}
+func (o *OptionalType) typ()      {}
+func (n *NullType) typ()          {}
+func (r *ResultVariantType) typ() {}
+func (r *ResultType) typ()        {}
+func (s *StructType) typ()        {}
+func (e *EnumType) typ()          {}
+func (a *ArrayType) typ()         {}
+func (s *SliceType) typ()         {}
+func (f *FunctionType) typ()      {}
+func (m *MethodType) typ()        {}
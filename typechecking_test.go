@@ -7,6 +7,18 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+func idToken(name string) wall.Token {
+	return wall.Token{Kind: wall.IDENTIFIER, Content: name}
+}
+
+func idExpr(name string) *wall.ParsedIdExpr {
+	return &wall.ParsedIdExpr{Token: idToken(name)}
+}
+
+func integerLiteral(content string) *wall.ParsedLiteralExpr {
+	return &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: content}}
+}
+
 func TestCheckImports(t *testing.T) {
 	fileA := &wall.ParsedFile{
 		Defs: []wall.ParsedDef{
@@ -652,6 +664,76 @@ func TestCheckWhileStmt(t *testing.T) {
 	}
 }
 
+func TestCheckDeferStmt(t *testing.T) {
+	checkedFile := wall.NewCheckedCompilationUnit("")
+	got, err := wall.CheckStmt(&wall.ParsedWhile{
+		Condition: &wall.ParsedLiteralExpr{
+			Token: wall.Token{Kind: wall.TRUE},
+		},
+		Body: &wall.ParsedBlock{
+			Stmts: []wall.ParsedStmt{
+				&wall.ParsedDefer{
+					Stmt: &wall.ParsedBlock{},
+				},
+				&wall.ParsedBreak{},
+			},
+		},
+	}, checkedFile.GlobalScope, &wall.MayReturn{
+		Type: wall.UNIT_TYPE_ID,
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, &wall.CheckedWhile{
+			Cond: &wall.CheckedLiteralExpr{
+				Literal: wall.Token{Kind: wall.TRUE},
+				Type:    wall.BOOL_TYPE_ID,
+			},
+			Body: &wall.CheckedBlock{
+				Stmts: []wall.CheckedStmt{
+					&wall.CheckedDefer{
+						Stmt: &wall.CheckedBlock{
+							Stmts: []wall.CheckedStmt{},
+						},
+					},
+					&wall.CheckedBreak{},
+				},
+			},
+		}, got)
+	}
+	invalid := []wall.ParsedStmt{
+		&wall.ParsedDefer{
+			Stmt: &wall.ParsedReturn{},
+		},
+		&wall.ParsedDefer{
+			Stmt: &wall.ParsedBlock{
+				Stmts: []wall.ParsedStmt{
+					&wall.ParsedReturn{},
+				},
+			},
+		},
+		&wall.ParsedDefer{
+			Stmt: &wall.ParsedBreak{},
+		},
+		&wall.ParsedDefer{
+			Stmt: &wall.ParsedDefer{
+				Stmt: &wall.ParsedBlock{},
+			},
+		},
+	}
+	for _, stmt := range invalid {
+		_, err := wall.CheckStmt(&wall.ParsedWhile{
+			Condition: &wall.ParsedLiteralExpr{
+				Token: wall.Token{Kind: wall.TRUE},
+			},
+			Body: &wall.ParsedBlock{
+				Stmts: []wall.ParsedStmt{stmt},
+			},
+		}, checkedFile.GlobalScope, &wall.MayReturn{
+			Type: wall.UNIT_TYPE_ID,
+		})
+		assert.Error(t, err)
+	}
+}
+
 func TestCheckCallExpr(t *testing.T) {
 	file := &wall.ParsedFile{
 		Defs: []wall.ParsedDef{
@@ -772,13 +854,13 @@ func TestCheckStructInitExpr(t *testing.T) {
 									{
 										Name: wall.Token{Kind: wall.IDENTIFIER, Content: "x"},
 										Value: &wall.ParsedLiteralExpr{
-											Token: wall.Token{Kind: wall.INTEGER},
+											Token: wall.Token{Kind: wall.INTEGER, Content: "0"},
 										},
 									},
 									{
 										Name: wall.Token{Kind: wall.IDENTIFIER, Content: "y"},
 										Value: &wall.ParsedLiteralExpr{
-											Token: wall.Token{Kind: wall.FLOAT},
+											Token: wall.Token{Kind: wall.FLOAT, Content: "0.0"},
 										},
 									},
 								},
@@ -828,11 +910,11 @@ func TestCheckStructAccessExpr(t *testing.T) {
 								Fields: []wall.ParsedStructInitField{
 									{
 										Name:  wall.Token{Kind: wall.IDENTIFIER, Content: "x"},
-										Value: &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER}},
+										Value: &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "0"}},
 									},
 									{
 										Name:  wall.Token{Kind: wall.IDENTIFIER, Content: "y"},
-										Value: &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER}},
+										Value: &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "0"}},
 									},
 								},
 							},
@@ -1034,7 +1116,7 @@ func TestCheckAsExpr(t *testing.T) {
 	checkedFile := wall.NewCheckedCompilationUnit("")
 	got, err := wall.CheckExpr(&wall.ParsedAsExpr{
 		Value: &wall.ParsedLiteralExpr{
-			Token: wall.Token{Kind: wall.INTEGER},
+			Token: wall.Token{Kind: wall.INTEGER, Content: "0"},
 		},
 		As: wall.Token{Kind: wall.AS},
 		Type: &wall.ParsedIdType{
@@ -1044,7 +1126,7 @@ func TestCheckAsExpr(t *testing.T) {
 	if assert.NoError(t, err) {
 		assert.Equal(t, &wall.CheckedAsExpr{
 			Value: &wall.CheckedLiteralExpr{
-				Literal: wall.Token{Kind: wall.INTEGER},
+				Literal: wall.Token{Kind: wall.INTEGER, Content: "0"},
 				Type:    wall.INT32_TYPE_ID,
 			},
 			Type: wall.FLOAT64_TYPE_ID,
@@ -1152,3 +1234,1613 @@ func TestCheckMethodDefAndSelfExpr(t *testing.T) {
 	assert.NoError(t, wall.CheckTypeContents(file, checkedFile))
 	assert.NoError(t, wall.CheckBlocks(file, checkedFile))
 }
+
+func TestCheckArrayLiteralExpr(t *testing.T) {
+	checkedFile := wall.NewCheckedCompilationUnit("")
+	got, err := wall.CheckExpr(&wall.ParsedArrayLiteralExpr{
+		Elems: []wall.ParsedExpr{
+			&wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "1"}},
+			&wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "2"}},
+		},
+	}, checkedFile.GlobalScope)
+	if assert.NoError(t, err) {
+		assert.Equal(t, checkedFile.TypeId(&wall.ArrayType{
+			Elem: wall.INT32_TYPE_ID,
+			Len:  2,
+		}), got.TypeId())
+	}
+	_, err = wall.CheckExpr(&wall.ParsedArrayLiteralExpr{
+		Elems: []wall.ParsedExpr{
+			&wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "1"}},
+			&wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.TRUE}},
+		},
+	}, checkedFile.GlobalScope)
+	assert.Error(t, err)
+	_, err = wall.CheckExpr(&wall.ParsedArrayLiteralExpr{
+		Type: &wall.ParsedArrayType{
+			Len:  &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "1"}},
+			Elem: &wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "int32"}},
+		},
+		Elems: []wall.ParsedExpr{
+			&wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "1"}},
+			&wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "2"}},
+		},
+	}, checkedFile.GlobalScope)
+	assert.Error(t, err)
+	lengths := map[string]bool{"0x2": true, "0b10": true, "0_2": true, "2i64": true, "2_": false, "0x0": false}
+	for length, valid := range lengths {
+		_, err = wall.CheckExpr(&wall.ParsedArrayLiteralExpr{
+			Type: &wall.ParsedArrayType{
+				Len:  &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: length}},
+				Elem: &wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "int32"}},
+			},
+			Elems: []wall.ParsedExpr{
+				&wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "1"}},
+				&wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "2"}},
+			},
+		}, checkedFile.GlobalScope)
+		assert.Equal(t, valid, err == nil, length)
+	}
+}
+
+func TestCheckIndexExpr(t *testing.T) {
+	checkedFile := wall.NewCheckedCompilationUnit("")
+	arrayType := checkedFile.TypeId(&wall.ArrayType{
+		Elem: wall.INT32_TYPE_ID,
+		Len:  3,
+	})
+	checkedFile.GlobalScope.DefineVar(&wall.Token{Kind: wall.IDENTIFIER, Content: "a"}, arrayType, true)
+	checkedFile.GlobalScope.DefineVar(&wall.Token{Kind: wall.IDENTIFIER, Content: "b"}, arrayType, false)
+	index := func(name string, i string) *wall.ParsedIndexExpr {
+		return &wall.ParsedIndexExpr{
+			Object: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: name}},
+			Index:  &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: i}},
+		}
+	}
+	got, err := wall.CheckExpr(index("a", "2"), checkedFile.GlobalScope)
+	if assert.NoError(t, err) {
+		assert.Equal(t, wall.INT32_TYPE_ID, got.TypeId())
+	}
+	_, err = wall.CheckExpr(index("a", "3"), checkedFile.GlobalScope)
+	assert.Error(t, err)
+	_, err = wall.CheckExpr(&wall.ParsedBinaryExpr{
+		Left:  index("a", "0"),
+		Op:    wall.Token{Kind: wall.EQ},
+		Right: &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "1"}},
+	}, checkedFile.GlobalScope)
+	assert.NoError(t, err)
+	_, err = wall.CheckExpr(&wall.ParsedBinaryExpr{
+		Left:  index("b", "0"),
+		Op:    wall.Token{Kind: wall.EQ},
+		Right: &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "1"}},
+	}, checkedFile.GlobalScope)
+	assert.Error(t, err)
+}
+
+func TestCheckSliceExpr(t *testing.T) {
+	checkedFile := wall.NewCheckedCompilationUnit("")
+	arrayType := checkedFile.TypeId(&wall.ArrayType{
+		Elem: wall.INT32_TYPE_ID,
+		Len:  3,
+	})
+	sliceType := checkedFile.TypeId(&wall.SliceType{
+		Elem: wall.INT32_TYPE_ID,
+	})
+	checkedFile.GlobalScope.DefineVar(&wall.Token{Kind: wall.IDENTIFIER, Content: "a"}, arrayType, false)
+	checkedFile.GlobalScope.DefineVar(&wall.Token{Kind: wall.IDENTIFIER, Content: "s"}, sliceType, false)
+	checkedFile.GlobalScope.DefineVar(&wall.Token{Kind: wall.IDENTIFIER, Content: "p"}, checkedFile.TypeId(&wall.PointerType{Type: wall.INT32_TYPE_ID}), false)
+	one := &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "1"}}
+	for _, name := range []string{"a", "s"} {
+		got, err := wall.CheckExpr(&wall.ParsedSliceExpr{
+			Object: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: name}},
+			High:   one,
+		}, checkedFile.GlobalScope)
+		if assert.NoError(t, err) {
+			assert.Equal(t, sliceType, got.TypeId())
+		}
+	}
+	_, err := wall.CheckExpr(&wall.ParsedSliceExpr{
+		Object: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "p"}},
+		Low:    one,
+	}, checkedFile.GlobalScope)
+	assert.Error(t, err)
+	_, err = wall.CheckExpr(&wall.ParsedSliceExpr{
+		Object: &wall.ParsedArrayLiteralExpr{Elems: []wall.ParsedExpr{one}},
+	}, checkedFile.GlobalScope)
+	assert.Error(t, err)
+	got, err := wall.CheckExpr(&wall.ParsedObjectAccessExpr{
+		Object: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "s"}},
+		Member: wall.Token{Kind: wall.IDENTIFIER, Content: "len"},
+	}, checkedFile.GlobalScope)
+	if assert.NoError(t, err) {
+		assert.Equal(t, wall.UINT_TYPE_ID, got.TypeId())
+	}
+	_, err = wall.CheckExpr(&wall.ParsedBinaryExpr{
+		Left: &wall.ParsedIndexExpr{
+			Object: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "s"}},
+			Index:  one,
+		},
+		Op:    wall.Token{Kind: wall.EQ},
+		Right: one,
+	}, checkedFile.GlobalScope)
+	assert.NoError(t, err)
+}
+
+func TestCheckLogicalOps(t *testing.T) {
+	checkedFile := wall.NewCheckedCompilationUnit("")
+	for _, op := range []wall.TokenKind{wall.AMPAMP, wall.PIPEPIPE} {
+		got, err := wall.CheckExpr(&wall.ParsedBinaryExpr{
+			Left:  &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.TRUE}},
+			Op:    wall.Token{Kind: op},
+			Right: &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.FALSE}},
+		}, checkedFile.GlobalScope)
+		if assert.NoError(t, err) {
+			assert.Equal(t, wall.BOOL_TYPE_ID, got.TypeId())
+		}
+		_, err = wall.CheckExpr(&wall.ParsedBinaryExpr{
+			Left:  &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "1"}},
+			Op:    wall.Token{Kind: op},
+			Right: &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "0"}},
+		}, checkedFile.GlobalScope)
+		assert.Error(t, err)
+	}
+	got, err := wall.CheckExpr(&wall.ParsedUnaryExpr{
+		Operator: wall.Token{Kind: wall.BANG},
+		Operand:  &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.TRUE}},
+	}, checkedFile.GlobalScope)
+	if assert.NoError(t, err) {
+		assert.Equal(t, wall.BOOL_TYPE_ID, got.TypeId())
+	}
+	_, err = wall.CheckExpr(&wall.ParsedUnaryExpr{
+		Operator: wall.Token{Kind: wall.BANG},
+		Operand:  &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "1"}},
+	}, checkedFile.GlobalScope)
+	assert.Error(t, err)
+}
+
+func TestCheckBitwiseOps(t *testing.T) {
+	checkedFile := wall.NewCheckedCompilationUnit("")
+	checkedFile.GlobalScope.DefineVar(&wall.Token{Kind: wall.IDENTIFIER, Content: "a"}, wall.UINT8_TYPE_ID, false)
+	checkedFile.GlobalScope.DefineVar(&wall.Token{Kind: wall.IDENTIFIER, Content: "f"}, wall.FLOAT64_TYPE_ID, false)
+	a := &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "a"}}
+	f := &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "f"}}
+	one := &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "1"}}
+	for _, op := range []wall.TokenKind{wall.PERCENT, wall.AMP, wall.PIPE, wall.CARET} {
+		got, err := wall.CheckExpr(&wall.ParsedBinaryExpr{Left: a, Op: wall.Token{Kind: op}, Right: a}, checkedFile.GlobalScope)
+		if assert.NoError(t, err) {
+			assert.Equal(t, wall.UINT8_TYPE_ID, got.TypeId())
+		}
+		_, err = wall.CheckExpr(&wall.ParsedBinaryExpr{Left: f, Op: wall.Token{Kind: op}, Right: f}, checkedFile.GlobalScope)
+		assert.Error(t, err)
+	}
+	for _, op := range []wall.TokenKind{wall.LTLT, wall.GTGT} {
+		got, err := wall.CheckExpr(&wall.ParsedBinaryExpr{Left: a, Op: wall.Token{Kind: op}, Right: one}, checkedFile.GlobalScope)
+		if assert.NoError(t, err) {
+			assert.Equal(t, wall.UINT8_TYPE_ID, got.TypeId())
+		}
+		_, err = wall.CheckExpr(&wall.ParsedBinaryExpr{Left: a, Op: wall.Token{Kind: op}, Right: f}, checkedFile.GlobalScope)
+		assert.Error(t, err)
+	}
+	got, err := wall.CheckExpr(&wall.ParsedUnaryExpr{Operator: wall.Token{Kind: wall.TILDE}, Operand: a}, checkedFile.GlobalScope)
+	if assert.NoError(t, err) {
+		assert.Equal(t, wall.UINT8_TYPE_ID, got.TypeId())
+	}
+	_, err = wall.CheckExpr(&wall.ParsedUnaryExpr{Operator: wall.Token{Kind: wall.TILDE}, Operand: f}, checkedFile.GlobalScope)
+	assert.Error(t, err)
+}
+
+func TestCheckCompoundAssignExpr(t *testing.T) {
+	checkedFile := wall.NewCheckedCompilationUnit("")
+	checkedFile.GlobalScope.DefineVar(&wall.Token{Kind: wall.IDENTIFIER, Content: "a"}, wall.INT32_TYPE_ID, true)
+	checkedFile.GlobalScope.DefineVar(&wall.Token{Kind: wall.IDENTIFIER, Content: "b"}, wall.INT32_TYPE_ID, false)
+	checkedFile.GlobalScope.DefineVar(&wall.Token{Kind: wall.IDENTIFIER, Content: "f"}, wall.FLOAT64_TYPE_ID, true)
+	checkedFile.GlobalScope.DefineVar(&wall.Token{Kind: wall.IDENTIFIER, Content: "n"}, wall.UINT8_TYPE_ID, false)
+	got, err := wall.CheckExpr(&wall.ParsedBinaryExpr{Left: idExpr("a"), Op: wall.Token{Kind: wall.PLUSEQ}, Right: idExpr("b")}, checkedFile.GlobalScope)
+	if assert.NoError(t, err) {
+		assert.Equal(t, wall.INT32_TYPE_ID, got.TypeId())
+	}
+	_, err = wall.CheckExpr(&wall.ParsedBinaryExpr{Left: idExpr("a"), Op: wall.Token{Kind: wall.LTLTEQ}, Right: idExpr("n")}, checkedFile.GlobalScope)
+	assert.NoError(t, err)
+	_, err = wall.CheckExpr(&wall.ParsedBinaryExpr{Left: idExpr("b"), Op: wall.Token{Kind: wall.PLUSEQ}, Right: idExpr("a")}, checkedFile.GlobalScope)
+	assert.Error(t, err)
+	_, err = wall.CheckExpr(&wall.ParsedBinaryExpr{Left: idExpr("f"), Op: wall.Token{Kind: wall.PERCENTEQ}, Right: idExpr("f")}, checkedFile.GlobalScope)
+	assert.Error(t, err)
+	_, err = wall.CheckExpr(&wall.ParsedBinaryExpr{Left: idExpr("a"), Op: wall.Token{Kind: wall.MINUSEQ}, Right: idExpr("f")}, checkedFile.GlobalScope)
+	assert.Error(t, err)
+	_, err = wall.CheckExpr(&wall.ParsedBinaryExpr{
+		Left:  &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "1"}},
+		Op:    wall.Token{Kind: wall.STAREQ},
+		Right: idExpr("a"),
+	}, checkedFile.GlobalScope)
+	assert.Error(t, err)
+}
+
+func TestCheckEnumDef(t *testing.T) {
+	file := &wall.ParsedFile{
+		Defs: []wall.ParsedDef{
+			&wall.ParsedEnumDef{
+				Name: wall.Token{Kind: wall.IDENTIFIER, Content: "Color"},
+				Variants: []wall.ParsedEnumVariant{
+					{Name: wall.Token{Kind: wall.IDENTIFIER, Content: "Red"}},
+					{
+						Name:  wall.Token{Kind: wall.IDENTIFIER, Content: "Green"},
+						Value: &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "5"}},
+					},
+					{Name: wall.Token{Kind: wall.IDENTIFIER, Content: "Blue"}},
+				},
+			},
+		},
+	}
+	checkedFile := wall.NewCheckedCompilationUnit("")
+	assert.NoError(t, wall.CheckTypeSignatures(file, checkedFile))
+	assert.NoError(t, wall.CheckFunctionSignatures(file, checkedFile))
+	if assert.NoError(t, wall.CheckTypeContents(file, checkedFile)) {
+		values := []int64{}
+		for _, variant := range checkedFile.Enums[0].Variants {
+			values = append(values, variant.Value)
+		}
+		assert.Equal(t, []int64{0, 5, 6}, values)
+		assert.Len(t, checkedFile.Methods, 1)
+		assert.Equal(t, "name", checkedFile.Methods[0].Name.Content)
+	}
+	variant := func(name string) *wall.ParsedModuleAccessExpr {
+		return &wall.ParsedModuleAccessExpr{
+			Module: wall.Token{Kind: wall.IDENTIFIER, Content: "Color"},
+			Member: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: name}},
+		}
+	}
+	colorType := checkedFile.GlobalScope.Types["Color"].TypeId
+	got, err := wall.CheckExpr(variant("Blue"), checkedFile.GlobalScope)
+	if assert.NoError(t, err) {
+		assert.Equal(t, colorType, got.TypeId())
+	}
+	_, err = wall.CheckExpr(variant("Purple"), checkedFile.GlobalScope)
+	assert.Error(t, err)
+	got, err = wall.CheckExpr(&wall.ParsedBinaryExpr{Left: variant("Red"), Op: wall.Token{Kind: wall.EQEQ}, Right: variant("Blue")}, checkedFile.GlobalScope)
+	if assert.NoError(t, err) {
+		assert.Equal(t, wall.BOOL_TYPE_ID, got.TypeId())
+	}
+	_, err = wall.CheckExpr(&wall.ParsedBinaryExpr{Left: variant("Red"), Op: wall.Token{Kind: wall.PLUS}, Right: variant("Blue")}, checkedFile.GlobalScope)
+	assert.Error(t, err)
+	got, err = wall.CheckExpr(&wall.ParsedAsExpr{
+		Value: variant("Green"),
+		Type:  &wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "uint8"}},
+	}, checkedFile.GlobalScope)
+	if assert.NoError(t, err) {
+		assert.Equal(t, wall.UINT8_TYPE_ID, got.TypeId())
+	}
+	_, err = wall.CheckExpr(&wall.ParsedAsExpr{
+		Value: &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "1"}},
+		Type:  &wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "Color"}},
+	}, checkedFile.GlobalScope)
+	assert.Error(t, err)
+}
+
+func TestCheckEnumDefErr(t *testing.T) {
+	variants := [][]wall.ParsedEnumVariant{
+		{},
+		{
+			{Name: wall.Token{Kind: wall.IDENTIFIER, Content: "A"}},
+			{Name: wall.Token{Kind: wall.IDENTIFIER, Content: "A"}},
+		},
+		{
+			{Name: wall.Token{Kind: wall.IDENTIFIER, Content: "A"}, Value: &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "1"}}},
+			{Name: wall.Token{Kind: wall.IDENTIFIER, Content: "B"}, Value: &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "1"}}},
+		},
+		{
+			{Name: wall.Token{Kind: wall.IDENTIFIER, Content: "A"}, Value: &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.FLOAT, Content: "1.0"}}},
+		},
+	}
+	for _, v := range variants {
+		file := &wall.ParsedFile{
+			Defs: []wall.ParsedDef{
+				&wall.ParsedEnumDef{
+					Name:     wall.Token{Kind: wall.IDENTIFIER, Content: "E"},
+					Variants: v,
+				},
+			},
+		}
+		checkedFile := wall.NewCheckedCompilationUnit("")
+		assert.NoError(t, wall.CheckTypeSignatures(file, checkedFile))
+		assert.Error(t, wall.CheckTypeContents(file, checkedFile))
+	}
+}
+
+func TestCheckMatch(t *testing.T) {
+	file := &wall.ParsedFile{
+		Defs: []wall.ParsedDef{
+			&wall.ParsedEnumDef{
+				Name: wall.Token{Kind: wall.IDENTIFIER, Content: "Opt"},
+				Variants: []wall.ParsedEnumVariant{
+					{
+						Name:    wall.Token{Kind: wall.IDENTIFIER, Content: "Some"},
+						Payload: []wall.ParsedType{&wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "int32"}}},
+					},
+					{Name: wall.Token{Kind: wall.IDENTIFIER, Content: "None"}},
+				},
+			},
+		},
+	}
+	checkedFile := wall.NewCheckedCompilationUnit("")
+	assert.NoError(t, wall.CheckTypeSignatures(file, checkedFile))
+	assert.NoError(t, wall.CheckFunctionSignatures(file, checkedFile))
+	assert.NoError(t, wall.CheckTypeContents(file, checkedFile))
+	optType := checkedFile.GlobalScope.Types["Opt"].TypeId
+	checkedFile.GlobalScope.DefineVar(&wall.Token{Kind: wall.IDENTIFIER, Content: "o"}, optType, false)
+	variant := func(name string) *wall.ParsedModuleAccessExpr {
+		return &wall.ParsedModuleAccessExpr{
+			Module: wall.Token{Kind: wall.IDENTIFIER, Content: "Opt"},
+			Member: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: name}},
+		}
+	}
+	got, err := wall.CheckExpr(variant("None"), checkedFile.GlobalScope)
+	if assert.NoError(t, err) {
+		assert.Equal(t, optType, got.TypeId())
+	}
+	got, err = wall.CheckExpr(variant("Some"), checkedFile.GlobalScope)
+	if assert.NoError(t, err) {
+		assert.Equal(t, checkedFile.TypeId(&wall.FunctionType{Params: []wall.TypeId{wall.INT32_TYPE_ID}, Returns: optType}), got.TypeId())
+	}
+	_, err = wall.CheckExpr(&wall.ParsedBinaryExpr{Left: variant("None"), Op: wall.Token{Kind: wall.EQEQ}, Right: variant("None")}, checkedFile.GlobalScope)
+	assert.Error(t, err)
+	returnId := func(name string) *wall.ParsedBlock {
+		return &wall.ParsedBlock{Stmts: []wall.ParsedStmt{&wall.ParsedReturn{Arg: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: name}}}}}
+	}
+	some := func(bindings []wall.Token, body *wall.ParsedBlock) wall.ParsedMatchArm {
+		return wall.ParsedMatchArm{Variant: wall.Token{Kind: wall.IDENTIFIER, Content: "Some"}, Bindings: bindings, Body: body}
+	}
+	none := func(body *wall.ParsedBlock) wall.ParsedMatchArm {
+		return wall.ParsedMatchArm{Variant: wall.Token{Kind: wall.IDENTIFIER, Content: "None"}, Body: body}
+	}
+	zero := &wall.ParsedBlock{Stmts: []wall.ParsedStmt{&wall.ParsedReturn{Arg: &wall.ParsedAsExpr{Value: &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "0"}}, Type: &wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "int32"}}}}}}
+	v := []wall.Token{{Kind: wall.IDENTIFIER, Content: "v"}}
+	tests := []struct {
+		match *wall.ParsedMatch
+		valid bool
+	}{
+		{&wall.ParsedMatch{Value: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "o"}}, Arms: []wall.ParsedMatchArm{some(v, returnId("v")), none(returnId("v"))}}, false},
+		{&wall.ParsedMatch{Value: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "o"}}, Arms: []wall.ParsedMatchArm{some(v, returnId("v"))}, ElseBody: returnId("v")}, false},
+		{&wall.ParsedMatch{Value: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "o"}}, Arms: []wall.ParsedMatchArm{some(v, returnId("v")), none(zero)}}, true},
+		{&wall.ParsedMatch{Value: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "o"}}, Arms: []wall.ParsedMatchArm{none(zero)}, ElseBody: zero}, true},
+		{&wall.ParsedMatch{Value: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "o"}}, Arms: []wall.ParsedMatchArm{some(nil, zero), none(zero)}}, true},
+		{&wall.ParsedMatch{Value: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "o"}}, Arms: []wall.ParsedMatchArm{some(v, returnId("v"))}}, false},
+		{&wall.ParsedMatch{Value: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "o"}}, Arms: []wall.ParsedMatchArm{some(nil, zero), none(zero)}, ElseBody: zero}, false},
+		{&wall.ParsedMatch{Value: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "o"}}, Arms: []wall.ParsedMatchArm{some([]wall.Token{}, zero), none(zero)}}, false},
+		{&wall.ParsedMatch{Value: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "o"}}, Arms: []wall.ParsedMatchArm{none(zero), none(zero)}, ElseBody: zero}, false},
+		{&wall.ParsedMatch{Value: &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "1"}}, ElseBody: zero}, false},
+	}
+	for _, test := range tests {
+		_, err := wall.CheckStmt(test.match, checkedFile.GlobalScope, &wall.MustReturn{Type: wall.INT32_TYPE_ID})
+		if test.valid {
+			assert.NoError(t, err)
+		} else {
+			assert.Error(t, err)
+		}
+	}
+}
+
+func TestCheckGenerics(t *testing.T) {
+	typeT := &wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "T"}}
+	boxT := &wall.ParsedGenericType{
+		Type: &wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "Box"}},
+		Args: []wall.ParsedType{typeT},
+	}
+	file := &wall.ParsedFile{
+		Defs: []wall.ParsedDef{
+			&wall.ParsedStructDef{
+				Name:       wall.Token{Kind: wall.IDENTIFIER, Content: "Box"},
+				TypeParams: []wall.Token{{Kind: wall.IDENTIFIER, Content: "T"}},
+				Fields: []wall.ParsedStructField{
+					{Name: wall.Token{Kind: wall.IDENTIFIER, Content: "value"}, Type: typeT},
+				},
+			},
+			&wall.ParsedFunDef{
+				Typename:   &wall.Token{Kind: wall.IDENTIFIER, Content: "Box"},
+				TypeParams: []wall.Token{{Kind: wall.IDENTIFIER, Content: "T"}},
+				Id:         wall.Token{Kind: wall.IDENTIFIER, Content: "get"},
+				Params:     []wall.ParsedFunParam{},
+				ReturnType: typeT,
+				Body: &wall.ParsedBlock{Stmts: []wall.ParsedStmt{&wall.ParsedReturn{
+					Arg: &wall.ParsedObjectAccessExpr{Member: wall.Token{Kind: wall.IDENTIFIER, Content: "value"}},
+				}}},
+			},
+			&wall.ParsedFunDef{
+				Id:         wall.Token{Kind: wall.IDENTIFIER, Content: "wrap"},
+				TypeParams: []wall.Token{{Kind: wall.IDENTIFIER, Content: "T"}},
+				Params:     []wall.ParsedFunParam{{Id: wall.Token{Kind: wall.IDENTIFIER, Content: "x"}, Type: typeT}},
+				ReturnType: boxT,
+				Body: &wall.ParsedBlock{Stmts: []wall.ParsedStmt{&wall.ParsedReturn{
+					Arg: &wall.ParsedStructInitExpr{
+						Name: &wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "Box"}},
+						Fields: []wall.ParsedStructInitField{
+							{Name: wall.Token{Kind: wall.IDENTIFIER, Content: "value"}, Value: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "x"}}},
+						},
+					},
+				}}},
+			},
+		},
+	}
+	checkedFile := wall.NewCheckedCompilationUnit("")
+	assert.NoError(t, wall.CheckTypeSignatures(file, checkedFile))
+	assert.NoError(t, wall.CheckFunctionSignatures(file, checkedFile))
+	assert.NoError(t, wall.CheckTypeContents(file, checkedFile))
+	assert.NoError(t, wall.CheckBlocks(file, checkedFile))
+	assert.Len(t, checkedFile.Funs, 0)
+	checkedFile.GlobalScope.DefineVar(&wall.Token{Kind: wall.IDENTIFIER, Content: "a"}, wall.INT32_TYPE_ID, false)
+	a := &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "a"}}
+	wrap := &wall.ParsedCallExpr{
+		Callee: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "wrap"}},
+		Args:   []wall.ParsedExpr{a},
+	}
+	got, err := wall.CheckExpr(wrap, checkedFile.GlobalScope)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Box[int32]", checkedFile.GlobalScope.TypeToString(got.TypeId()))
+	}
+	boxInt32 := got.TypeId()
+	got, err = wall.CheckExpr(wrap, checkedFile.GlobalScope)
+	if assert.NoError(t, err) {
+		assert.Equal(t, boxInt32, got.TypeId())
+	}
+	assert.Len(t, checkedFile.Funs, 1)
+	got, err = wall.CheckExpr(&wall.ParsedStructInitExpr{
+		Name: &wall.ParsedGenericType{
+			Type: &wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "Box"}},
+			Args: []wall.ParsedType{&wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "int32"}}},
+		},
+		Fields: []wall.ParsedStructInitField{{Name: wall.Token{Kind: wall.IDENTIFIER, Content: "value"}, Value: a}},
+	}, checkedFile.GlobalScope)
+	if assert.NoError(t, err) {
+		assert.Equal(t, boxInt32, got.TypeId())
+	}
+	got, err = wall.CheckExpr(&wall.ParsedCallExpr{
+		Callee: &wall.ParsedObjectAccessExpr{Object: wrap, Member: wall.Token{Kind: wall.IDENTIFIER, Content: "get"}},
+		Args:   []wall.ParsedExpr{},
+	}, checkedFile.GlobalScope)
+	if assert.NoError(t, err) {
+		assert.Equal(t, wall.INT32_TYPE_ID, got.TypeId())
+	}
+	assert.Len(t, checkedFile.Methods, 1)
+	funs := checkedFile.TypeId(&wall.ArrayType{Elem: checkedFile.TypeId(&wall.FunctionType{Params: []wall.TypeId{}, Returns: wall.INT32_TYPE_ID}), Len: 2})
+	checkedFile.GlobalScope.DefineVar(&wall.Token{Kind: wall.IDENTIFIER, Content: "fs"}, funs, false)
+	checkedFile.GlobalScope.DefineVar(&wall.Token{Kind: wall.IDENTIFIER, Content: "p"}, checkedFile.TypeId(&wall.PointerType{Type: wall.UINT_TYPE_ID}), false)
+	checkedFile.GlobalScope.DefineVar(&wall.Token{Kind: wall.IDENTIFIER, Content: "q"}, checkedFile.TypeId(&wall.PointerType{Type: wall.BOOL_TYPE_ID}), false)
+	indexCall := func(pointer string) *wall.ParsedCallExpr {
+		deref := &wall.ParsedUnaryExpr{Operator: wall.Token{Kind: wall.STAR}, Operand: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: pointer}}}
+		return &wall.ParsedCallExpr{
+			Callee: &wall.ParsedTypeArgsExpr{
+				Object: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "fs"}},
+				Args:   []wall.ParsedType{&wall.ParsedPointerType{To: &wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: pointer}}}},
+				Index:  &wall.ParsedIndexExpr{Object: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "fs"}}, Index: deref},
+			},
+			Args: []wall.ParsedExpr{},
+		}
+	}
+	got, err = wall.CheckExpr(indexCall("p"), checkedFile.GlobalScope)
+	if assert.NoError(t, err) {
+		assert.Equal(t, wall.INT32_TYPE_ID, got.TypeId())
+	}
+	_, err = wall.CheckExpr(indexCall("q"), checkedFile.GlobalScope)
+	if assert.Error(t, err) {
+		assert.NotContains(t, err.Error(), "generic")
+	}
+	invalid := []wall.ParsedExpr{
+		&wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "wrap"}},
+		&wall.ParsedCallExpr{
+			Callee: &wall.ParsedTypeArgsExpr{
+				Object: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "wrap"}},
+				Args:   []wall.ParsedType{&wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "float64"}}},
+			},
+			Args: []wall.ParsedExpr{a},
+		},
+		&wall.ParsedCallExpr{
+			Callee: &wall.ParsedTypeArgsExpr{
+				Object: &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "wrap"}},
+				Args:   []wall.ParsedType{typeT, typeT},
+			},
+			Args: []wall.ParsedExpr{a},
+		},
+		&wall.ParsedStructInitExpr{
+			Name:   &wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "Box"}},
+			Fields: []wall.ParsedStructInitField{},
+		},
+	}
+	for _, expr := range invalid {
+		_, err := wall.CheckExpr(expr, checkedFile.GlobalScope)
+		assert.Error(t, err)
+	}
+}
+
+func TestCheckTraits(t *testing.T) {
+	idType := func(name string) *wall.ParsedIdType {
+		return &wall.ParsedIdType{Token: idToken(name)}
+	}
+	method := func(name string, param string, returns string) *wall.ParsedFunDef {
+		params := []wall.ParsedFunParam{}
+		if param != "" {
+			params = append(params, wall.ParsedFunParam{Id: idToken("other"), Type: idType(param)})
+		}
+		return &wall.ParsedFunDef{
+			Id:         idToken(name),
+			Params:     params,
+			ReturnType: idType(returns),
+			Body: &wall.ParsedBlock{Stmts: []wall.ParsedStmt{&wall.ParsedReturn{
+				Arg: &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.TRUE}},
+			}}},
+		}
+	}
+	tests := []struct {
+		trait   string
+		methods []*wall.ParsedFunDef
+		valid   bool
+	}{
+		{"Similar", []*wall.ParsedFunDef{method("similar", "Point", "bool")}, true},
+		{"Equals", []*wall.ParsedFunDef{method("equals", "Point", "bool")}, true},
+		{"Similar", []*wall.ParsedFunDef{}, false},
+		{"Similar", []*wall.ParsedFunDef{method("similar", "int32", "bool")}, false},
+		{"Similar", []*wall.ParsedFunDef{method("similar", "Point", "bool"), method("other", "", "bool")}, false},
+		{"Unknown", []*wall.ParsedFunDef{}, false},
+	}
+	for _, test := range tests {
+		impl := &wall.ParsedImplDef{
+			Trait:    idType(test.trait),
+			Typename: idToken("Point"),
+			Methods:  test.methods,
+		}
+		for _, m := range impl.Methods {
+			m.Typename = &impl.Typename
+		}
+		file := &wall.ParsedFile{
+			Defs: []wall.ParsedDef{
+				&wall.ParsedStructDef{
+					Name:   idToken("Point"),
+					Fields: []wall.ParsedStructField{{Name: idToken("x"), Type: idType("int32")}},
+				},
+				&wall.ParsedTraitDef{
+					Name: idToken("Similar"),
+					Methods: []wall.ParsedTraitMethod{
+						{
+							Id:         idToken("similar"),
+							Params:     []wall.ParsedFunParam{{Id: idToken("other"), Type: idType("Self")}},
+							ReturnType: idType("bool"),
+						},
+					},
+				},
+				impl,
+			},
+		}
+		checkedFile := wall.NewCheckedCompilationUnit("")
+		assert.NoError(t, wall.CheckTypeSignatures(file, checkedFile))
+		assert.NoError(t, wall.CheckFunctionSignatures(file, checkedFile))
+		err := wall.CheckTypeContents(file, checkedFile)
+		if !test.valid {
+			assert.Error(t, err)
+			continue
+		}
+		if assert.NoError(t, err) {
+			assert.NoError(t, wall.CheckBlocks(file, checkedFile))
+		}
+		pointType := checkedFile.GlobalScope.Types["Point"].TypeId
+		checkedFile.GlobalScope.DefineVar(&wall.Token{Kind: wall.IDENTIFIER, Content: "p"}, pointType, false)
+		p := idExpr("p")
+		got, err := wall.CheckExpr(&wall.ParsedBinaryExpr{Left: p, Op: wall.Token{Kind: wall.EQEQ}, Right: p}, checkedFile.GlobalScope)
+		if test.trait == "Equals" {
+			if assert.NoError(t, err) {
+				assert.IsType(t, &wall.CheckedCallExpr{}, got)
+			}
+		} else {
+			assert.Error(t, err)
+		}
+	}
+	file := &wall.ParsedFile{
+		Defs: []wall.ParsedDef{
+			&wall.ParsedTraitDef{Name: idToken("Add")},
+		},
+	}
+	assert.Error(t, wall.CheckTypeSignatures(file, wall.NewCheckedCompilationUnit("")))
+}
+
+func TestCheckOverloadedOperators(t *testing.T) {
+	idType := func(name string) *wall.ParsedIdType {
+		return &wall.ParsedIdType{Token: idToken(name)}
+	}
+	typename := idToken("V")
+	method := func(name string, arity int, returns string, value wall.ParsedExpr) *wall.ParsedFunDef {
+		params := []wall.ParsedFunParam{}
+		if arity > 0 {
+			params = append(params, wall.ParsedFunParam{Id: idToken("other"), Type: idType("V")})
+		}
+		return &wall.ParsedFunDef{
+			Typename:   &typename,
+			Id:         idToken(name),
+			Params:     params,
+			ReturnType: idType(returns),
+			Body:       &wall.ParsedBlock{Stmts: []wall.ParsedStmt{&wall.ParsedReturn{Arg: value}}},
+		}
+	}
+	other := idExpr("other")
+	this := &wall.ParsedStructInitExpr{
+		Name:   idType("V"),
+		Fields: []wall.ParsedStructInitField{{Name: idToken("x"), Value: &wall.ParsedObjectAccessExpr{Member: idToken("x")}}},
+	}
+	file := &wall.ParsedFile{
+		Defs: []wall.ParsedDef{
+			&wall.ParsedStructDef{
+				Name:   idToken("V"),
+				Fields: []wall.ParsedStructField{{Name: idToken("x"), Type: idType("int32")}},
+			},
+			method("add", 1, "V", other),
+			method("negate", 0, "V", this),
+			method("equals", 1, "bool", &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.TRUE}}),
+			method("compare", 1, "int32", &wall.ParsedObjectAccessExpr{Member: idToken("x")}),
+			method("multiply", 1, "bool", &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.TRUE}}),
+		},
+	}
+	checkedFile := wall.NewCheckedCompilationUnit("")
+	assert.NoError(t, wall.CheckTypeSignatures(file, checkedFile))
+	assert.NoError(t, wall.CheckFunctionSignatures(file, checkedFile))
+	assert.NoError(t, wall.CheckTypeContents(file, checkedFile))
+	assert.NoError(t, wall.CheckBlocks(file, checkedFile))
+	vType := checkedFile.GlobalScope.Types["V"].TypeId
+	checkedFile.GlobalScope.DefineVar(&wall.Token{Kind: wall.IDENTIFIER, Content: "a"}, vType, false)
+	a := idExpr("a")
+	binary := func(op wall.TokenKind) *wall.ParsedBinaryExpr {
+		return &wall.ParsedBinaryExpr{Left: a, Op: wall.Token{Kind: op}, Right: a}
+	}
+	got, err := wall.CheckExpr(binary(wall.PLUS), checkedFile.GlobalScope)
+	if assert.NoError(t, err) {
+		assert.IsType(t, &wall.CheckedCallExpr{}, got)
+		assert.Equal(t, vType, got.TypeId())
+	}
+	got, err = wall.CheckExpr(binary(wall.EQEQ), checkedFile.GlobalScope)
+	if assert.NoError(t, err) {
+		assert.IsType(t, &wall.CheckedCallExpr{}, got)
+		assert.Equal(t, wall.BOOL_TYPE_ID, got.TypeId())
+	}
+	got, err = wall.CheckExpr(binary(wall.BANGEQ), checkedFile.GlobalScope)
+	if assert.NoError(t, err) {
+		assert.IsType(t, &wall.CheckedUnaryExpr{}, got)
+	}
+	got, err = wall.CheckExpr(binary(wall.LTEQ), checkedFile.GlobalScope)
+	if assert.NoError(t, err) {
+		if assert.IsType(t, &wall.CheckedBinaryExpr{}, got) {
+			assert.IsType(t, &wall.CheckedCallExpr{}, got.(*wall.CheckedBinaryExpr).Left)
+			assert.Equal(t, wall.CHECKED_LESSOREQUAL, got.(*wall.CheckedBinaryExpr).Op)
+		}
+	}
+	got, err = wall.CheckExpr(&wall.ParsedUnaryExpr{Operator: wall.Token{Kind: wall.MINUS}, Operand: a}, checkedFile.GlobalScope)
+	if assert.NoError(t, err) {
+		assert.IsType(t, &wall.CheckedCallExpr{}, got)
+		assert.Equal(t, vType, got.TypeId())
+	}
+	for _, op := range []wall.TokenKind{wall.STAR, wall.MINUS, wall.PLUSEQ} {
+		_, err = wall.CheckExpr(binary(op), checkedFile.GlobalScope)
+		assert.Error(t, err)
+	}
+	checkedFile.GlobalScope.DefineVar(&wall.Token{Kind: wall.IDENTIFIER, Content: "m"}, vType, true)
+	got, err = wall.CheckExpr(&wall.ParsedBinaryExpr{Left: idExpr("m"), Op: wall.Token{Kind: wall.PLUSEQ}, Right: a}, checkedFile.GlobalScope)
+	if assert.NoError(t, err) {
+		if assert.IsType(t, &wall.CheckedOverloadedAssignExpr{}, got) {
+			assign := got.(*wall.CheckedOverloadedAssignExpr)
+			assert.Equal(t, &wall.CheckedIdExpr{Id: &wall.Token{Kind: wall.IDENTIFIER, Content: "m"}, Type: vType}, assign.Left)
+			assert.Equal(t, vType, assign.Value.TypeId())
+		}
+	}
+}
+
+func TestCheckFunctionValues(t *testing.T) {
+	int32Type := &wall.ParsedIdType{Token: idToken("int32")}
+	callbackType := &wall.ParsedFunType{Params: []wall.ParsedType{int32Type}, Returns: &wall.ParsedIdType{Token: idToken("bool")}}
+	typename := idToken("Button")
+	file := &wall.ParsedFile{
+		Defs: []wall.ParsedDef{
+			&wall.ParsedStructDef{
+				Name:   idToken("Button"),
+				Fields: []wall.ParsedStructField{{Name: idToken("onClick"), Type: callbackType}},
+			},
+			&wall.ParsedFunDef{
+				Typename: &typename,
+				Id:       idToken("press"),
+				Params:   []wall.ParsedFunParam{},
+				Body:     &wall.ParsedBlock{Stmts: []wall.ParsedStmt{}},
+			},
+			&wall.ParsedFunDef{
+				Id:         idToken("isZero"),
+				Params:     []wall.ParsedFunParam{{Id: idToken("x"), Type: int32Type}},
+				ReturnType: &wall.ParsedIdType{Token: idToken("bool")},
+				Body: &wall.ParsedBlock{Stmts: []wall.ParsedStmt{&wall.ParsedReturn{
+					Arg: &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.TRUE}},
+				}}},
+			},
+			&wall.ParsedExternFunDef{
+				Name:   idToken("find"),
+				Params: []wall.ParsedFunParam{{Id: idToken("pred"), Type: callbackType}},
+			},
+		},
+	}
+	checkedFile := wall.NewCheckedCompilationUnit("")
+	assert.NoError(t, wall.CheckTypeSignatures(file, checkedFile))
+	assert.NoError(t, wall.CheckFunctionSignatures(file, checkedFile))
+	assert.NoError(t, wall.CheckTypeContents(file, checkedFile))
+	assert.NoError(t, wall.CheckBlocks(file, checkedFile))
+	callback := checkedFile.TypeId(&wall.FunctionType{Params: []wall.TypeId{wall.INT32_TYPE_ID}, Returns: wall.BOOL_TYPE_ID})
+	got, err := wall.CheckExpr(idExpr("isZero"), checkedFile.GlobalScope)
+	if assert.NoError(t, err) {
+		assert.Equal(t, callback, got.TypeId())
+	}
+	s := wall.NewScope(checkedFile.GlobalScope)
+	s.DefineVar(&wall.Token{Kind: wall.IDENTIFIER, Content: "f"}, callback, false)
+	s.DefineVar(&wall.Token{Kind: wall.IDENTIFIER, Content: "b"}, checkedFile.GlobalScope.Types["Button"].TypeId, false)
+	s.DefineVar(&wall.Token{Kind: wall.IDENTIFIER, Content: "n"}, wall.INT32_TYPE_ID, false)
+	n := idExpr("n")
+	valid := []wall.ParsedExpr{
+		&wall.ParsedCallExpr{Callee: idExpr("f"), Args: []wall.ParsedExpr{n}},
+		&wall.ParsedCallExpr{Callee: &wall.ParsedObjectAccessExpr{Object: idExpr("b"), Member: idToken("onClick")}, Args: []wall.ParsedExpr{n}},
+		&wall.ParsedStructInitExpr{
+			Name:   &wall.ParsedIdType{Token: idToken("Button")},
+			Fields: []wall.ParsedStructInitField{{Name: idToken("onClick"), Value: idExpr("isZero")}},
+		},
+	}
+	for _, expr := range valid {
+		_, err := wall.CheckExpr(expr, s)
+		assert.NoError(t, err)
+	}
+	got, err = wall.CheckExpr(&wall.ParsedCallExpr{Callee: idExpr("find"), Args: []wall.ParsedExpr{idExpr("isZero")}}, s)
+	if assert.NoError(t, err) {
+		pred := got.(*wall.CheckedCallExpr).Args[0].(*wall.CheckedFunExpr)
+		assert.True(t, pred.Extern)
+		assert.Equal(t, checkedFile.TypeId(&wall.FunctionType{Params: []wall.TypeId{wall.INT32_TYPE_ID}, Returns: wall.BOOL_TYPE_ID, Extern: true}), pred.TypeId())
+	}
+	invalid := []wall.ParsedExpr{
+		&wall.ParsedCallExpr{Callee: idExpr("find"), Args: []wall.ParsedExpr{idExpr("f")}},
+		&wall.ParsedCallExpr{Callee: idExpr("f"), Args: []wall.ParsedExpr{}},
+		&wall.ParsedCallExpr{Callee: idExpr("n"), Args: []wall.ParsedExpr{}},
+		&wall.ParsedObjectAccessExpr{Object: idExpr("b"), Member: idToken("press")},
+	}
+	for _, expr := range invalid {
+		_, err := wall.CheckExpr(expr, s)
+		assert.Error(t, err)
+	}
+}
+
+func TestCheckClosures(t *testing.T) {
+	int32Type := &wall.ParsedIdType{Token: idToken("int32")}
+	checkedFile := wall.NewCheckedCompilationUnit("")
+	s := wall.NewScope(checkedFile.GlobalScope)
+	n := idToken("n")
+	s.DefineVar(&n, wall.INT32_TYPE_ID, true)
+	adder := &wall.ParsedFunExpr{
+		Params:     []wall.ParsedFunParam{{Id: idToken("x"), Type: int32Type}},
+		ReturnType: int32Type,
+		Body: &wall.ParsedBlock{Stmts: []wall.ParsedStmt{&wall.ParsedReturn{
+			Arg: &wall.ParsedBinaryExpr{
+				Left:  idExpr("x"),
+				Op:    wall.Token{Kind: wall.PLUS},
+				Right: idExpr("n"),
+			},
+		}}},
+	}
+	got, err := wall.CheckExpr(adder, s)
+	if assert.NoError(t, err) {
+		closure := got.(*wall.CheckedFunExpr)
+		assert.Equal(t, checkedFile.TypeId(&wall.FunctionType{Params: []wall.TypeId{wall.INT32_TYPE_ID}, Returns: wall.INT32_TYPE_ID}), closure.TypeId())
+		assert.Equal(t, []wall.CheckedFunParam{{Name: &n, Type: wall.INT32_TYPE_ID}}, closure.Captures)
+		assert.Equal(t, []*wall.CheckedFunExpr{closure}, checkedFile.Closures)
+	}
+	invalid := []wall.ParsedExpr{
+		&wall.ParsedFunExpr{
+			Params: []wall.ParsedFunParam{},
+			Body: &wall.ParsedBlock{Stmts: []wall.ParsedStmt{&wall.ParsedExprStmt{
+				Expr: &wall.ParsedBinaryExpr{
+					Left:  idExpr("n"),
+					Op:    wall.Token{Kind: wall.EQ},
+					Right: &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "1"}},
+				},
+			}}},
+		},
+		&wall.ParsedFunExpr{
+			Params:     []wall.ParsedFunParam{{Id: idToken("n"), Type: int32Type}},
+			ReturnType: int32Type,
+			Body: &wall.ParsedBlock{Stmts: []wall.ParsedStmt{&wall.ParsedReturn{
+				Arg: idExpr("n"),
+			}}},
+		},
+	}
+	for _, expr := range invalid {
+		_, err := wall.CheckExpr(expr, s)
+		assert.Error(t, err)
+	}
+}
+
+func TestCheckClosureEnvs(t *testing.T) {
+	int32Type := &wall.ParsedIdType{Token: idToken("int32")}
+	callbackType := &wall.ParsedFunType{Params: []wall.ParsedType{int32Type}, Returns: int32Type}
+	adder := &wall.ParsedFunExpr{
+		Params:     []wall.ParsedFunParam{{Id: idToken("x"), Type: int32Type}},
+		ReturnType: int32Type,
+		Body: &wall.ParsedBlock{Stmts: []wall.ParsedStmt{&wall.ParsedReturn{
+			Arg: &wall.ParsedBinaryExpr{Left: idExpr("x"), Op: wall.Token{Kind: wall.PLUS}, Right: idExpr("k")},
+		}}},
+	}
+	apply := &wall.ParsedFunDef{
+		Id:         idToken("apply"),
+		Params:     []wall.ParsedFunParam{{Id: idToken("f"), Type: callbackType}},
+		ReturnType: int32Type,
+		Body: &wall.ParsedBlock{Stmts: []wall.ParsedStmt{&wall.ParsedReturn{
+			Arg: &wall.ParsedCallExpr{Callee: idExpr("f"), Args: []wall.ParsedExpr{integerLiteral("1")}},
+		}}},
+	}
+	fun := func(returnType wall.ParsedType, arg wall.ParsedExpr) *wall.ParsedFunDef {
+		return &wall.ParsedFunDef{
+			Id:         idToken("g"),
+			Params:     []wall.ParsedFunParam{{Id: idToken("k"), Type: int32Type}},
+			ReturnType: returnType,
+			Body:       &wall.ParsedBlock{Stmts: []wall.ParsedStmt{&wall.ParsedReturn{Arg: arg}}},
+		}
+	}
+	tests := []struct {
+		def   *wall.ParsedFunDef
+		valid bool
+	}{
+		{fun(int32Type, &wall.ParsedCallExpr{Callee: idExpr("apply"), Args: []wall.ParsedExpr{adder}}), true},
+		{fun(int32Type, &wall.ParsedCallExpr{Callee: adder, Args: []wall.ParsedExpr{integerLiteral("1")}}), true},
+		{fun(callbackType, adder), false},
+	}
+	for _, test := range tests {
+		file := &wall.ParsedFile{Defs: []wall.ParsedDef{apply, test.def}}
+		checkedFile := wall.NewCheckedCompilationUnit("")
+		assert.NoError(t, wall.CheckFunctionSignatures(file, checkedFile))
+		if assert.NoError(t, wall.CheckBlocks(file, checkedFile)) {
+			err := wall.CheckClosureEnvs(checkedFile)
+			if test.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		}
+	}
+}
+
+func TestCheckOptionals(t *testing.T) {
+	int32Type := &wall.ParsedIdType{Token: idToken("int32")}
+	optionalInt32 := &wall.ParsedOptionalType{Elem: int32Type}
+	optionalPointer := &wall.ParsedOptionalType{Elem: &wall.ParsedPointerType{To: int32Type}}
+	file := &wall.ParsedFile{
+		Defs: []wall.ParsedDef{
+			&wall.ParsedFunDef{
+				Id:     idToken("take"),
+				Params: []wall.ParsedFunParam{{Id: idToken("x"), Type: optionalInt32}},
+				Body:   &wall.ParsedBlock{Stmts: []wall.ParsedStmt{}},
+			},
+		},
+	}
+	checkedFile := wall.NewCheckedCompilationUnit("")
+	assert.NoError(t, wall.CheckFunctionSignatures(file, checkedFile))
+	optional := checkedFile.TypeId(&wall.OptionalType{Elem: wall.INT32_TYPE_ID})
+	s := wall.NewScope(checkedFile.GlobalScope)
+	o, n := idToken("o"), idToken("n")
+	s.DefineVar(&o, optional, true)
+	s.DefineVar(&n, wall.INT32_TYPE_ID, false)
+	p := idToken("p")
+	s.DefineVar(&p, checkedFile.TypeId(&wall.OptionalType{Elem: checkedFile.TypeId(&wall.PointerType{Type: wall.INT32_TYPE_ID})}), false)
+	null := &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.NULL}}
+	got, err := wall.CheckExpr(&wall.ParsedCallExpr{Callee: idExpr("take"), Args: []wall.ParsedExpr{&wall.ParsedIdExpr{Token: n}}}, s)
+	if assert.NoError(t, err) {
+		assert.Equal(t, &wall.CheckedOptionalExpr{
+			Value: &wall.CheckedIdExpr{Id: &n, Type: wall.INT32_TYPE_ID},
+			Type:  optional,
+		}, got.(*wall.CheckedCallExpr).Args[0])
+	}
+	valid := []wall.ParsedExpr{
+		&wall.ParsedCallExpr{Callee: idExpr("take"), Args: []wall.ParsedExpr{null}},
+		&wall.ParsedBinaryExpr{Left: &wall.ParsedIdExpr{Token: o}, Op: wall.Token{Kind: wall.EQ}, Right: null},
+		&wall.ParsedBinaryExpr{Left: &wall.ParsedIdExpr{Token: o}, Op: wall.Token{Kind: wall.EQ}, Right: &wall.ParsedIdExpr{Token: n}},
+		&wall.ParsedBinaryExpr{Left: &wall.ParsedIdExpr{Token: o}, Op: wall.Token{Kind: wall.BANGEQ}, Right: null},
+		&wall.ParsedAsExpr{Value: null, Type: optionalPointer},
+	}
+	for _, expr := range valid {
+		_, err := wall.CheckExpr(expr, s)
+		assert.NoError(t, err)
+	}
+	invalid := []wall.ParsedExpr{
+		&wall.ParsedUnaryExpr{Operator: wall.Token{Kind: wall.STAR}, Operand: &wall.ParsedIdExpr{Token: p}},
+		&wall.ParsedBinaryExpr{Left: &wall.ParsedIdExpr{Token: n}, Op: wall.Token{Kind: wall.EQEQ}, Right: null},
+		&wall.ParsedBinaryExpr{Left: &wall.ParsedIdExpr{Token: o}, Op: wall.Token{Kind: wall.PLUS}, Right: &wall.ParsedIdExpr{Token: n}},
+	}
+	for _, expr := range invalid {
+		_, err := wall.CheckExpr(expr, s)
+		assert.Error(t, err)
+	}
+	unwrap := &wall.ParsedIf{
+		Binding:   &wall.Token{Kind: wall.IDENTIFIER, Content: "x"},
+		Condition: &wall.ParsedIdExpr{Token: o},
+		Body: &wall.ParsedBlock{Stmts: []wall.ParsedStmt{&wall.ParsedExprStmt{
+			Expr: &wall.ParsedBinaryExpr{Left: idExpr("x"), Op: wall.Token{Kind: wall.PLUS}, Right: &wall.ParsedIdExpr{Token: n}},
+		}}},
+	}
+	_, err = wall.CheckStmt(unwrap, s, &wall.MayReturn{Type: wall.UNIT_TYPE_ID})
+	assert.NoError(t, err)
+	unwrap.Condition = &wall.ParsedIdExpr{Token: n}
+	_, err = wall.CheckStmt(unwrap, s, &wall.MayReturn{Type: wall.UNIT_TYPE_ID})
+	assert.Error(t, err)
+	_, err = wall.CheckStmt(&wall.ParsedVar{Id: idToken("v"), Value: null}, s, &wall.MayReturn{Type: wall.UNIT_TYPE_ID})
+	assert.Error(t, err)
+}
+
+func funDef(name string, returns wall.ParsedType, stmts ...wall.ParsedStmt) *wall.ParsedFunDef {
+	return &wall.ParsedFunDef{
+		Id:         idToken(name),
+		Params:     []wall.ParsedFunParam{},
+		ReturnType: returns,
+		Body:       &wall.ParsedBlock{Stmts: stmts},
+	}
+}
+
+func TestCheckResults(t *testing.T) {
+	int32Type := &wall.ParsedIdType{Token: idToken("int32")}
+	int32Result := &wall.ParsedResultType{Value: int32Type, Error: &wall.ParsedIdType{Token: idToken("bool")}}
+	call := func(name string) *wall.ParsedPropagateExpr {
+		return &wall.ParsedPropagateExpr{Value: &wall.ParsedCallExpr{Callee: idExpr(name), Args: []wall.ParsedExpr{}}}
+	}
+	file := &wall.ParsedFile{
+		Defs: []wall.ParsedDef{
+			funDef("fail", int32Result, &wall.ParsedReturn{Arg: &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.TRUE}}}),
+			funDef("twice", int32Result, &wall.ParsedReturn{Arg: &wall.ParsedBinaryExpr{Left: call("fail"), Op: wall.Token{Kind: wall.STAR}, Right: integerLiteral("2")}}),
+			funDef("unit", &wall.ParsedResultType{Value: &wall.ParsedIdType{Token: idToken("()")}, Error: &wall.ParsedIdType{Token: idToken("bool")}}, &wall.ParsedExprStmt{Expr: call("fail")}, &wall.ParsedReturn{}),
+		},
+	}
+	checkedFile := wall.NewCheckedCompilationUnit("")
+	assert.NoError(t, wall.CheckFunctionSignatures(file, checkedFile))
+	if assert.NoError(t, wall.CheckBlocks(file, checkedFile)) {
+		result := checkedFile.TypeId(&wall.ResultType{Value: wall.INT32_TYPE_ID, Error: wall.BOOL_TYPE_ID})
+		assert.Equal(t, &wall.CheckedResultExpr{
+			Value: &wall.CheckedLiteralExpr{Literal: wall.Token{Kind: wall.TRUE}, Type: wall.BOOL_TYPE_ID},
+			Error: true,
+			Type:  result,
+		}, checkedFile.Funs[0].Body.Stmts[0].(*wall.CheckedReturn).Value)
+		propagate := checkedFile.Funs[1].Body.Stmts[0].(*wall.CheckedReturn).Value.(*wall.CheckedResultExpr).Value.(*wall.CheckedBinaryExpr).Left
+		assert.Equal(t, wall.INT32_TYPE_ID, propagate.TypeId())
+		assert.Equal(t, result, propagate.(*wall.CheckedPropagateExpr).Returns)
+	}
+	invalid := []*wall.ParsedFunDef{
+		funDef("wrongReturn", int32Type, &wall.ParsedReturn{Arg: call("fail")}),
+		funDef("wrongError", &wall.ParsedResultType{Value: int32Type, Error: &wall.ParsedIdType{Token: idToken("float64")}}, &wall.ParsedReturn{Arg: call("fail")}),
+		funDef("notResult", int32Result, &wall.ParsedReturn{Arg: &wall.ParsedPropagateExpr{Value: integerLiteral("1")}}),
+		funDef("deferred", int32Result, &wall.ParsedDefer{Stmt: &wall.ParsedExprStmt{Expr: call("fail")}}, &wall.ParsedReturn{Arg: integerLiteral("1")}),
+	}
+	for _, def := range invalid {
+		file := &wall.ParsedFile{Defs: append([]wall.ParsedDef{file.Defs[0]}, def)}
+		checkedFile := wall.NewCheckedCompilationUnit("")
+		err := wall.CheckFunctionSignatures(file, checkedFile)
+		if err == nil {
+			err = wall.CheckBlocks(file, checkedFile)
+		}
+		assert.Error(t, err, def.Id.Content)
+	}
+}
+
+func TestCheckResultVariants(t *testing.T) {
+	int32Type := &wall.ParsedIdType{Token: idToken("int32")}
+	one := integerLiteral("1")
+	variant := func(name string, args ...wall.ParsedExpr) *wall.ParsedCallExpr {
+		return &wall.ParsedCallExpr{Callee: idExpr(name), Args: args}
+	}
+	arm := func(name string, bindings ...wall.Token) wall.ParsedMatchArm {
+		return wall.ParsedMatchArm{Variant: idToken(name), Bindings: bindings, Body: &wall.ParsedBlock{}}
+	}
+	match := func(arms ...wall.ParsedMatchArm) *wall.ParsedMatch {
+		return &wall.ParsedMatch{Value: variant("same"), Arms: arms}
+	}
+	same := funDef("same", &wall.ParsedResultType{Value: int32Type, Error: int32Type}, &wall.ParsedReturn{Arg: variant("Err", one)})
+	unit := funDef("unit", &wall.ParsedResultType{Value: &wall.ParsedIdType{Token: idToken("()")}, Error: int32Type}, &wall.ParsedReturn{Arg: variant("Ok")})
+	valid := []*wall.ParsedFunDef{
+		funDef("matched", nil, match(arm("Ok", idToken("v")), arm("Err", idToken("e")))),
+		funDef("otherwise", nil, &wall.ParsedMatch{Value: variant("same"), Arms: []wall.ParsedMatchArm{arm("Err", idToken("_"))}, ElseBody: &wall.ParsedBlock{}}),
+		funDef("unitMatched", nil, &wall.ParsedMatch{Value: variant("unit"), Arms: []wall.ParsedMatchArm{arm("Ok"), arm("Err")}}),
+		funDef("annotated", nil, &wall.ParsedVar{Id: idToken("r"), Value: &wall.ParsedAsExpr{Value: variant("Ok", one), Type: same.ReturnType}}),
+	}
+	for _, def := range valid {
+		file := &wall.ParsedFile{Defs: []wall.ParsedDef{same, unit, def}}
+		checkedFile := wall.NewCheckedCompilationUnit("")
+		assert.NoError(t, wall.CheckFunctionSignatures(file, checkedFile))
+		if assert.NoError(t, wall.CheckBlocks(file, checkedFile), def.Id.Content) {
+			assert.Equal(t, &wall.CheckedResultExpr{
+				Value: &wall.CheckedLiteralExpr{Literal: one.Token, Type: wall.INT32_TYPE_ID},
+				Error: true,
+				Type:  checkedFile.TypeId(&wall.ResultType{Value: wall.INT32_TYPE_ID, Error: wall.INT32_TYPE_ID}),
+			}, checkedFile.Funs[0].Body.Stmts[0].(*wall.CheckedReturn).Value)
+		}
+	}
+	invalid := []*wall.ParsedFunDef{
+		funDef("notExhaustive", nil, match(arm("Ok", idToken("v")))),
+		funDef("unknownVariant", nil, match(arm("Ok", idToken("v")), arm("Err", idToken("e")), arm("Maybe"))),
+		funDef("unitBinding", nil, &wall.ParsedMatch{Value: variant("unit"), Arms: []wall.ParsedMatchArm{arm("Ok", idToken("v")), arm("Err")}}),
+		funDef("inferred", nil, &wall.ParsedVar{Id: idToken("r"), Value: variant("Ok", one)}),
+		funDef("unused", nil, &wall.ParsedExprStmt{Expr: variant("Err", one)}),
+		funDef("wrongType", same.ReturnType, &wall.ParsedReturn{Arg: variant("Err", &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.TRUE}})}),
+		funDef("missingError", same.ReturnType, &wall.ParsedReturn{Arg: variant("Err")}),
+	}
+	for _, def := range invalid {
+		file := &wall.ParsedFile{Defs: []wall.ParsedDef{same, unit, def}}
+		checkedFile := wall.NewCheckedCompilationUnit("")
+		err := wall.CheckFunctionSignatures(file, checkedFile)
+		if err == nil {
+			err = wall.CheckBlocks(file, checkedFile)
+		}
+		assert.Error(t, err, def.Id.Content)
+	}
+}
+
+func TestCheckUntypedConstants(t *testing.T) {
+	uint8Type := &wall.ParsedIdType{Token: idToken("uint8")}
+	fun := func(name string, arg wall.ParsedExpr) *wall.ParsedFunDef {
+		return &wall.ParsedFunDef{
+			Id:         idToken(name),
+			Params:     []wall.ParsedFunParam{{Id: idToken("x"), Type: uint8Type}},
+			ReturnType: uint8Type,
+			Body:       &wall.ParsedBlock{Stmts: []wall.ParsedStmt{&wall.ParsedReturn{Arg: arg}}},
+		}
+	}
+	add := func(left wall.ParsedExpr, right wall.ParsedExpr) *wall.ParsedBinaryExpr {
+		return &wall.ParsedBinaryExpr{Left: left, Op: wall.Token{Kind: wall.PLUS}, Right: right}
+	}
+	x := idExpr("x")
+	// 200 * 2 overflows uint8, but the folded quotient doesn't.
+	folded := &wall.ParsedBinaryExpr{
+		Left:  &wall.ParsedBinaryExpr{Left: integerLiteral("200"), Op: wall.Token{Kind: wall.STAR}, Right: integerLiteral("2")},
+		Op:    wall.Token{Kind: wall.SLASH},
+		Right: integerLiteral("4"),
+	}
+	file := &wall.ParsedFile{
+		Defs: []wall.ParsedDef{
+			fun("inc", add(x, integerLiteral("1"))),
+			fun("dec", add(integerLiteral("255"), x)),
+			fun("call", &wall.ParsedCallExpr{Callee: idExpr("inc"), Args: []wall.ParsedExpr{integerLiteral("200")}}),
+			fun("conversion", &wall.ParsedAsExpr{Value: integerLiteral("255"), Type: uint8Type}),
+			fun("folded", add(x, folded)),
+		},
+	}
+	checkedFile := wall.NewCheckedCompilationUnit("")
+	assert.NoError(t, wall.CheckFunctionSignatures(file, checkedFile))
+	if assert.NoError(t, wall.CheckBlocks(file, checkedFile)) {
+		inc := checkedFile.Funs[0].Body.Stmts[0].(*wall.CheckedReturn).Value.(*wall.CheckedBinaryExpr)
+		assert.Equal(t, &wall.CheckedLiteralExpr{Literal: integerLiteral("1").Token, Type: wall.UINT8_TYPE_ID}, inc.Right)
+		dec := checkedFile.Funs[1].Body.Stmts[0].(*wall.CheckedReturn).Value.(*wall.CheckedBinaryExpr)
+		assert.Equal(t, wall.UINT8_TYPE_ID, dec.Left.TypeId())
+		call := checkedFile.Funs[2].Body.Stmts[0].(*wall.CheckedReturn).Value.(*wall.CheckedCallExpr)
+		assert.Equal(t, wall.UINT8_TYPE_ID, call.Args[0].TypeId())
+		conversion := checkedFile.Funs[3].Body.Stmts[0].(*wall.CheckedReturn).Value.(*wall.CheckedAsExpr)
+		assert.Equal(t, wall.UINT8_TYPE_ID, conversion.Value.TypeId())
+		sum := checkedFile.Funs[4].Body.Stmts[0].(*wall.CheckedReturn).Value.(*wall.CheckedBinaryExpr)
+		assert.Equal(t, "100", wall.CodegenExpr(sum.Right, checkedFile.GlobalScope))
+	}
+	invalid := []*wall.ParsedFunDef{
+		fun("overflow", add(x, integerLiteral("256"))),
+		fun("negative", add(x, &wall.ParsedUnaryExpr{Operator: wall.Token{Kind: wall.MINUS}, Operand: integerLiteral("1")})),
+		fun("truncated", add(x, &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.FLOAT, Content: "1.5"}})),
+		fun("division", &wall.ParsedBinaryExpr{Left: x, Op: wall.Token{Kind: wall.SLASH}, Right: integerLiteral("0")}),
+		fun("returned", integerLiteral("1000")),
+	}
+	for _, def := range invalid {
+		file := &wall.ParsedFile{Defs: []wall.ParsedDef{def}}
+		checkedFile := wall.NewCheckedCompilationUnit("")
+		err := wall.CheckFunctionSignatures(file, checkedFile)
+		if err == nil {
+			err = wall.CheckBlocks(file, checkedFile)
+		}
+		assert.Error(t, err, def.Id.Content)
+	}
+}
+
+func TestCheckIntegerLiteralRanges(t *testing.T) {
+	integer := func(content string, line uint) *wall.ParsedLiteralExpr {
+		return &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: content, Pos: wall.Pos{Filename: "a.wall", Line: line}}}
+	}
+	as := func(value wall.ParsedExpr, typename string) *wall.ParsedAsExpr {
+		return &wall.ParsedAsExpr{Value: value, Type: &wall.ParsedIdType{Token: wall.Token{Kind: wall.IDENTIFIER, Content: typename}}}
+	}
+	negate := func(value wall.ParsedExpr) *wall.ParsedUnaryExpr {
+		return &wall.ParsedUnaryExpr{Operator: wall.Token{Kind: wall.MINUS}, Operand: value}
+	}
+	check := func(expr wall.ParsedExpr) error {
+		file := &wall.ParsedFile{
+			Defs: []wall.ParsedDef{
+				&wall.ParsedFunDef{
+					Id:     wall.Token{Kind: wall.IDENTIFIER, Content: "f"},
+					Params: []wall.ParsedFunParam{},
+					Body: &wall.ParsedBlock{Stmts: []wall.ParsedStmt{
+						&wall.ParsedVar{Id: wall.Token{Kind: wall.IDENTIFIER, Content: "x"}, Value: expr},
+					}},
+				},
+			},
+		}
+		checkedFile := wall.NewCheckedCompilationUnit("")
+		if err := wall.CheckFunctionSignatures(file, checkedFile); err != nil {
+			return err
+		}
+		return wall.CheckBlocks(file, checkedFile)
+	}
+	valid := []wall.ParsedExpr{
+		integer("2147483647", 1),
+		negate(integer("2147483648", 1)),
+		as(integer("255", 1), "uint8"),
+		as(negate(integer("128", 1)), "int8"),
+		as(integer("18446744073709551615", 1), "uint64"),
+		as(integer("3000000000", 1), "int64"),
+	}
+	for _, expr := range valid {
+		assert.NoError(t, check(expr))
+	}
+	invalid := []struct {
+		expr wall.ParsedExpr
+		err  string
+	}{
+		{integer("2147483648", 3), "a.wall:3: error: constant 2147483648 overflows int32"},
+		{as(integer("300", 4), "uint8"), "a.wall:4: error: constant 300 overflows uint8"},
+		{as(negate(integer("1", 5)), "uint"), "a.wall:5: error: constant -1 overflows uint"},
+		{as(integer("18446744073709551616", 6), "uint64"), "a.wall:6: error: constant 18446744073709551616 overflows uint64"},
+	}
+	for _, test := range invalid {
+		if err := check(test.expr); assert.Error(t, err) {
+			assert.Equal(t, test.err, err.Error())
+		}
+	}
+}
+
+func TestCheckConstDefs(t *testing.T) {
+	binary := func(left wall.ParsedExpr, op wall.TokenKind, right wall.ParsedExpr) *wall.ParsedBinaryExpr {
+		return &wall.ParsedBinaryExpr{Left: left, Op: wall.Token{Kind: op}, Right: right}
+	}
+	constDef := func(name string, value wall.ParsedExpr) *wall.ParsedConstDef {
+		return &wall.ParsedConstDef{Name: idToken(name), Value: value}
+	}
+	uint8Type := &wall.ParsedIdType{Token: idToken("uint8")}
+	fun := func(stmts ...wall.ParsedStmt) *wall.ParsedFunDef {
+		return &wall.ParsedFunDef{
+			Id:         idToken("f"),
+			Params:     []wall.ParsedFunParam{{Id: idToken("x"), Type: uint8Type}},
+			ReturnType: uint8Type,
+			Body:       &wall.ParsedBlock{Stmts: stmts},
+		}
+	}
+	fileB := &wall.ParsedFile{
+		Defs: []wall.ParsedDef{
+			constDef("SIDES", integerLiteral("4")),
+		},
+	}
+	fileA := &wall.ParsedFile{
+		Defs: []wall.ParsedDef{
+			&wall.ParsedImport{Name: idToken("b"), File: fileB},
+			constDef("AREA", binary(&wall.ParsedModuleAccessExpr{Module: idToken("b"), Member: idExpr("SIDES")}, wall.STAR, idExpr("SIDES2"))),
+			constDef("SIDES2", binary(integerLiteral("2"), wall.PLUS, integerLiteral("3"))),
+			constDef("MASK", &wall.ParsedAsExpr{Value: integerLiteral("15"), Type: uint8Type}),
+			fun(&wall.ParsedReturn{Arg: binary(binary(idExpr("x"), wall.PLUS, idExpr("AREA")), wall.AMP, idExpr("MASK"))}),
+		},
+	}
+	checkedFile := wall.NewCheckedCompilationUnit("")
+	assert.NoError(t, wall.CheckImports(fileA, checkedFile))
+	assert.NoError(t, wall.CheckFunctionSignatures(fileA, checkedFile))
+	if assert.NoError(t, wall.CheckBlocks(fileA, checkedFile)) {
+		and := checkedFile.Funs[0].Body.Stmts[0].(*wall.CheckedReturn).Value.(*wall.CheckedBinaryExpr)
+		assert.Equal(t, &wall.CheckedLiteralExpr{Literal: wall.Token{Kind: wall.INTEGER, Content: "20"}, Type: wall.UINT8_TYPE_ID}, and.Left.(*wall.CheckedBinaryExpr).Right)
+		assert.Equal(t, &wall.CheckedAsExpr{
+			Value: &wall.CheckedLiteralExpr{Literal: wall.Token{Kind: wall.INTEGER, Content: "15"}, Type: wall.INT32_TYPE_ID},
+			Type:  wall.UINT8_TYPE_ID,
+		}, and.Right)
+	}
+	int32Type := &wall.ParsedIdType{Token: idToken("int32")}
+	charByte := &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.CHARACTER, Content: "\xff"}}
+	file := &wall.ParsedFile{
+		Defs: []wall.ParsedDef{
+			constDef("BYTE", &wall.ParsedAsExpr{Value: charByte, Type: int32Type}),
+			constDef("SIGNED", binary(charByte, wall.LT, &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.CHARACTER, Content: "a"}})),
+			&wall.ParsedFunDef{
+				Id:         idToken("f"),
+				ReturnType: int32Type,
+				Body: &wall.ParsedBlock{Stmts: []wall.ParsedStmt{
+					&wall.ParsedIf{Condition: idExpr("SIGNED"), Body: &wall.ParsedBlock{Stmts: []wall.ParsedStmt{&wall.ParsedReturn{Arg: idExpr("BYTE")}}}},
+					&wall.ParsedReturn{Arg: integerLiteral("0")},
+				}},
+			},
+		},
+	}
+	checkedFile = wall.NewCheckedCompilationUnit("")
+	assert.NoError(t, wall.CheckFunctionSignatures(file, checkedFile))
+	if assert.NoError(t, wall.CheckBlocks(file, checkedFile)) {
+		// char is signed, as in C.
+		stmts := checkedFile.Funs[0].Body.Stmts
+		assert.Equal(t, &wall.CheckedLiteralExpr{Literal: wall.Token{Kind: wall.TRUE, Content: "true"}, Type: wall.BOOL_TYPE_ID}, stmts[0].(*wall.CheckedIf).Cond)
+		assert.Equal(t, "(int32_t) ((-1))", wall.CodegenExpr(stmts[0].(*wall.CheckedIf).Body.Stmts[0].(*wall.CheckedReturn).Value, checkedFile.GlobalScope))
+	}
+	int64Type := &wall.ParsedIdType{Token: idToken("int64")}
+	sized := &wall.ParsedArrayType{Len: idExpr("SIZE"), Elem: int64Type}
+	file = &wall.ParsedFile{
+		Defs: []wall.ParsedDef{
+			&wall.ParsedFunDef{
+				Id:         idToken("first"),
+				Params:     []wall.ParsedFunParam{{Id: idToken("xs"), Type: sized}},
+				ReturnType: int64Type,
+				Body: &wall.ParsedBlock{Stmts: []wall.ParsedStmt{
+					&wall.ParsedVar{Id: idToken("half"), Value: idExpr("HALF")},
+					&wall.ParsedReturn{
+						Arg: binary(&wall.ParsedIndexExpr{Object: idExpr("xs"), Index: integerLiteral("0")}, wall.PLUS, idExpr("HUGE")),
+					},
+				}},
+			},
+			constDef("SIZE", binary(integerLiteral("0x2"), wall.STAR, integerLiteral("2"))),
+			constDef("HUGE", binary(integerLiteral("5000000000"), wall.STAR, integerLiteral("2"))),
+			constDef("HALF", binary(integerLiteral("1"), wall.SLASH, &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.FLOAT, Content: "2.0"}})),
+		},
+	}
+	checkedFile = wall.NewCheckedCompilationUnit("")
+	assert.NoError(t, wall.CheckFunctionSignatures(file, checkedFile))
+	if assert.NoError(t, wall.CheckBlocks(file, checkedFile)) {
+		assert.Equal(t, checkedFile.TypeId(&wall.ArrayType{Elem: wall.INT64_TYPE_ID, Len: 4}), checkedFile.Funs[0].Params[0].Type)
+	}
+	invalid := [][]wall.ParsedDef{
+		{constDef("A", binary(idExpr("B"), wall.PLUS, integerLiteral("1"))), constDef("B", idExpr("A"))},
+		{constDef("SIZE", &wall.ParsedUnaryExpr{Operator: wall.Token{Kind: wall.MINUS}, Operand: integerLiteral("1")}), &wall.ParsedFunDef{
+			Id:     idToken("first"),
+			Params: []wall.ParsedFunParam{{Id: idToken("xs"), Type: sized}},
+			Body:   &wall.ParsedBlock{},
+		}},
+		{constDef("A", integerLiteral("5000000000")), fun(&wall.ParsedVar{Id: idToken("y"), Value: idExpr("A")}, &wall.ParsedReturn{Arg: idExpr("x")})},
+		{constDef("A", &wall.ParsedAsExpr{Value: integerLiteral("300"), Type: uint8Type})},
+		{constDef("A", binary(integerLiteral("1"), wall.SLASH, integerLiteral("0")))},
+		{constDef("A", integerLiteral("1")), constDef("A", integerLiteral("2"))},
+		{constDef("A", &wall.ParsedAsExpr{Value: integerLiteral("256"), Type: &wall.ParsedIdType{Token: idToken("char")}})},
+		{constDef("A", idExpr("f")), fun(&wall.ParsedReturn{Arg: idExpr("x")})},
+		{constDef("A", integerLiteral("256")), fun(&wall.ParsedReturn{Arg: binary(idExpr("x"), wall.PLUS, idExpr("A"))})},
+	}
+	for _, defs := range invalid {
+		file := &wall.ParsedFile{Defs: defs}
+		checkedFile := wall.NewCheckedCompilationUnit("")
+		err := wall.CheckFunctionSignatures(file, checkedFile)
+		if err == nil {
+			err = wall.CheckBlocks(file, checkedFile)
+		}
+		assert.Error(t, err)
+	}
+}
+
+func TestCheckVarDefs(t *testing.T) {
+	mut := wall.Token{Kind: wall.MUT}
+	verbose := &wall.ParsedModuleAccessExpr{Module: idToken("config"), Member: idExpr("verbose")}
+	assign := func(left wall.ParsedExpr) *wall.ParsedFunDef {
+		return &wall.ParsedFunDef{
+			Id:     idToken("f"),
+			Params: []wall.ParsedFunParam{},
+			Body: &wall.ParsedBlock{Stmts: []wall.ParsedStmt{
+				&wall.ParsedExprStmt{Expr: &wall.ParsedBinaryExpr{Left: left, Op: wall.Token{Kind: wall.EQ}, Right: integerLiteral("2")}},
+			}},
+		}
+	}
+	config := &wall.ParsedFile{
+		Defs: []wall.ParsedDef{
+			&wall.ParsedVarDef{Mut: &mut, Name: idToken("verbose"), Value: integerLiteral("1")},
+			&wall.ParsedVarDef{Name: idToken("level"), Value: idExpr("verbose")},
+		},
+	}
+	file := &wall.ParsedFile{
+		Defs: []wall.ParsedDef{
+			&wall.ParsedImport{Name: idToken("config"), File: config},
+			&wall.ParsedVarDef{Name: idToken("copy"), Value: verbose},
+			assign(verbose),
+		},
+	}
+	checkedFile := wall.NewCheckedCompilationUnit("")
+	assert.NoError(t, wall.CheckImports(file, checkedFile))
+	assert.NoError(t, wall.CheckFunctionSignatures(file, checkedFile))
+	if assert.NoError(t, wall.CheckBlocks(file, checkedFile)) {
+		globals := checkedFile.Imports[0].File.Globals
+		if assert.Len(t, globals, 2) {
+			assert.Equal(t, "verbose", globals[0].Name.Content)
+			assert.True(t, globals[0].Static)
+			assert.False(t, globals[1].Static)
+		}
+		if assert.Len(t, checkedFile.Globals, 1) {
+			assert.Equal(t, wall.INT32_TYPE_ID, checkedFile.Globals[0].Value.TypeId())
+			assert.False(t, checkedFile.Globals[0].Static)
+		}
+	}
+	invalid := [][]wall.ParsedDef{
+		{&wall.ParsedVarDef{Name: idToken("a"), Value: integerLiteral("1")}, assign(idExpr("a"))},
+		{&wall.ParsedVarDef{Name: idToken("a"), Value: idExpr("b")}, &wall.ParsedVarDef{Name: idToken("b"), Value: integerLiteral("1")}},
+		{&wall.ParsedVarDef{Name: idToken("a"), Value: &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.NULL}}}},
+		{&wall.ParsedVarDef{Name: idToken("a"), Value: integerLiteral("1")}, &wall.ParsedVarDef{Name: idToken("a"), Value: integerLiteral("2")}},
+	}
+	for _, defs := range invalid {
+		file := &wall.ParsedFile{Defs: defs}
+		checkedFile := wall.NewCheckedCompilationUnit("")
+		err := wall.CheckFunctionSignatures(file, checkedFile)
+		if err == nil {
+			err = wall.CheckBlocks(file, checkedFile)
+		}
+		assert.Error(t, err)
+	}
+}
+
+func TestCheckCharLiterals(t *testing.T) {
+	checkedFile := wall.NewCheckedCompilationUnit("")
+	char := func(content string) *wall.ParsedLiteralExpr {
+		return &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.CHARACTER, Content: content}}
+	}
+	got, err := wall.CheckExpr(char("a"), checkedFile.GlobalScope)
+	if assert.NoError(t, err) {
+		assert.Equal(t, &wall.CheckedLiteralExpr{
+			Literal: wall.Token{Kind: wall.CHARACTER, Content: "a"},
+			Type:    wall.CHAR_TYPE_ID,
+		}, got)
+	}
+	got, err = wall.CheckExpr(&wall.ParsedBinaryExpr{Left: char("a"), Op: wall.Token{Kind: wall.LT}, Right: char("z")}, checkedFile.GlobalScope)
+	if assert.NoError(t, err) {
+		assert.Equal(t, wall.BOOL_TYPE_ID, got.TypeId())
+	}
+	_, err = wall.CheckExpr(&wall.ParsedBinaryExpr{Left: char("a"), Op: wall.Token{Kind: wall.PLUS}, Right: char("b")}, checkedFile.GlobalScope)
+	assert.Error(t, err)
+}
+
+func TestCheckNumericLiterals(t *testing.T) {
+	checkedFile := wall.NewCheckedCompilationUnit("")
+	literal := func(kind wall.TokenKind, content string) *wall.ParsedLiteralExpr {
+		return &wall.ParsedLiteralExpr{Token: wall.Token{Kind: kind, Content: content}}
+	}
+	tests := []struct {
+		literal *wall.ParsedLiteralExpr
+		want    wall.CheckedExpr
+	}{
+		{literal(wall.INTEGER, "0xFF"), &wall.CheckedLiteralExpr{Literal: wall.Token{Kind: wall.INTEGER, Content: "255"}, Type: wall.INT32_TYPE_ID}},
+		{literal(wall.INTEGER, "0b1010"), &wall.CheckedLiteralExpr{Literal: wall.Token{Kind: wall.INTEGER, Content: "10"}, Type: wall.INT32_TYPE_ID}},
+		{literal(wall.INTEGER, "0o17"), &wall.CheckedLiteralExpr{Literal: wall.Token{Kind: wall.INTEGER, Content: "15"}, Type: wall.INT32_TYPE_ID}},
+		{literal(wall.INTEGER, "1_000"), &wall.CheckedLiteralExpr{Literal: wall.Token{Kind: wall.INTEGER, Content: "1000"}, Type: wall.INT32_TYPE_ID}},
+		{literal(wall.INTEGER, "017"), &wall.CheckedLiteralExpr{Literal: wall.Token{Kind: wall.INTEGER, Content: "17"}, Type: wall.INT32_TYPE_ID}},
+		{literal(wall.FLOAT, "1_0.5e-3"), &wall.CheckedLiteralExpr{Literal: wall.Token{Kind: wall.FLOAT, Content: "10.5e-3"}, Type: wall.FLOAT64_TYPE_ID}},
+		{literal(wall.INTEGER, "10u8"), &wall.CheckedAsExpr{
+			Value: &wall.CheckedLiteralExpr{Literal: wall.Token{Kind: wall.INTEGER, Content: "10"}, Type: wall.INT32_TYPE_ID},
+			Type:  wall.UINT8_TYPE_ID,
+		}},
+		{literal(wall.FLOAT, "1.0f32"), &wall.CheckedAsExpr{
+			Value: &wall.CheckedLiteralExpr{Literal: wall.Token{Kind: wall.FLOAT, Content: "1.0"}, Type: wall.FLOAT64_TYPE_ID},
+			Type:  wall.FLOAT32_TYPE_ID,
+		}},
+	}
+	for _, test := range tests {
+		got, err := wall.CheckExpr(test.literal, checkedFile.GlobalScope)
+		if assert.NoError(t, err, test.literal.Content) {
+			assert.Equal(t, test.want, got, test.literal.Content)
+		}
+	}
+	invalid := []*wall.ParsedLiteralExpr{
+		literal(wall.INTEGER, "300u8"),
+		literal(wall.INTEGER, "10q"),
+		literal(wall.INTEGER, "1__0"),
+		literal(wall.INTEGER, "1_"),
+		literal(wall.INTEGER, "0b102"),
+		literal(wall.INTEGER, "1e"),
+		literal(wall.FLOAT, "1.5u8"),
+	}
+	for _, literal := range invalid {
+		_, err := wall.CheckExpr(literal, checkedFile.GlobalScope)
+		assert.Error(t, err, literal.Content)
+	}
+}
+
+func TestCheckInterpolatedString(t *testing.T) {
+	checkedFile := wall.NewCheckedCompilationUnit("")
+	constChar := checkedFile.TypeId(&wall.PointerType{Type: wall.CHAR_TYPE_ID})
+	checkedFile.GlobalScope.DefineVar(&wall.Token{Kind: wall.IDENTIFIER, Content: "n"}, wall.UINT8_TYPE_ID, false)
+	checkedFile.GlobalScope.DefineVar(&wall.Token{Kind: wall.IDENTIFIER, Content: "ok"}, wall.BOOL_TYPE_ID, false)
+	checkedFile.GlobalScope.DefineVar(&wall.Token{Kind: wall.IDENTIFIER, Content: "name"}, constChar, false)
+	got, err := wall.CheckExpr(&wall.ParsedInterpolatedStringExpr{
+		Parts: []wall.Token{{Content: "100% "}, {Content: " "}, {Content: " "}, {Content: " "}, {Content: "!"}},
+		Exprs: []wall.ParsedExpr{
+			idExpr("n"),
+			idExpr("ok"),
+			idExpr("name"),
+			&wall.ParsedUnaryExpr{Operator: wall.Token{Kind: wall.MINUS}, Operand: &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.FLOAT, Content: "0.5"}}},
+		},
+	}, checkedFile.GlobalScope)
+	if !assert.NoError(t, err) {
+		return
+	}
+	str, ok := got.(*wall.CheckedInterpolatedStringExpr)
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Equal(t, constChar, str.Type)
+	assert.Equal(t, &wall.CheckedLiteralExpr{
+		Literal: wall.Token{Kind: wall.STRING, Content: "100%% %llu %s %s %g!"},
+		Type:    constChar,
+	}, str.Format)
+	if assert.Len(t, str.Args, 4) {
+		assert.Equal(t, wall.UINT64_TYPE_ID, str.Args[0].TypeId())
+		assert.IsType(t, &wall.CheckedIfExpr{}, str.Args[1])
+		assert.Equal(t, constChar, str.Args[1].TypeId())
+		assert.Equal(t, constChar, str.Args[2].TypeId())
+		assert.Equal(t, wall.FLOAT64_TYPE_ID, str.Args[3].TypeId())
+	}
+	_, err = wall.CheckExpr(&wall.ParsedInterpolatedStringExpr{
+		Parts: []wall.Token{{}, {}},
+		Exprs: []wall.ParsedExpr{&wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.NULL}}},
+	}, checkedFile.GlobalScope)
+	assert.Error(t, err)
+
+	checkedFile.GlobalScope.DefineVar(&wall.Token{Kind: wall.IDENTIFIER, Content: "puts"}, checkedFile.TypeId(&wall.FunctionType{
+		Params:  []wall.TypeId{constChar},
+		Returns: wall.INT32_TYPE_ID,
+		Extern:  true,
+	}), false)
+	interpolated := func() *wall.ParsedInterpolatedStringExpr {
+		return &wall.ParsedInterpolatedStringExpr{Parts: []wall.Token{{Content: "n = "}, {}}, Exprs: []wall.ParsedExpr{idExpr("n")}}
+	}
+	tests := []struct {
+		stmt  wall.ParsedStmt
+		valid bool
+	}{
+		{&wall.ParsedExprStmt{Expr: &wall.ParsedCallExpr{Callee: idExpr("puts"), Args: []wall.ParsedExpr{interpolated()}}}, true},
+		{&wall.ParsedExprStmt{Expr: &wall.ParsedCallExpr{Callee: idExpr("puts"), Args: []wall.ParsedExpr{&wall.ParsedInterpolatedStringExpr{
+			Parts: []wall.Token{{Content: "("}, {Content: ")"}},
+			Exprs: []wall.ParsedExpr{interpolated()},
+		}}}}, true},
+		{&wall.ParsedExprStmt{Expr: interpolated()}, false},
+		{&wall.ParsedVar{Id: idToken("s"), Value: interpolated()}, false},
+		{&wall.ParsedReturn{Arg: interpolated()}, false},
+	}
+	for _, test := range tests {
+		_, err := wall.CheckStmt(&wall.ParsedBlock{Stmts: []wall.ParsedStmt{test.stmt}}, wall.NewScope(checkedFile.GlobalScope), &wall.MayReturn{Type: constChar})
+		if test.valid {
+			assert.NoError(t, err)
+		} else {
+			assert.Error(t, err)
+		}
+	}
+}
+
+func TestCheckPointerArithmetic(t *testing.T) {
+	checkedFile := wall.NewCheckedCompilationUnit("")
+	intPointer := checkedFile.TypeId(&wall.PointerType{Type: wall.INT32_TYPE_ID})
+	checkedFile.GlobalScope.DefineVar(&wall.Token{Kind: wall.IDENTIFIER, Content: "p"}, intPointer, true)
+	checkedFile.GlobalScope.DefineVar(&wall.Token{Kind: wall.IDENTIFIER, Content: "q"}, intPointer, false)
+	checkedFile.GlobalScope.DefineVar(&wall.Token{Kind: wall.IDENTIFIER, Content: "f"}, wall.FLOAT64_TYPE_ID, false)
+	one := &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.INTEGER, Content: "1"}}
+	binary := func(left wall.ParsedExpr, op wall.TokenKind, right wall.ParsedExpr) *wall.ParsedBinaryExpr {
+		return &wall.ParsedBinaryExpr{Left: left, Op: wall.Token{Kind: op}, Right: right}
+	}
+	index := &wall.ParsedIndexExpr{Object: idExpr("q"), Index: one}
+	tests := []struct {
+		expr wall.ParsedExpr
+		want wall.TypeId
+	}{
+		{binary(idExpr("p"), wall.PLUS, one), intPointer},
+		{binary(idExpr("p"), wall.MINUS, one), intPointer},
+		{binary(idExpr("p"), wall.MINUS, idExpr("q")), wall.INT_TYPE_ID},
+		{binary(idExpr("p"), wall.PLUSEQ, one), intPointer},
+		{index, wall.INT32_TYPE_ID},
+		{binary(index, wall.EQ, one), wall.INT32_TYPE_ID},
+		{&wall.ParsedSliceExpr{Object: idExpr("p"), Low: one, High: one}, checkedFile.TypeId(&wall.SliceType{Elem: wall.INT32_TYPE_ID})},
+	}
+	unsafe := wall.NewScope(checkedFile.GlobalScope)
+	unsafe.Unsafe = true
+	for _, test := range tests {
+		got, err := wall.CheckExpr(test.expr, unsafe)
+		if assert.NoError(t, err) {
+			assert.Equal(t, test.want, got.TypeId())
+		}
+		_, err = wall.CheckExpr(test.expr, checkedFile.GlobalScope)
+		assert.Error(t, err)
+	}
+	for _, expr := range []wall.ParsedExpr{
+		binary(idExpr("p"), wall.PLUS, idExpr("q")),
+		binary(idExpr("p"), wall.PLUS, idExpr("f")),
+		binary(idExpr("p"), wall.MINUSEQ, idExpr("q")),
+		binary(one, wall.PLUS, idExpr("p")),
+	} {
+		_, err := wall.CheckExpr(expr, unsafe)
+		assert.Error(t, err)
+	}
+}
+
+func TestCheckIfChains(t *testing.T) {
+	checkedFile := wall.NewCheckedCompilationUnit("")
+	checkedFile.GlobalScope.DefineVar(&wall.Token{Kind: wall.IDENTIFIER, Content: "c"}, wall.BOOL_TYPE_ID, false)
+	c := &wall.ParsedIdExpr{Token: wall.Token{Kind: wall.IDENTIFIER, Content: "c"}}
+	block := func(stmts ...wall.ParsedStmt) *wall.ParsedBlock {
+		return &wall.ParsedBlock{Stmts: stmts}
+	}
+	ret := func(content string) *wall.ParsedReturn {
+		return &wall.ParsedReturn{Arg: integerLiteral(content)}
+	}
+	chain := &wall.ParsedIf{
+		Condition: c,
+		Body:      block(ret("1")),
+		ElseIf: &wall.ParsedIf{
+			Condition: c,
+			Body:      block(ret("2")),
+			ElseBody:  block(ret("3")),
+		},
+	}
+	_, err := wall.CheckStmt(chain, checkedFile.GlobalScope, &wall.MustReturn{Type: wall.INT32_TYPE_ID})
+	assert.NoError(t, err)
+	chain.ElseIf.ElseBody = nil
+	_, err = wall.CheckStmt(chain, checkedFile.GlobalScope, &wall.MustReturn{Type: wall.INT32_TYPE_ID})
+	assert.Error(t, err)
+
+	value := func(expr wall.ParsedExpr) *wall.ParsedBlock {
+		return block(&wall.ParsedExprStmt{Expr: expr})
+	}
+	float := &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.FLOAT, Content: "2.5"}}
+	got, err := wall.CheckExpr(&wall.ParsedIfExpr{If: &wall.ParsedIf{Condition: c, Body: value(integerLiteral("1")), ElseBody: value(float)}}, checkedFile.GlobalScope)
+	if assert.NoError(t, err) {
+		assert.Equal(t, wall.FLOAT64_TYPE_ID, got.TypeId())
+	}
+	stmt, err := wall.CheckStmt(&wall.ParsedReturn{Arg: &wall.ParsedIfExpr{If: &wall.ParsedIf{
+		Condition: c,
+		Body:      value(integerLiteral("1")),
+		ElseIf:    &wall.ParsedIf{Condition: c, Body: value(integerLiteral("2")), ElseBody: value(integerLiteral("255"))},
+	}}}, checkedFile.GlobalScope, &wall.MustReturn{Type: wall.UINT8_TYPE_ID})
+	if assert.NoError(t, err) {
+		assert.Equal(t, wall.UINT8_TYPE_ID, stmt.(*wall.CheckedReturn).Value.TypeId())
+	}
+	invalid := []*wall.ParsedIf{
+		{Condition: c, Body: value(integerLiteral("1"))},
+		{Condition: c, Body: value(integerLiteral("1")), ElseBody: value(&wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.TRUE}})},
+		{Condition: c, Body: block(ret("1")), ElseBody: value(integerLiteral("2"))},
+		{Condition: c, Body: block(), ElseBody: value(integerLiteral("2"))},
+		{Condition: c, Body: value(integerLiteral("1")), ElseBody: block(&wall.ParsedVar{Id: wall.Token{Kind: wall.IDENTIFIER, Content: "x"}, Value: integerLiteral("2")})},
+	}
+	for _, p := range invalid {
+		_, err := wall.CheckExpr(&wall.ParsedIfExpr{If: p}, checkedFile.GlobalScope)
+		assert.Error(t, err)
+	}
+}
+
+func TestCheckForLoops(t *testing.T) {
+	checkedFile := wall.NewCheckedCompilationUnit("")
+	define := func(name string, typ wall.TypeId, mutable bool) {
+		checkedFile.GlobalScope.DefineVar(&wall.Token{Kind: wall.IDENTIFIER, Content: name}, typ, mutable)
+	}
+	define("n", wall.UINT8_TYPE_ID, false)
+	define("c", wall.BOOL_TYPE_ID, false)
+	define("xs", checkedFile.TypeId(&wall.ArrayType{Elem: wall.INT32_TYPE_ID, Len: 3}), false)
+	define("sum", wall.INT32_TYPE_ID, true)
+	addTo := func(left string, right wall.ParsedExpr) *wall.ParsedExprStmt {
+		return &wall.ParsedExprStmt{Expr: &wall.ParsedBinaryExpr{Left: idExpr(left), Op: wall.Token{Kind: wall.PLUSEQ}, Right: right}}
+	}
+	body := func(stmts ...wall.ParsedStmt) *wall.ParsedBlock {
+		return &wall.ParsedBlock{Stmts: stmts}
+	}
+	forIn := func(name string, iterable wall.ParsedExpr, end wall.ParsedExpr, b *wall.ParsedBlock) *wall.ParsedForIn {
+		p := &wall.ParsedForIn{Name: wall.Token{Kind: wall.IDENTIFIER, Content: name}, Iterable: iterable, Body: b}
+		if end != nil {
+			p.DotDot = &wall.Token{Kind: wall.DOTDOT}
+			p.End = end
+		}
+		return p
+	}
+	valid := []wall.ParsedStmt{
+		forIn("i", integerLiteral("0"), idExpr("n"), body(&wall.ParsedBreak{})),
+		forIn("x", idExpr("xs"), nil, body(addTo("sum", idExpr("x")), &wall.ParsedContinue{})),
+		&wall.ParsedFor{
+			Init: &wall.ParsedVar{Mut: &wall.Token{Kind: wall.MUT}, Id: wall.Token{Kind: wall.IDENTIFIER, Content: "i"}, Value: integerLiteral("0")},
+			Cond: &wall.ParsedBinaryExpr{Left: idExpr("i"), Op: wall.Token{Kind: wall.LT}, Right: integerLiteral("10")},
+			Post: addTo("i", integerLiteral("1")),
+			Body: body(addTo("sum", idExpr("i"))),
+		},
+		&wall.ParsedFor{Body: body(&wall.ParsedBreak{})},
+	}
+	for _, p := range valid {
+		_, err := wall.CheckStmt(p, checkedFile.GlobalScope, &wall.MayReturn{Type: wall.UNIT_TYPE_ID})
+		assert.NoError(t, err)
+	}
+	invalid := []wall.ParsedStmt{
+		forIn("i", &wall.ParsedLiteralExpr{Token: wall.Token{Kind: wall.TRUE}}, idExpr("c"), body()),
+		forIn("i", idExpr("n"), nil, body()),
+		forIn("i", integerLiteral("0"), idExpr("n"), body(addTo("i", integerLiteral("1")))),
+		forIn("x", idExpr("xs"), nil, body(&wall.ParsedReturn{Arg: integerLiteral("1")})),
+		&wall.ParsedFor{Cond: integerLiteral("1"), Body: body()},
+		&wall.ParsedFor{Post: &wall.ParsedBreak{}, Body: body()},
+	}
+	for _, p := range invalid {
+		_, err := wall.CheckStmt(p, checkedFile.GlobalScope, &wall.MayReturn{Type: wall.UNIT_TYPE_ID})
+		assert.Error(t, err)
+	}
+}